@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fragpit/env-cleaner/internal/config"
+	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/internal/service"
+	"github.com/fragpit/env-cleaner/internal/storage"
+)
+
+var (
+	apiKeyScopes string
+	apiKeyTTL    string
+)
+
+var apiKeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "Manage API keys",
+	Long: `API key command group manages named API keys. It works on the server
+database directly and reads the server configuration.`,
+	Annotations: map[string]string{serverConfigAnnotation: ""},
+}
+
+var apiKeyCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create API key",
+	Long: `Create API key. The key is printed once and only its hash is
+stored.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := CreateAPIKey(args[0]); err != nil {
+			slog.Error("error", slog.Any("error", err))
+			os.Exit(1)
+		}
+	},
+}
+
+var apiKeyListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List API keys",
+	Long:    `List API keys`,
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := ListAPIKeys(); err != nil {
+			slog.Error("error", slog.Any("error", err))
+			os.Exit(1)
+		}
+	},
+}
+
+var apiKeyRevokeCmd = &cobra.Command{
+	Use:   "revoke <name>",
+	Short: "Revoke API key",
+	Long:  `Revoke API key`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := RevokeAPIKey(args[0]); err != nil {
+			slog.Error("error", slog.Any("error", err))
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(apiKeyCmd)
+	apiKeyCmd.AddCommand(apiKeyCreateCmd)
+	apiKeyCmd.AddCommand(apiKeyListCmd)
+	apiKeyCmd.AddCommand(apiKeyRevokeCmd)
+
+	apiKeyCreateCmd.Flags().StringVar(
+		&apiKeyScopes,
+		"scopes",
+		"",
+		"Comma separated scopes (read, register, extend, delete, admin)",
+	)
+	apiKeyCreateCmd.Flags().StringVar(
+		&apiKeyTTL, "ttl", "", "Key lifetime (e.g. 90d), no expiry if empty",
+	)
+
+	if err := apiKeyCreateCmd.MarkFlagRequired("scopes"); err != nil {
+		slog.Error("error", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+func openAPIKeyService() (*service.APIKeyService, func(), error) {
+	serverCfg, err := config.NewServerConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading configuration: %w", err)
+	}
+
+	st, err := storage.New(serverCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return service.NewAPIKeyService(st), func() { _ = st.Close() }, nil
+}
+
+func CreateAPIKey(name string) error {
+	svc, closeFn, err := openAPIKeyService()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	key, token, err := svc.CreateAPIKey(
+		context.Background(), name, strings.Split(apiKeyScopes, ","), apiKeyTTL,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	fmt.Printf("API key %s created with scopes %s, expires: %s\n",
+		key.Name, strings.Join(key.Scopes, ","), formatUnix(key.ExpiresAt, "never"))
+	fmt.Printf("Key (shown only once): %s\n", token)
+
+	return nil
+}
+
+func ListAPIKeys() error {
+	svc, closeFn, err := openAPIKeyService()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	keys, err := svc.GetAPIKeys(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to list api keys: %w", err)
+	}
+
+	now := time.Now().Unix()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "Name\tScopes\tCreatedAt\tExpiresAt\tStatus")
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			key.Name, strings.Join(key.Scopes, ","),
+			formatUnix(key.CreatedAt, ""), formatUnix(key.ExpiresAt, "never"),
+			apiKeyStatus(key, now))
+	}
+	_ = w.Flush()
+
+	return nil
+}
+
+func RevokeAPIKey(name string) error {
+	svc, closeFn, err := openAPIKeyService()
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	if err := svc.RevokeAPIKey(context.Background(), name); err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	fmt.Printf("API key %s revoked\n", name)
+
+	return nil
+}
+
+func apiKeyStatus(key *model.APIKey, now int64) string {
+	switch {
+	case key.RevokedAt != 0:
+		return "revoked " + formatUnix(key.RevokedAt, "")
+	case !key.Active(now):
+		return "expired"
+	default:
+		return "active"
+	}
+}
+
+// formatUnix formats unix time t for table output, or returns zero if t is
+// not set.
+func formatUnix(t int64, zero string) string {
+	if t == 0 {
+		return zero
+	}
+	return time.Unix(t, 0).Format("02-01-06 15:04:05")
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fragpit/env-cleaner/pkg/client"
+)
+
+var (
+	auditEnvID  string
+	auditAction string
+	auditActor  string
+	auditSource string
+	auditOwner  string
+	auditSince  string
+	auditUntil  string
+	auditLimit  int
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the audit log",
+	Long:  `Show the audit log of environment lifecycle actions, newest first`,
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := Audit(cmd.Context()); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().StringVar(&auditEnvID, "env-id", "", "Environment ID")
+	auditCmd.Flags().StringVar(
+		&auditAction,
+		"action",
+		"",
+		"Action (create, discover, update, extend, warn, delete, delete_failed, "+
+			"gone, reappear, protect, unprotect, delete_now, forget)",
+	)
+	auditCmd.Flags().StringVar(&auditActor, "actor", "", "Actor")
+	auditCmd.Flags().StringVar(
+		&auditSource,
+		"source",
+		"",
+		"Source (api, extend_page, crawler, deleter, webhook)",
+	)
+	auditCmd.Flags().StringVar(
+		&auditOwner, "owner", "", "Owner of the environments",
+	)
+	auditCmd.Flags().StringVar(
+		&auditSince,
+		"since",
+		"",
+		"Show entries since a period ago (e.g. 7d) or an RFC 3339 time",
+	)
+	auditCmd.Flags().StringVar(
+		&auditUntil,
+		"until",
+		"",
+		"Show entries until a period ago (e.g. 1d) or an RFC 3339 time",
+	)
+	auditCmd.Flags().IntVar(
+		&auditLimit, "limit", 0, "Maximum number of entries (default 100)",
+	)
+	addOutputFlag(auditCmd)
+}
+
+func Audit(ctx context.Context) error {
+	p, err := newPrinter(outputFormat)
+	if err != nil {
+		return err
+	}
+
+	entries, err := apiClient.GetAuditEntries(ctx, &client.AuditOptions{
+		EnvID:  auditEnvID,
+		Action: auditAction,
+		Actor:  auditActor,
+		Source: auditSource,
+		Owner:  auditOwner,
+		Since:  auditSince,
+		Until:  auditUntil,
+		Limit:  auditLimit,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get audit log: %w", err)
+	}
+
+	if entries == nil {
+		entries = []client.AuditEntry{}
+	}
+
+	return p.print(entries, func(w io.Writer, wide bool) {
+		_, _ = fmt.Fprintln(w, strings.Join(auditRow(wide,
+			"Time", "Action", "Actor", "Source", "ID", "Type", "Name",
+			"OldDeleteAt", "NewDeleteAt", "Details"), "\t"))
+		for _, e := range entries {
+			_, _ = fmt.Fprintln(w, strings.Join(auditRow(wide,
+				e.CreatedAt, e.Action, e.Actor, e.Source, e.EnvID, e.EnvType,
+				e.EnvName, e.OldDeleteAt, e.NewDeleteAt, e.Details), "\t"))
+		}
+	})
+}
+
+// auditRow returns the columns of an audit table row. The environment type
+// is only shown in wide tables.
+func auditRow(
+	wide bool,
+	createdAt, action, actor, source, envID, envType, envName,
+	oldDeleteAt, newDeleteAt, details string,
+) []string {
+	row := []string{createdAt, action, actor, source, envID}
+	if wide {
+		row = append(row, envType)
+	}
+
+	return append(row, envName, oldDeleteAt, newDeleteAt, details)
+}
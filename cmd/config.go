@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fragpit/env-cleaner/internal/config"
+)
+
+var configCmd = &cobra.Command{
+	Use:         "config",
+	Short:       "Server configuration",
+	Long:        `Config command group works with the server configuration file`,
+	Annotations: map[string]string{serverConfigAnnotation: ""},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the server configuration",
+	Long: `Validate the server configuration the same way the server does on start:
+parse every duration, compile the regular expressions and check the settings
+the enabled connectors, notifications and listeners require. All problems are
+reported at once with the keys of the invalid settings.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := ValidateConfig(); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+}
+
+func ValidateConfig() error {
+	serverCfg, err := config.NewServerConfig()
+	if err != nil {
+		return fmt.Errorf("error reading configuration: %w", err)
+	}
+
+	err = serverCfg.Validate()
+	var ve *config.ValidationError
+	if errors.As(err, &ve) {
+		for _, p := range ve.Problems {
+			fmt.Println(p)
+		}
+		return &usageError{
+			msg: fmt.Sprintf("%d configuration problems found", len(ve.Problems)),
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Configuration is valid")
+	return nil
+}
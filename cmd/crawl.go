@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fragpit/env-cleaner/internal/server"
+)
+
+var crawlConnectors []string
+
+var crawlCmd = &cobra.Command{
+	Use:   "crawl",
+	Short: "Run the crawlers once",
+	Long: `Run a single crawl of the enabled connectors, or of the ones given with
+--connector, and exit. It reads the server configuration and is meant for
+running env-cleaner from cron instead of the server. It exits non-zero if any
+crawl failed.`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{serverConfigAnnotation: ""},
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := Crawl(); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(crawlCmd)
+
+	crawlCmd.Flags().StringSliceVar(
+		&crawlConnectors,
+		"connector",
+		nil,
+		"Connector to crawl (helm, vsphere_vm), can be repeated",
+	)
+}
+
+func Crawl() error {
+	results, err := server.Crawl(crawlConnectors)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w,
+		"Type\tFound\tDiscovered\tUpdated\tGone\tReappeared\tRemoved\tError")
+	for _, res := range results {
+		sum := res.Summary
+		errMsg := ""
+		if res.Err != nil {
+			errMsg = truncate(res.Err.Error(), 60)
+			failed++
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			sum.Type, sum.Found, sum.Discovered, sum.Updated, sum.Gone,
+			sum.Reappeared, sum.Removed, errMsg)
+	}
+	_ = w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d crawls failed", failed, len(results))
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fragpit/env-cleaner/internal/config"
+	"github.com/fragpit/env-cleaner/internal/storage"
+	"github.com/fragpit/env-cleaner/internal/storage/migrations"
+)
+
+var dbDryRun bool
+
+var dbCmd = &cobra.Command{
+	Use:         "db",
+	Short:       "Database maintenance",
+	Long:        `Database command group manages the server database schema`,
+	Annotations: map[string]string{serverConfigAnnotation: ""},
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending schema migrations",
+	Long: `Apply pending schema migrations. The server applies them automatically
+on start, this command allows to upgrade the schema ahead of a deploy.`,
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := Migrate(dbDryRun); err != nil {
+			slog.Error("error", slog.Any("error", err))
+			os.Exit(1)
+		}
+	},
+}
+
+var dbStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show schema migrations status",
+	Long:  `Show schema migrations status`,
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := MigrationStatus(); err != nil {
+			slog.Error("error", slog.Any("error", err))
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbMigrateCmd)
+	dbCmd.AddCommand(dbStatusCmd)
+
+	dbMigrateCmd.Flags().BoolVar(
+		&dbDryRun, "dry-run", false, "Only list migrations that would be applied",
+	)
+}
+
+func openMigrator(create bool) (*migrations.Migrator, func(), error) {
+	serverCfg, err := config.NewServerConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading configuration: %w", err)
+	}
+
+	open := storage.Open
+	if create {
+		open = storage.Create
+	}
+
+	st, err := open(serverCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	closeFn := func() { _ = st.Close() }
+
+	m, err := st.Migrator()
+	if err != nil {
+		closeFn()
+		return nil, nil, err
+	}
+
+	return m, closeFn, nil
+}
+
+func Migrate(dryRun bool) error {
+	m, closeFn, err := openMigrator(!dryRun)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	ctx := context.Background()
+
+	if dryRun {
+		pending, err := m.Pending(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(pending) == 0 {
+			fmt.Println("Schema is up to date")
+			return nil
+		}
+
+		for _, mg := range pending {
+			fmt.Printf("Would apply %s\n", mg)
+		}
+		return nil
+	}
+
+	applied, err := m.Up(ctx)
+	for _, mg := range applied {
+		fmt.Printf("Applied %s\n", mg)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		fmt.Println("Schema is up to date")
+	}
+
+	return nil
+}
+
+func MigrationStatus() error {
+	m, closeFn, err := openMigrator(false)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "Version\tName\tStatus\tAppliedAt")
+	for _, st := range statuses {
+		status, appliedAt := "pending", ""
+		if st.Applied {
+			status = "applied"
+			appliedAt = st.AppliedAt.Format("02-01-06 15:04:05")
+		}
+		_, _ = fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n",
+			st.Version, st.Name, status, appliedAt)
+	}
+	_ = w.Flush()
+
+	return nil
+}
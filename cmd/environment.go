@@ -6,10 +6,6 @@ import (
 	"github.com/spf13/cobra"
 )
 
-const (
-	apiEnvironmentsEndpoint = "/api/environments"
-)
-
 var envCmd = &cobra.Command{
 	Use:     "environment",
 	Aliases: []string{"env"},
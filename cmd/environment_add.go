@@ -1,20 +1,13 @@
 package cmd
 
 import (
-	"bytes"
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"net/http"
-	"net/url"
-	"path"
-
 	"log/slog"
 	"os"
 
 	"github.com/spf13/cobra"
 
-	"github.com/fragpit/env-cleaner/internal/api"
+	"github.com/fragpit/env-cleaner/pkg/client"
 )
 
 var (
@@ -23,6 +16,7 @@ var (
 	envNamespace string
 	envType      string
 	envTTL       string
+	envLabels    map[string]string
 )
 
 var addCmd = &cobra.Command{
@@ -32,8 +26,7 @@ var addCmd = &cobra.Command{
 	Long:    `Add environment`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := Add(cmd, args); err != nil {
-			slog.Error("error", slog.Any("error", err))
-			os.Exit(1)
+			exitWithError(err)
 		}
 	},
 }
@@ -49,6 +42,9 @@ func init() {
 		StringVarP(&envType, "type", "t", "", "Environment type (vsphere_vm, helm)")
 	addCmd.Flags().
 		StringVarP(&envTTL, "ttl", "", "", "Time to live for the environment")
+	addCmd.Flags().StringToStringVar(
+		&envLabels, "label", nil, "Environment labels (e.g. team=qa,env=dev)",
+	)
 
 	if err := addCmd.MarkFlagRequired("name"); err != nil {
 		slog.Error("error", slog.Any("error", err))
@@ -69,73 +65,23 @@ func init() {
 }
 
 // TODO: rework empty params
-func Add(_ *cobra.Command, _ []string) error {
-	env := api.EnvironmentRequest{
+func Add(cmd *cobra.Command, _ []string) error {
+	env := client.EnvironmentRequest{
 		Name:      envName,
 		Namespace: envNamespace,
 		Owner:     envOwner,
 		Type:      envType,
 		TTL:       envTTL,
+		Labels:    envLabels,
 	}
 
 	if env.Type == "helm" && env.Namespace == "" {
 		return fmt.Errorf("namespace parameter is required for helm type")
 	}
 
-	baseURL, err := url.Parse(cfg.APIURL)
-	if err != nil {
-		return fmt.Errorf("error parsing url: %w", err)
-	}
-
-	baseURL.Path = path.Join(baseURL.Path, apiEnvironmentsEndpoint)
-	fullURL := baseURL.String()
-
-	jsonData, err := json.Marshal(env)
-	if err != nil {
-		return fmt.Errorf("error marshaling json: %w", err)
-	}
-
-	req, err := http.NewRequest(
-		http.MethodPost,
-		fullURL,
-		bytes.NewBuffer(jsonData),
-	)
+	envResp, err := apiClient.AddEnvironment(cmd.Context(), &env)
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
-	}
-
-	encodedAPIKey := base64.StdEncoding.EncodeToString([]byte(cfg.AdminAPIKey))
-	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", encodedAPIKey))
-	req.Method = http.MethodPost
-	req.Header.Set("Content-Type", "application/json")
-
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("error sending request: %w", err)
-	}
-	defer func() { _ = res.Body.Close() }()
-
-	var resp api.Response
-	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
-		return fmt.Errorf("error decoding response: %w", err)
-	}
-
-	if !resp.Success {
-		return fmt.Errorf(
-			"failed to add environment: %s (code: %d)",
-			resp.Error.Message,
-			resp.Error.Code,
-		)
-	}
-
-	var envResp api.EnvironmentResponse
-	data, err := json.Marshal(resp.Data)
-	if err != nil {
-		return fmt.Errorf("error decoding response: %w", err)
-	}
-
-	if err := json.Unmarshal(data, &envResp); err != nil {
-		return fmt.Errorf("error decoding response: %w", err)
+		return fmt.Errorf("failed to add environment: %w", err)
 	}
 
 	fmt.Printf(
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fragpit/env-cleaner/pkg/client"
+)
+
+var (
+	batchAction    string
+	batchIDs       []string
+	batchOwner     string
+	batchNamespace string
+	batchType      string
+	batchNameRegex string
+	batchLabel     string
+	batchPeriod    string
+	batchDeleteAt  string
+	batchIgnoreMax bool
+	batchReason    string
+	batchNewOwner  string
+	batchDryRun    bool
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Apply an action to several environments",
+	Long: `Apply an action to the environments listed with --ids or matched by
+--owner, --namespace, --type, --name-regex and --label. Actions are extend (--period),
+set-expiry (--delete-at), delete-now, protect (--period, --reason) and
+change-owner (--new-owner). Use --dry-run to preview the matches.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := Batch(cmd.Context()); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	envCmd.AddCommand(batchCmd)
+
+	batchCmd.Flags().StringVar(
+		&batchAction,
+		"action",
+		"",
+		"Action (extend, set-expiry, delete-now, protect, change-owner)",
+	)
+	batchCmd.Flags().StringSliceVar(
+		&batchIDs, "ids", nil, "Comma separated environment IDs",
+	)
+	batchCmd.Flags().StringVar(&batchOwner, "owner", "", "Select by owner")
+	batchCmd.Flags().StringVar(
+		&batchNamespace, "namespace", "", "Select by namespace",
+	)
+	batchCmd.Flags().StringVar(
+		&batchType, "type", "", "Select by type (helm, vsphere_vm)",
+	)
+	batchCmd.Flags().StringVar(
+		&batchNameRegex,
+		"name-regex",
+		"",
+		"Select by a regular expression matching the name (e.g. ^hack-)",
+	)
+	batchCmd.Flags().StringVar(
+		&batchLabel,
+		"label",
+		"",
+		"Select by labels, a bare key matches any value (e.g. team=qa,critical)",
+	)
+	batchCmd.Flags().StringVar(
+		&batchPeriod,
+		"period",
+		"",
+		"Extension or protection period (e.g. 3d, 1w)",
+	)
+	batchCmd.Flags().StringVar(
+		&batchDeleteAt,
+		"delete-at",
+		"",
+		`Deletion date in the "02-01-06 15:04:05" format`,
+	)
+	batchCmd.Flags().BoolVar(
+		&batchIgnoreMax,
+		"ignore-max",
+		false,
+		"Ignore the max_extend_duration server limit",
+	)
+	batchCmd.Flags().StringVar(
+		&batchReason, "reason", "", "Reason for the protection",
+	)
+	batchCmd.Flags().StringVar(
+		&batchNewOwner, "new-owner", "", "Owner to change to",
+	)
+	batchCmd.Flags().BoolVar(
+		&batchDryRun,
+		"dry-run",
+		false,
+		"Show the matched environments without changing them",
+	)
+	_ = batchCmd.MarkFlagRequired("action")
+	batchCmd.MarkFlagsMutuallyExclusive("ids", "owner")
+	batchCmd.MarkFlagsMutuallyExclusive("ids", "namespace")
+	batchCmd.MarkFlagsMutuallyExclusive("ids", "type")
+	batchCmd.MarkFlagsMutuallyExclusive("ids", "name-regex")
+	batchCmd.MarkFlagsMutuallyExclusive("ids", "label")
+}
+
+func Batch(ctx context.Context) error {
+	req := &client.BatchRequest{
+		IDs:       batchIDs,
+		Action:    batchAction,
+		Period:    batchPeriod,
+		DeleteAt:  batchDeleteAt,
+		IgnoreMax: batchIgnoreMax,
+		Reason:    batchReason,
+		Owner:     batchNewOwner,
+	}
+	if len(batchIDs) == 0 {
+		req.Selector = &client.BatchSelector{
+			Owner:     batchOwner,
+			Namespace: batchNamespace,
+			Type:      batchType,
+			NameRegex: batchNameRegex,
+			Label:     batchLabel,
+		}
+	}
+
+	resp, err := apiClient.BatchEnvironments(ctx, req, batchDryRun)
+	if err != nil {
+		return fmt.Errorf("failed to apply batch action: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "Owner\tID\tName\tType\tDeleteAt\tResult\tError")
+	for _, item := range resp.Items {
+		env := item.Environment
+		if env == nil {
+			env = &client.Environment{EnvID: item.EnvID}
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			env.Owner, env.EnvID, env.Name, env.Type, env.DeleteAt,
+			item.Result, truncate(item.Error, 60))
+	}
+	_ = w.Flush()
+
+	if resp.DryRun {
+		fmt.Printf("\nDry run: %s would apply to %d environments\n",
+			resp.Action, resp.Matched)
+	} else {
+		fmt.Printf("\n%s: %d succeeded, %d failed\n",
+			resp.Action, resp.Succeeded, resp.Failed)
+	}
+
+	if resp.Failed > 0 {
+		return fmt.Errorf("%d environments failed", resp.Failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var deleteForget bool
+
+var deleteCmd = &cobra.Command{
+	Use:     "delete <env_id>",
+	Aliases: []string{"rm"},
+	Short:   "Delete environment",
+	Long: `Schedule an immediate deletion of an environment by the deleter. With
+--forget the environment is removed from the database without being deleted.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := Delete(cmd.Context(), args[0]); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	envCmd.AddCommand(deleteCmd)
+
+	deleteCmd.Flags().BoolVar(
+		&deleteForget,
+		"forget",
+		false,
+		"Remove from the database without deleting the resources",
+	)
+}
+
+func Delete(ctx context.Context, envID string) error {
+	env, err := apiClient.DeleteEnvironment(ctx, envID, deleteForget)
+	if err != nil {
+		return fmt.Errorf("failed to delete environment: %w", err)
+	}
+
+	if deleteForget {
+		fmt.Printf(
+			"Environment: %s id: %s type: %s forgotten\n",
+			env.Name, env.EnvID, env.Type,
+		)
+		return nil
+	}
+
+	fmt.Printf(
+		"Environment: %s id: %s type: %s scheduled for deletion\n",
+		env.Name, env.EnvID, env.Type,
+	)
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fragpit/env-cleaner/pkg/client"
+)
+
+var (
+	extendPeriod    string
+	extendDeleteAt  string
+	extendIgnoreMax bool
+	expiryAt        string
+)
+
+var extendCmd = &cobra.Command{
+	Use:   "extend <env_id>",
+	Short: "Extend environment",
+	Long: `Extend environment by a period as admin, without a token from a stale
+notification`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := Extend(cmd.Context(), args[0]); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+var setExpiryCmd = &cobra.Command{
+	Use:   "set-expiry <env_id>",
+	Short: "Set environment deletion date",
+	Long: `Set environment deletion date as admin, without a token from a stale
+notification`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := SetExpiry(cmd.Context(), args[0]); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	envCmd.AddCommand(extendCmd)
+	envCmd.AddCommand(setExpiryCmd)
+
+	extendCmd.Flags().StringVar(
+		&extendPeriod, "period", "", "Extension period (e.g. 3d, 1w)",
+	)
+	extendCmd.Flags().StringVar(
+		&extendDeleteAt,
+		"delete-at",
+		"",
+		`Deletion date in the "02-01-06 15:04:05" format`,
+	)
+	_ = extendCmd.Flags().MarkDeprecated("delete-at", "use set-expiry --at")
+	extendCmd.MarkFlagsOneRequired("period", "delete-at")
+	extendCmd.MarkFlagsMutuallyExclusive("period", "delete-at")
+
+	setExpiryCmd.Flags().StringVar(
+		&expiryAt, "at", "", `Deletion date in the "02-01-06 15:04:05" format`,
+	)
+	_ = setExpiryCmd.MarkFlagRequired("at")
+
+	for _, c := range []*cobra.Command{extendCmd, setExpiryCmd} {
+		c.Flags().BoolVar(
+			&extendIgnoreMax,
+			"ignore-max",
+			false,
+			"Ignore the max_extend_duration server limit",
+		)
+	}
+}
+
+func Extend(ctx context.Context, envID string) error {
+	return extendEnvironment(ctx, envID, &client.ExtendRequest{
+		Period:    extendPeriod,
+		DeleteAt:  extendDeleteAt,
+		IgnoreMax: extendIgnoreMax,
+	})
+}
+
+func SetExpiry(ctx context.Context, envID string) error {
+	return extendEnvironment(ctx, envID, &client.ExtendRequest{
+		DeleteAt:  expiryAt,
+		IgnoreMax: extendIgnoreMax,
+	})
+}
+
+func extendEnvironment(
+	ctx context.Context,
+	envID string,
+	req *client.ExtendRequest,
+) error {
+	env, err := apiClient.ExtendEnvironment(ctx, envID, req)
+	if err != nil {
+		return fmt.Errorf("failed to extend environment: %w", err)
+	}
+
+	fmt.Printf(
+		"Environment: %s id: %s type: %s extended, delete at: %s\n",
+		env.Name, env.EnvID, env.Type, env.DeleteAt,
+	)
+
+	return nil
+}
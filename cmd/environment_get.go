@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fragpit/env-cleaner/pkg/client"
+)
+
+var getCmd = &cobra.Command{
+	Use:   "get <env_id>",
+	Short: "Show environment",
+	Long:  `Show environment in any status`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := Get(cmd.Context(), args[0]); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	envCmd.AddCommand(getCmd)
+
+	addOutputFlag(getCmd)
+}
+
+func Get(ctx context.Context, envID string) error {
+	p, err := newPrinter(outputFormat)
+	if err != nil {
+		return err
+	}
+
+	env, err := apiClient.GetEnvironment(ctx, envID)
+	if err != nil {
+		return fmt.Errorf("failed to get environment: %w", err)
+	}
+
+	return p.print(env, func(w io.Writer, _ bool) {
+		printEnvironment(w, env)
+	})
+}
+
+// printEnvironment writes the fields of env that are set, one per line.
+func printEnvironment(w io.Writer, env *client.Environment) {
+	failures := ""
+	if env.FailureCount > 0 {
+		failures = strconv.Itoa(env.FailureCount)
+	}
+
+	for _, field := range []struct{ name, value string }{
+		{"ID", env.EnvID},
+		{"Name", env.Name},
+		{"Type", env.Type},
+		{"Namespace", env.Namespace},
+		{"Owner", env.Owner},
+		{"Status", env.Status},
+		{"StatusChangedAt", env.StatusChangedAt},
+		{"DeleteAt", env.DeleteAt},
+		{"ExpiresIn", expiresIn(env)},
+		{"DeleteAtSource", env.DeleteAtSource},
+		{"TTL", env.TTL},
+		{"Protected", protectedColumn(env)},
+		{"ProtectedBy", env.ProtectedBy},
+		{"ProtectedReason", env.ProtectedReason},
+		{"Labels", formatLabels(env.Labels)},
+		{"Failures", failures},
+		{"NextAttempt", env.NextAttemptAt},
+		{"LastError", env.LastError},
+	} {
+		if field.value != "" {
+			_, _ = fmt.Fprintf(w, "%s:\t%s\n", field.name, field.value)
+		}
+	}
+}
@@ -1,20 +1,15 @@
 package cmd
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
-	"net/url"
-	"os"
-	"path"
-	"text/tabwriter"
-
-	"log/slog"
+	"io"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 
-	"github.com/fragpit/env-cleaner/internal/api"
+	"github.com/fragpit/env-cleaner/pkg/client"
 )
 
 var listCmd = &cobra.Command{
@@ -23,71 +18,219 @@ var listCmd = &cobra.Command{
 	Short:   "List environments",
 	Long:    `List environments`,
 	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
-		if err := List(); err != nil {
-			slog.Error("error", slog.Any("error", err))
-			os.Exit(1)
+		if err := List(cmd.Context()); err != nil {
+			exitWithError(err)
 		}
 	},
 }
 
+var (
+	listStatus       string
+	listOwner        string
+	listType         string
+	listNamespace    string
+	listName         string
+	listLabel        string
+	listDeleteBefore string
+	listDeleteAfter  string
+	listExpiring     string
+	listExpired      bool
+	listProtected    bool
+	listSort         string
+	listLimit        int
+)
+
+// listPageSize is the page size used to fetch every environment.
+const listPageSize = 1000
+
 func init() {
 	envCmd.AddCommand(listCmd)
+
+	listCmd.Flags().StringVar(
+		&listStatus,
+		"status",
+		"",
+		"Comma separated statuses to list (active, warned, deleting, deleted, failed, gone)",
+	)
+	listCmd.Flags().StringVar(&listOwner, "owner", "", "Owner")
+	listCmd.Flags().StringVar(&listType, "type", "", "Type (helm, vsphere_vm)")
+	listCmd.Flags().StringVar(&listNamespace, "namespace", "", "Namespace")
+	listCmd.Flags().StringVar(
+		&listName, "name", "", "Name glob pattern (e.g. feature-*)",
+	)
+	listCmd.Flags().StringVar(
+		&listLabel,
+		"label",
+		"",
+		"Label selector, a bare key matches any value (e.g. team=qa,critical)",
+	)
+	listCmd.Flags().StringVar(
+		&listDeleteBefore,
+		"delete-before",
+		"",
+		"List environments deleted before a period from now (e.g. 2d) or an RFC 3339 time",
+	)
+	listCmd.Flags().StringVar(
+		&listDeleteAfter,
+		"delete-after",
+		"",
+		"List environments deleted after a period from now (e.g. 2d) or an RFC 3339 time",
+	)
+	listCmd.Flags().StringVar(
+		&listExpiring,
+		"expiring-within",
+		"",
+		"List environments to be deleted within a period from now (e.g. 24h)",
+	)
+	listCmd.Flags().BoolVar(
+		&listExpired,
+		"expired",
+		false,
+		"List environments whose deletion date has passed",
+	)
+	listCmd.Flags().BoolVar(
+		&listProtected, "protected", false, "List protected environments",
+	)
+	listCmd.Flags().StringVar(
+		&listSort,
+		"sort-by",
+		"",
+		"Sort field (env_id, name, owner, type, namespace, delete_at), descending with a leading -",
+	)
+	listCmd.Flags().IntVar(
+		&listLimit, "limit", 0, "Maximum number of environments (default all)",
+	)
+	listCmd.MarkFlagsMutuallyExclusive("expiring-within", "delete-before")
+	listCmd.MarkFlagsMutuallyExclusive("expiring-within", "delete-after")
+	listCmd.MarkFlagsMutuallyExclusive("expired", "delete-before")
+	listCmd.MarkFlagsMutuallyExclusive("expired", "expiring-within")
+	addOutputFlag(listCmd)
 }
 
-func List() error {
-	baseURL, err := url.Parse(cfg.APIURL)
+func List(ctx context.Context) error {
+	p, err := newPrinter(outputFormat)
 	if err != nil {
-		return fmt.Errorf("error parsing url: %w", err)
+		return err
 	}
 
-	baseURL.Path = path.Join(baseURL.Path, apiEnvironmentsEndpoint)
-	fullURL := baseURL.String()
+	opts := &client.ListOptions{
+		Owner:        listOwner,
+		Type:         listType,
+		Namespace:    listNamespace,
+		Name:         listName,
+		Label:        listLabel,
+		DeleteBefore: listDeleteBefore,
+		DeleteAfter:  listDeleteAfter,
+		Sort:         listSort,
+		Limit:        listPageSize,
+	}
+	if listStatus != "" {
+		opts.Statuses = strings.Split(listStatus, ",")
+	}
+	// Environments whose deletion date has passed are not expiring.
+	if listExpiring != "" {
+		opts.DeleteBefore = listExpiring
+		opts.DeleteAfter = "0s"
+	}
+	if listExpired {
+		opts.DeleteBefore = "0s"
+	}
 
-	req, err := http.NewRequest(http.MethodGet, fullURL, http.NoBody)
-	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+	// Without --limit every page is fetched. Pages filtered on the client
+	// are fetched in full.
+	if listLimit > 0 && listLimit < opts.Limit && !listProtected {
+		opts.Limit = listLimit
 	}
 
-	encodedAPIKey := base64.StdEncoding.EncodeToString([]byte(cfg.AdminAPIKey))
-	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", encodedAPIKey))
+	var environments []client.Environment
+	for {
+		page, err := apiClient.ListEnvironments(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("failed to list environments: %w", err)
+		}
+		// The API has no protection filter, protected environments are
+		// picked out of each page.
+		for _, env := range page.Environments {
+			if listProtected && !env.Protected {
+				continue
+			}
+			environments = append(environments, env)
+		}
 
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("error sending request: %w", err)
+		if page.NextCursor == "" ||
+			(listLimit > 0 && len(environments) >= listLimit) {
+			break
+		}
+		opts.Cursor = page.NextCursor
 	}
-	defer func() { _ = res.Body.Close() }()
-
-	var resp api.Response
-	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
-		return fmt.Errorf("error decoding response: %w", err)
+	if listLimit > 0 && len(environments) > listLimit {
+		environments = environments[:listLimit]
 	}
-
-	if !resp.Success {
-		return fmt.Errorf(
-			"failed to list environments: %s (code: %d)",
-			resp.Error.Message,
-			resp.Error.Code,
-		)
+	// Empty lists are printed as [] rather than null.
+	if environments == nil {
+		environments = []client.Environment{}
 	}
 
-	var environments []api.EnvironmentResponse
+	return p.print(environments, func(w io.Writer, wide bool) {
+		printEnvironmentTable(w, environments, wide)
+	})
+}
 
-	data, err := json.Marshal(resp.Data)
-	if err != nil {
-		return fmt.Errorf("error decoding response: %w", err)
+// printEnvironmentTable writes environments as a table. Wide adds the
+// deletion attempts.
+func printEnvironmentTable(
+	w io.Writer,
+	environments []client.Environment,
+	wide bool,
+) {
+	header := "Owner\tID\tName\tNamespace\tType\tDeleteAt\tExpiresIn\tStatus\tProtected"
+	if wide {
+		header += "\tTTL\tDeleteAtSource\tLabels\tFailures\tNextAttempt\tLastError"
 	}
+	_, _ = fmt.Fprintln(w, header)
 
-	if err := json.Unmarshal(data, &environments); err != nil {
-		return fmt.Errorf("error decoding response: %w", err)
+	for _, env := range environments {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s",
+			env.Owner, env.EnvID, env.Name, env.Namespace, env.Type,
+			env.DeleteAt, expiresIn(&env), env.Status, protectedColumn(&env))
+		if wide {
+			_, _ = fmt.Fprintf(w, "\t%s\t%s\t%s\t%d\t%s\t%s",
+				env.TTL, env.DeleteAtSource, formatLabels(env.Labels),
+				env.FailureCount,
+				env.NextAttemptAt, truncate(env.LastError, 60))
+		}
+		_, _ = fmt.Fprintln(w)
 	}
+}
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	_, _ = fmt.Fprintln(w, "Owner\tID\tName\tType\tDeleteAt")
-	for _, env := range environments {
-		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
-			env.Owner, env.EnvID, env.Name, env.Type, env.DeleteAt)
+// protectedColumn describes the protection of env for table output.
+func protectedColumn(env *client.Environment) string {
+	switch {
+	case !env.Protected:
+		return ""
+	case env.ProtectedUntil != "":
+		return "until " + env.ProtectedUntil
+	default:
+		return "yes"
+	}
+}
+
+// formatLabels writes labels as key=value pairs sorted by key for table
+// output.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
 	}
-	_ = w.Flush()
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
 
-	return nil
+// truncate shortens s to at most n runes for table output.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
 }
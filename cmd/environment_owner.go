@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fragpit/env-cleaner/pkg/client"
+)
+
+var setOwnerCmd = &cobra.Command{
+	Use:   "set-owner <env_id> <owner>",
+	Short: "Change environment owner",
+	Long: `Change environment owner. For environments discovered by the crawler,
+an owner different from the connector metadata is reset on the next crawl.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := SetOwner(cmd.Context(), args[0], args[1]); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	envCmd.AddCommand(setOwnerCmd)
+}
+
+func SetOwner(ctx context.Context, envID, owner string) error {
+	env, err := apiClient.UpdateEnvironment(
+		ctx, envID, &client.UpdateEnvironmentRequest{Owner: owner},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to change environment owner: %w", err)
+	}
+
+	fmt.Printf(
+		"Environment: %s id: %s type: %s owner changed to %s\n",
+		env.Name, env.EnvID, env.Type, env.Owner,
+	)
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fragpit/env-cleaner/pkg/client"
+)
+
+var (
+	protectPeriod string
+	protectReason string
+)
+
+var protectCmd = &cobra.Command{
+	Use:   "protect <env_id>",
+	Short: "Protect environment from deletion",
+	Long: `Protect environment from deletion. Without --period the protection
+does not expire.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := Protect(cmd.Context(), args[0]); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+var unprotectCmd = &cobra.Command{
+	Use:   "unprotect <env_id>",
+	Short: "Remove environment protection",
+	Long:  `Remove environment protection`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := Unprotect(cmd.Context(), args[0]); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	envCmd.AddCommand(protectCmd)
+	envCmd.AddCommand(unprotectCmd)
+
+	protectCmd.Flags().StringVar(
+		&protectPeriod, "period", "", "Protection period (e.g. 1d, 2w)",
+	)
+	protectCmd.Flags().StringVar(
+		&protectReason, "reason", "", "Reason for the protection",
+	)
+}
+
+func Protect(ctx context.Context, envID string) error {
+	env, err := apiClient.ProtectEnvironment(ctx, envID, &client.ProtectRequest{
+		Period: protectPeriod,
+		Reason: protectReason,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to protect environment: %w", err)
+	}
+
+	until := "no expiry"
+	if env.ProtectedUntil != "" {
+		until = "until " + env.ProtectedUntil
+	}
+	fmt.Printf(
+		"Environment: %s id: %s type: %s protected, %s\n",
+		env.Name, env.EnvID, env.Type, until,
+	)
+
+	return nil
+}
+
+func Unprotect(ctx context.Context, envID string) error {
+	env, err := apiClient.UnprotectEnvironment(ctx, envID)
+	if err != nil {
+		return fmt.Errorf("failed to unprotect environment: %w", err)
+	}
+
+	fmt.Printf(
+		"Environment: %s id: %s type: %s unprotected\n",
+		env.Name, env.EnvID, env.Type,
+	)
+
+	return nil
+}
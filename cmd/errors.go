@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+
+	"github.com/fragpit/env-cleaner/pkg/client"
+)
+
+// Exit codes of the client commands.
+const (
+	// exitError is returned for failures other than the ones below, such
+	// as unreachable or failing servers.
+	exitError = 1
+	// exitUsage is returned for invalid arguments and rejected requests.
+	exitUsage = 2
+	// exitDenied is returned for missing or insufficient credentials.
+	exitDenied = 3
+	// exitNotFound is returned for unknown environments.
+	exitNotFound = 4
+	// exitConflict is returned for environments in a state that does not
+	// allow the change, such as protected or deleted ones.
+	exitConflict = 5
+)
+
+// usageError is an error in the arguments of a command found before any
+// request is sent.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// exitCode returns the exit code for err.
+func exitCode(err error) int {
+	var ue *usageError
+	switch {
+	case errors.As(err, &ue), errors.Is(err, client.ErrBadRequest):
+		return exitUsage
+	case errors.Is(err, client.ErrUnauthorized),
+		errors.Is(err, client.ErrForbidden):
+		return exitDenied
+	case errors.Is(err, client.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, client.ErrConflict):
+		return exitConflict
+	}
+
+	return exitError
+}
+
+// exitWithError logs err and exits with its exit code.
+func exitWithError(err error) {
+	slog.Error("error", slog.Any("error", err))
+	os.Exit(exitCode(err))
+}
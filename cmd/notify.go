@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fragpit/env-cleaner/internal/server"
+)
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Warn the owners of stale environments once",
+	Long: `Warn the owners of environments that will be deleted within the stale
+threshold and exit, without deleting anything. It reads the server
+configuration and is meant for running env-cleaner from cron instead of the
+server. It exits non-zero if any notification failed.`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{serverConfigAnnotation: ""},
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := Notify(); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(notifyCmd)
+}
+
+func Notify() error {
+	sum, err := server.Notify()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Warned: %d\n", sum.Warned)
+	fmt.Printf("Errors: %d\n", sum.Errors)
+
+	return summaryError(sum)
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+
+	"github.com/fragpit/env-cleaner/pkg/client"
+)
+
+// Output formats of the read commands. jsonpath and go-template take the
+// expression after a =.
+const (
+	outputTable      = "table"
+	outputWide       = "wide"
+	outputJSON       = "json"
+	outputYAML       = "yaml"
+	outputJSONPath   = "jsonpath"
+	outputGoTemplate = "go-template"
+)
+
+var outputFormat string
+
+// addOutputFlag adds the -o flag to a read command.
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(
+		&outputFormat,
+		"output",
+		"o",
+		outputTable,
+		"Output format (table, wide, json, yaml, jsonpath=<expr>, go-template=<tmpl>)",
+	)
+}
+
+// printer writes the result of a read command in the chosen format. Tables
+// are written by the command, the other formats are generated from the
+// JSON encoding of the result, so fields are named like in the API. Like
+// kubectl, jsonpath and go-template output is not followed by a newline.
+type printer struct {
+	format string
+	// jsonPath and tmpl are set for their formats.
+	jsonPath *jsonpath.JSONPath
+	tmpl     *template.Template
+}
+
+// newPrinter parses format and returns a usage error if it is invalid, so
+// that it is reported before any request is sent.
+func newPrinter(format string) (*printer, error) {
+	name, expr, _ := strings.Cut(format, "=")
+	p := &printer{format: name}
+
+	switch name {
+	case "", outputTable:
+		p.format = outputTable
+	case outputWide, outputJSON, outputYAML:
+	case outputJSONPath:
+		if expr == "" {
+			return nil, &usageError{msg: "jsonpath output requires an expression"}
+		}
+		// Like kubectl, accept expressions without the enclosing braces.
+		if !strings.HasPrefix(expr, "{") {
+			expr = "{" + expr + "}"
+		}
+		// Fields left out of the JSON when empty are printed as empty.
+		p.jsonPath = jsonpath.New("output").AllowMissingKeys(true)
+		if err := p.jsonPath.Parse(expr); err != nil {
+			return nil, &usageError{msg: fmt.Sprintf("invalid jsonpath: %v", err)}
+		}
+	case outputGoTemplate:
+		if expr == "" {
+			return nil, &usageError{msg: "go-template output requires a template"}
+		}
+		tmpl, err := template.New("output").Parse(expr)
+		if err != nil {
+			return nil, &usageError{msg: fmt.Sprintf("invalid go-template: %v", err)}
+		}
+		p.tmpl = tmpl
+	default:
+		return nil, &usageError{msg: fmt.Sprintf(
+			"unknown output format: %s, must be one of %s, %s, %s, %s, %s=, %s=",
+			name, outputTable, outputWide, outputJSON, outputYAML,
+			outputJSONPath, outputGoTemplate,
+		)}
+	}
+
+	return p, nil
+}
+
+// print writes data in the format of p. table writes the table output,
+// with the extra columns if wide is set.
+func (p *printer) print(
+	data any,
+	table func(w io.Writer, wide bool),
+) error {
+	switch p.format {
+	case outputTable, outputWide:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		table(w, p.format == outputWide)
+		return w.Flush()
+	case outputJSON:
+		out, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding output: %w", err)
+		}
+		_, err = fmt.Println(string(out))
+		return err
+	case outputYAML:
+		out, err := yaml.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("error encoding output: %w", err)
+		}
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+
+	generic, err := toGeneric(data)
+	if err != nil {
+		return err
+	}
+
+	if p.jsonPath != nil {
+		if err := p.jsonPath.Execute(os.Stdout, generic); err != nil {
+			return fmt.Errorf("error executing jsonpath: %w", err)
+		}
+	} else if err := p.tmpl.Execute(os.Stdout, generic); err != nil {
+		return fmt.Errorf("error executing go-template: %w", err)
+	}
+
+	return nil
+}
+
+// toGeneric converts data to maps and slices keyed by the JSON field names.
+// Numbers are kept as json.Number to print integers as such.
+func toGeneric(data any) (any, error) {
+	out, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding output: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(out))
+	dec.UseNumber()
+
+	var generic any
+	if err := dec.Decode(&generic); err != nil {
+		return nil, fmt.Errorf("error encoding output: %w", err)
+	}
+
+	return generic, nil
+}
+
+// expiresIn describes how long until env is deleted, e.g. 2d3h or -5h
+// once the date has passed. It is empty for deleted environments and
+// environments without a deletion date.
+func expiresIn(env *client.Environment) string {
+	if env.DeleteAtSec == 0 || env.Status == "deleted" || env.Status == "gone" {
+		return ""
+	}
+
+	d := time.Until(time.Unix(env.DeleteAtSec, 0)).Round(time.Minute)
+	if d < 0 {
+		return "-" + formatDuration(-d)
+	}
+
+	return formatDuration(d)
+}
+
+// formatDuration formats d with its two most significant units out of days,
+// hours and minutes.
+func formatDuration(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fragpit/env-cleaner/internal/server"
+	"github.com/fragpit/env-cleaner/internal/service"
+)
+
+var reapDryRun bool
+
+var reapCmd = &cobra.Command{
+	Use:   "reap",
+	Short: "Run the deleter once",
+	Long: `Run a single deleter pass and exit: warn the owners of stale
+environments, delete the outdated ones and purge the deleted ones past
+retention. It reads the server configuration and is meant for running
+env-cleaner from cron instead of the server. It exits non-zero if any
+deletion or notification failed.
+
+With --dry-run it only prints the environments that would be warned and
+deleted: nothing is sent, deleted, purged or written to the database. The
+database is not migrated, so it must exist and be up to date.`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{serverConfigAnnotation: ""},
+	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
+		if err := Reap(); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reapCmd)
+
+	reapCmd.Flags().BoolVar(
+		&reapDryRun,
+		"dry-run",
+		false,
+		"Only print the environments that would be warned and deleted",
+	)
+}
+
+func Reap() error {
+	sum, err := server.Reap(reapDryRun)
+	if err != nil {
+		return err
+	}
+
+	warned, deleted := "Warned", "Deleted"
+	if sum.DryRun {
+		deleted = "Would delete"
+	}
+	if sum.PlanOnly {
+		printPlan(sum)
+		warned = "Would warn"
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "%s:\t%d\n", warned, sum.Warned)
+	_, _ = fmt.Fprintf(w, "%s:\t%d\n", deleted, sum.Deleted)
+	_, _ = fmt.Fprintf(w, "Protected:\t%d\n", sum.Protected)
+	_, _ = fmt.Fprintf(w, "Skipped:\t%d\n", sum.Skipped)
+	_, _ = fmt.Fprintf(w, "Failed:\t%d\n", sum.Failed)
+	_, _ = fmt.Fprintf(w, "Purged:\t%d\n", sum.Purged)
+	_, _ = fmt.Fprintf(w, "Errors:\t%d\n", sum.Errors)
+	_ = w.Flush()
+
+	return summaryError(sum)
+}
+
+// printPlan prints the environments a plan only run would have warned or
+// deleted.
+func printPlan(sum *service.DeleterSummary) {
+	if len(sum.Plan) == 0 {
+		fmt.Println("Dry run: nothing to do")
+		fmt.Println()
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "Action\tOwner\tID\tNamespace\tName\tType\tDeleteAt")
+	for _, p := range sum.Plan {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			p.Action, p.Env.Owner, p.Env.EnvID, p.Env.Namespace, p.Env.Name,
+			p.Env.Type, p.Env.DeleteAt)
+	}
+	_ = w.Flush()
+	fmt.Println()
+}
+
+// summaryError returns an error if a deleter pass had failures.
+func summaryError(sum *service.DeleterSummary) error {
+	if sum.Failed > 0 || sum.Errors > 0 {
+		return fmt.Errorf("%d deletions failed, %d other errors",
+			sum.Failed, sum.Errors)
+	}
+
+	return nil
+}
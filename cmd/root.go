@@ -11,16 +11,22 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/fragpit/env-cleaner/internal/config"
+	"github.com/fragpit/env-cleaner/pkg/client"
 )
 
 var cfgFile string
 var cfg *config.ClientConfig
+var apiClient *client.Client
 var err error
 var Debug bool
 var version = "undefined"
 
 var logLevel slog.LevelVar
 
+// serverConfigAnnotation marks commands that read the server configuration
+// file instead of the client one. Subcommands inherit it from their parents.
+const serverConfigAnnotation = "server-config"
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "env-cleaner",
@@ -42,6 +48,25 @@ infrastructures.`,
 			slog.Error("error reading configuration", slog.Any("error", err))
 			os.Exit(1)
 		}
+
+		tlsConfig, err := client.LoadTLSConfig(
+			cfg.TLS.CAFile, cfg.TLS.CertFile, cfg.TLS.KeyFile,
+		)
+		if err != nil {
+			slog.Error("error configuring tls", slog.Any("error", err))
+			os.Exit(1)
+		}
+
+		apiClient, err = client.New(client.Config{
+			URL:         cfg.APIURL,
+			APIKey:      cfg.APIKey,
+			AdminAPIKey: cfg.AdminAPIKey,
+			TLS:         tlsConfig,
+		})
+		if err != nil {
+			slog.Error("error creating api client", slog.Any("error", err))
+			os.Exit(1)
+		}
 	},
 }
 
@@ -50,7 +75,7 @@ infrastructures.`,
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 }
 
@@ -105,7 +130,7 @@ func initConfig() {
 		home, err := os.UserHomeDir()
 		cobra.CheckErr(err)
 
-		if cmd.Name() == "server" {
+		if usesServerConfig(cmd) {
 			viper.AddConfigPath(home + "/.env-cleaner/")
 			viper.SetConfigType("yaml")
 			viper.SetConfigName("env-cleaner.yml")
@@ -128,3 +153,12 @@ func initConfig() {
 		os.Exit(1)
 	}
 }
+
+func usesServerConfig(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if _, ok := c.Annotations[serverConfigAnnotation]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -15,6 +15,7 @@ var serverCmd = &cobra.Command{
 	Short: "Server mode",
 	Long: `Server mode is a common mode for this application. It starts an API
 interface, and schedules a crawler and cleanup job for the specified environments.`,
+	Annotations: map[string]string{serverConfigAnnotation: ""},
 	Run: func(cmd *cobra.Command, args []string) { //nolint:revive
 		slog.Info("starting server", slog.String("version", version))
 		if err := server.Run(); err != nil {
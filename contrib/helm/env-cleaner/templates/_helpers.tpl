@@ -67,3 +67,18 @@ Create the name of the service account to use
 {{- printf "{\"auths\":{\"%s\":{\"username\":\"%s\",\"password\":\"%s\"}}}" .registry .username .password | b64enc }}
 {{- end }}
 {{- end }}
+
+{{/*
+Port and scheme of the health probes, served on the internal listener if it
+is exposed.
+*/}}
+{{- define "env-cleaner.probePort" -}}
+{{- if .Values.service.metricsPort -}}
+port: metrics
+{{- else -}}
+port: http
+{{- if .Values.tls.secretName }}
+scheme: HTTPS
+{{- end }}
+{{- end }}
+{{- end }}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/pkg/utils"
+)
+
+type AuditHandler struct {
+	service AuditService
+}
+
+func NewAuditHandler(svc AuditService) *AuditHandler {
+	return &AuditHandler{service: svc}
+}
+
+func (h *AuditHandler) GetAuditEntries(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := h.service.GetAuditEntries(r.Context(), filter)
+	if err != nil {
+		handleServiceError(w, err, "get audit entries")
+		return
+	}
+
+	sendSuccessResponse(w, NewAuditListResponse(entries))
+}
+
+func parseAuditFilter(r *http.Request) (*model.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := &model.AuditFilter{
+		EnvID:  query.Get("env_id"),
+		Action: query.Get("action"),
+		Actor:  query.Get("actor"),
+		Source: query.Get("source"),
+		Owner:  query.Get("owner"),
+	}
+
+	var err error
+	if v := query.Get("since"); v != "" {
+		if filter.Since, err = utils.ParseTimeBound(v); err != nil {
+			return nil, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+
+	if v := query.Get("until"); v != "" {
+		if filter.Until, err = utils.ParseTimeBound(v); err != nil {
+			return nil, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid limit: %s", v)
+		}
+	}
+
+	return filter, nil
+}
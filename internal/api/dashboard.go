@@ -0,0 +1,219 @@
+package api
+
+import (
+	_ "embed"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+)
+
+const (
+	// dashboardPageSize is the page size environments are listed with.
+	dashboardPageSize = 1000
+	// dashboardHistoryLimit is the number of recent audit entries shown.
+	dashboardHistoryLimit = 20
+)
+
+//go:embed static/dashboard.html
+var dashboardHTML string
+
+//go:embed static/dashboard.css
+var dashboardCSS string
+
+//go:embed static/dashboard.js
+var dashboardJS string
+
+type dashboardData struct {
+	User         string
+	Owner        string
+	Environments []dashboardEnvironment
+	History      []dashboardEntry
+	PeriodMin    string
+	PeriodMid    string
+	PeriodMax    string
+}
+
+type dashboardEnvironment struct {
+	EnvID       string
+	Name        string
+	Type        string
+	Status      string
+	DeleteAt    string
+	DeleteAtSec int64
+	Protected   bool
+	CanExtend   bool
+	CanDelete   bool
+}
+
+type dashboardEntry struct {
+	CreatedAt   string
+	EnvName     string
+	Action      string
+	Actor       string
+	NewDeleteAt string
+	Details     string
+}
+
+type DashboardHandler struct {
+	service           EnvironmentService
+	auditService      AuditService
+	staleThreshold    string
+	maxExtendDuration string
+	tmpl              *template.Template
+}
+
+func NewDashboardHandler(
+	svc EnvironmentService,
+	auditSvc AuditService,
+	staleThreshold string,
+	maxExtendDuration string,
+) *DashboardHandler {
+	tmpl := template.Must(
+		template.New("dashboard").Parse(dashboardHTML),
+	)
+	return &DashboardHandler{
+		service:           svc,
+		auditService:      auditSvc,
+		staleThreshold:    staleThreshold,
+		maxExtendDuration: maxExtendDuration,
+		tmpl:              tmpl,
+	}
+}
+
+// ServePage lists the environments of the logged in owner with their
+// recent history. Actors not restricted to an owner see the owner in the
+// owner parameter, or the environments owned by their own name.
+func (h *DashboardHandler) ServePage(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	actor, _ := model.ActorFromContext(r.Context())
+
+	owner := actor.Owner
+	if owner == "" {
+		owner = r.URL.Query().Get("owner")
+	}
+	if owner == "" {
+		owner = actor.Name
+	}
+
+	var envs []*model.Environment
+	filter := &model.EnvironmentFilter{
+		Owner: owner,
+		Sort:  model.SortByDeleteAt,
+		Limit: dashboardPageSize,
+	}
+	for cursor := ""; ; {
+		page, next, err := h.service.GetEnvironments(r.Context(), filter, cursor)
+		if err != nil {
+			handleServiceError(w, err, "dashboard")
+			return
+		}
+		envs = append(envs, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	entries, err := h.auditService.GetAuditEntries(
+		r.Context(),
+		&model.AuditFilter{Owner: owner, Limit: dashboardHistoryLimit},
+	)
+	if err != nil {
+		handleServiceError(w, err, "dashboard")
+		return
+	}
+
+	periods, err := calcExtendPeriods(
+		h.staleThreshold, h.maxExtendDuration,
+	)
+	if err != nil {
+		slog.Error("error calculating extend periods",
+			slog.Any("error", err),
+		)
+		sendErrorResponse(
+			w, http.StatusInternalServerError,
+			"internal server error",
+		)
+		return
+	}
+
+	data := dashboardData{
+		User:      actor.Name,
+		Owner:     owner,
+		PeriodMin: periods["min"],
+		PeriodMid: periods["mid"],
+		PeriodMax: periods["max"],
+	}
+
+	now := time.Now().Unix()
+	for _, env := range envs {
+		live := env.Status != model.StatusDeleting &&
+			env.Status != model.StatusGone
+		protected := env.IsProtected(now)
+		data.Environments = append(data.Environments, dashboardEnvironment{
+			EnvID:       env.EnvID,
+			Name:        env.DisplayName(),
+			Type:        env.Type,
+			Status:      env.Status,
+			DeleteAt:    env.DeleteAt,
+			DeleteAtSec: env.DeleteAtSec,
+			Protected:   protected,
+			CanExtend:   live && actor.HasScope(model.ScopeExtend),
+			// Environments already due wait for the deleter.
+			CanDelete: live && !protected && env.DeleteAtSec > now &&
+				actor.HasScope(model.ScopeDelete),
+		})
+	}
+
+	for _, e := range entries {
+		data.History = append(data.History, dashboardEntry{
+			CreatedAt:   time.Unix(e.CreatedAt, 0).Format("02-01-06 15:04:05"),
+			EnvName:     e.EnvName,
+			Action:      e.Action,
+			Actor:       e.Actor,
+			NewDeleteAt: e.NewDeleteAt,
+			Details:     e.Details,
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tmpl.Execute(w, data); err != nil {
+		slog.Error("error rendering template",
+			slog.Any("error", err),
+		)
+	}
+}
+
+func (h *DashboardHandler) ServeCSS(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(dashboardCSS)); err != nil {
+		slog.Error("error serving CSS",
+			slog.Any("error", err),
+		)
+	}
+}
+
+func (h *DashboardHandler) ServeJS(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	w.Header().Set(
+		"Content-Type",
+		"application/javascript; charset=utf-8",
+	)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(dashboardJS)); err != nil {
+		slog.Error("error serving JS",
+			slog.Any("error", err),
+		)
+	}
+}
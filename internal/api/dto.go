@@ -1,64 +1,193 @@
 package api
 
-import "github.com/fragpit/env-cleaner/internal/model"
+import (
+	"time"
 
-// EnvironmentRequest is a DTO for creating an environment.
-type EnvironmentRequest struct {
-	Name      string `json:"name"`
-	Namespace string `json:"namespace"`
-	Owner     string `json:"owner"`
-	Type      string `json:"type"`
-	TTL       string `json:"ttl"`
-}
+	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/pkg/apitypes"
+)
 
-// ToModel converts EnvironmentRequest DTO to domain model.
-func (r *EnvironmentRequest) ToModel() *model.Environment {
+// environmentFromRequest converts EnvironmentRequest DTO to domain model.
+func environmentFromRequest(r *apitypes.EnvironmentRequest) *model.Environment {
 	return &model.Environment{
 		Type:      r.Type,
 		Name:      r.Name,
 		Namespace: r.Namespace,
 		Owner:     r.Owner,
+		Labels:    r.Labels,
 	}
 }
 
-// EnvironmentResponse is a DTO for returning environment data.
-type EnvironmentResponse struct {
-	EnvID     string `json:"env_id"`
-	Type      string `json:"type"`
-	Name      string `json:"name"`
-	Namespace string `json:"namespace,omitempty"`
-	Owner     string `json:"owner"`
-	DeleteAt  string `json:"delete_at,omitempty"`
-}
-
 // NewEnvironmentResponse converts domain model to response DTO.
 func NewEnvironmentResponse(
 	e *model.Environment,
-) *EnvironmentResponse {
-	return &EnvironmentResponse{
-		EnvID:     e.EnvID,
-		Type:      e.Type,
-		Name:      e.Name,
-		Namespace: e.Namespace,
-		Owner:     e.Owner,
-		DeleteAt:  e.DeleteAt,
+) *apitypes.EnvironmentResponse {
+	resp := &apitypes.EnvironmentResponse{
+		EnvID:           e.EnvID,
+		Type:            e.Type,
+		Name:            e.Name,
+		Namespace:       e.Namespace,
+		Owner:           e.Owner,
+		DeleteAt:        e.DeleteAt,
+		DeleteAtSec:     e.DeleteAtSec,
+		TTL:             e.TTL,
+		DeleteAtSource:  e.DeleteAtSource,
+		Status:          e.Status,
+		FailureCount:    e.FailureCount,
+		LastError:       e.LastError,
+		Protected:       e.Protected,
+		ProtectedReason: e.ProtectedReason,
+		ProtectedBy:     e.ProtectedBy,
+		Labels:          e.Labels,
+	}
+	if e.StatusChangedAt > 0 {
+		resp.StatusChangedAt = time.Unix(e.StatusChangedAt, 0).
+			Format("02-01-06 15:04:05")
 	}
+	if e.ProtectedUntil > 0 {
+		resp.ProtectedUntil = time.Unix(e.ProtectedUntil, 0).
+			Format("02-01-06 15:04:05")
+	}
+	if e.NextAttemptAt > 0 {
+		resp.NextAttemptAt = time.Unix(e.NextAttemptAt, 0).
+			Format("02-01-06 15:04:05")
+	}
+
+	return resp
 }
 
 // NewEnvironmentListResponse converts a slice of domain models
 // to a slice of response DTOs.
 func NewEnvironmentListResponse(
 	envs []*model.Environment,
-) []*EnvironmentResponse {
-	result := make([]*EnvironmentResponse, len(envs))
+) []*apitypes.EnvironmentResponse {
+	result := make([]*apitypes.EnvironmentResponse, len(envs))
 	for i, e := range envs {
 		result[i] = NewEnvironmentResponse(e)
 	}
 	return result
 }
 
-// ExtendEnvironmentRequest is a DTO for extending an environment's TTL.
-type ExtendEnvironmentRequest struct {
-	Period string `json:"period"`
-	Token  string `json:"token"`
+// batchOperationFromRequest converts BatchRequest DTO to domain model.
+func batchOperationFromRequest(
+	r *apitypes.BatchRequest,
+	dryRun bool,
+) *model.BatchOperation {
+	op := &model.BatchOperation{
+		IDs:       r.IDs,
+		Action:    r.Action,
+		Period:    r.Period,
+		DeleteAt:  r.DeleteAt,
+		IgnoreMax: r.IgnoreMax,
+		Reason:    r.Reason,
+		Owner:     r.Owner,
+		DryRun:    dryRun,
+	}
+	if r.Selector != nil {
+		op.Selector = model.BatchSelector{
+			Owner:     r.Selector.Owner,
+			Namespace: r.Selector.Namespace,
+			Type:      r.Selector.Type,
+			NameRegex: r.Selector.NameRegex,
+			Label:     r.Selector.Label,
+		}
+	}
+	return op
+}
+
+// NewBatchResponse converts batch results to a response DTO.
+func NewBatchResponse(
+	op *model.BatchOperation,
+	results []*model.BatchResult,
+) *apitypes.BatchResponse {
+	resp := &apitypes.BatchResponse{
+		Action: op.Action,
+		DryRun: op.DryRun,
+		Items:  make([]*apitypes.BatchItemResponse, len(results)),
+	}
+	for i, res := range results {
+		item := &apitypes.BatchItemResponse{
+			EnvID:  res.EnvID,
+			Result: res.Result,
+			Error:  res.Error,
+		}
+		if res.Env != nil {
+			item.Environment = NewEnvironmentResponse(res.Env)
+		}
+		resp.Items[i] = item
+
+		switch res.Result {
+		case model.BatchResultMatched:
+			resp.Matched++
+		case model.BatchResultOK:
+			resp.Matched++
+			resp.Succeeded++
+		case model.BatchResultFailed:
+			resp.Failed++
+			if res.Env != nil {
+				resp.Matched++
+			}
+		}
+	}
+	return resp
+}
+
+// NewWebhookResponse converts the results of a merge request event to a
+// response DTO.
+func NewWebhookResponse(
+	ev *model.MergeRequestEvent,
+	results []*model.BatchResult,
+) *apitypes.WebhookResponse {
+	op := &model.BatchOperation{Action: model.BatchActionDeleteNow}
+	return &apitypes.WebhookResponse{
+		Project: ev.Project,
+		Number:  ev.Number,
+		State:   ev.State,
+		Items:   NewBatchResponse(op, results).Items,
+	}
+}
+
+// NewAuditListResponse converts a slice of audit entries to a slice of
+// response DTOs.
+func NewAuditListResponse(
+	entries []*model.AuditEntry,
+) []*apitypes.AuditEntryResponse {
+	result := make([]*apitypes.AuditEntryResponse, len(entries))
+	for i, e := range entries {
+		result[i] = &apitypes.AuditEntryResponse{
+			ID:          e.ID,
+			CreatedAt:   time.Unix(e.CreatedAt, 0).Format("02-01-06 15:04:05"),
+			EnvID:       e.EnvID,
+			EnvType:     e.EnvType,
+			EnvName:     e.EnvName,
+			Action:      e.Action,
+			Actor:       e.Actor,
+			Source:      e.Source,
+			OldDeleteAt: e.OldDeleteAt,
+			NewDeleteAt: e.NewDeleteAt,
+			Details:     e.Details,
+		}
+	}
+	return result
+}
+
+// NewHealthResponse converts a health report to a response DTO.
+func NewHealthResponse(report *model.HealthReport) *apitypes.HealthResponse {
+	resp := &apitypes.HealthResponse{
+		Status: report.Status,
+		Components: make(
+			map[string]*apitypes.ComponentHealthResponse, len(report.Components),
+		),
+	}
+	for name, c := range report.Components {
+		component := &apitypes.ComponentHealthResponse{
+			Status: c.Status,
+			Error:  c.Error,
+		}
+		if !c.LastSuccess.IsZero() {
+			component.LastSuccess = c.LastSuccess.Format("02-01-06 15:04:05")
+		}
+		resp.Components[name] = component
+	}
+	return resp
 }
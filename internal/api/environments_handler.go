@@ -2,8 +2,15 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/pkg/apitypes"
+	"github.com/fragpit/env-cleaner/pkg/utils"
 )
 
 type EnvironmentHandler struct {
@@ -18,27 +25,94 @@ func (h *EnvironmentHandler) GetEnvironments(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
-	envs, err := h.service.GetEnvironments(r.Context())
+	query := r.URL.Query()
+	filter := &model.EnvironmentFilter{
+		Owner:     query.Get("owner"),
+		Type:      query.Get("type"),
+		Namespace: query.Get("namespace"),
+		Name:      query.Get("name"),
+		Sort:      query.Get("sort"),
+	}
+	if status := query.Get("status"); status != "" {
+		filter.Statuses = strings.Split(status, ",")
+	}
+	if label := query.Get("label"); label != "" {
+		labels, err := model.ParseLabelSelector(label)
+		if err != nil {
+			sendErrorResponse(w, http.StatusBadRequest,
+				fmt.Sprintf("invalid label: %v", err))
+			return
+		}
+		filter.Labels = labels
+	}
+
+	for param, bound := range map[string]*int64{
+		"delete_before": &filter.DeleteBefore,
+		"delete_after":  &filter.DeleteAfter,
+	} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := utils.ParseDeleteBound(value)
+		if err != nil {
+			sendErrorResponse(w, http.StatusBadRequest,
+				fmt.Sprintf("invalid %s: %v", param, err))
+			return
+		}
+		*bound = t
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		filter.Limit = n
+	}
+
+	envs, next, err := h.service.GetEnvironments(
+		r.Context(), filter, query.Get("cursor"),
+	)
 	if err != nil {
 		handleServiceError(w, err, "get environments")
 		return
 	}
 
-	sendSuccessResponse(w, NewEnvironmentListResponse(envs))
+	sendPagedResponse(w, NewEnvironmentListResponse(envs), &apitypes.Paging{
+		Limit:      filter.Limit,
+		NextCursor: next,
+	})
+}
+
+func (h *EnvironmentHandler) GetEnvironment(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	envID := r.PathValue("id")
+
+	env, err := h.service.GetEnvironment(r.Context(), envID)
+	if err != nil {
+		handleServiceError(w, err, envID)
+		return
+	}
+
+	sendSuccessResponse(w, NewEnvironmentResponse(env))
 }
 
 func (h *EnvironmentHandler) AddEnvironment(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
-	var envReq EnvironmentRequest
+	var envReq apitypes.EnvironmentRequest
 	if err := json.NewDecoder(r.Body).Decode(&envReq); err != nil {
 		slog.Error("error decoding request", slog.Any("error", err))
 		sendErrorResponse(w, http.StatusBadRequest, "error decoding request")
 		return
 	}
 
-	envModel := envReq.ToModel()
+	envModel := environmentFromRequest(&envReq)
 
 	if err := h.service.AddEnvironment(
 		r.Context(),
@@ -52,21 +126,111 @@ func (h *EnvironmentHandler) AddEnvironment(
 	sendSuccessResponse(w, NewEnvironmentResponse(envModel))
 }
 
+func (h *EnvironmentHandler) UpdateEnvironment(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	envID := r.PathValue("id")
+
+	var req apitypes.UpdateEnvironmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("error decoding request", slog.Any("error", err))
+		sendErrorResponse(w, http.StatusBadRequest, "error decoding request")
+		return
+	}
+
+	env, err := h.service.UpdateEnvironment(
+		r.Context(), envID, req.Owner, req.TTL, req.DeleteAt,
+	)
+	if err != nil {
+		handleServiceError(w, err, envID)
+		return
+	}
+
+	slog.Info("updated environment",
+		slog.String("name", env.DisplayName()),
+		slog.String("type", env.Type),
+		slog.String("id", env.EnvID),
+		slog.String("owner", env.Owner),
+		slog.String("delete_at", env.DeleteAt),
+	)
+
+	sendSuccessResponse(w, NewEnvironmentResponse(env))
+}
+
+func (h *EnvironmentHandler) DeleteEnvironment(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	envID := r.PathValue("id")
+
+	forget := false
+	if v := r.URL.Query().Get("forget"); v != "" {
+		var err error
+		if forget, err = strconv.ParseBool(v); err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "invalid forget value")
+			return
+		}
+	}
+
+	env, err := h.service.DeleteEnvironment(r.Context(), envID, forget)
+	if err != nil {
+		handleServiceError(w, err, envID)
+		return
+	}
+
+	msg := "scheduled environment deletion"
+	if forget {
+		msg = "forgot environment"
+	}
+	slog.Info(msg,
+		slog.String("name", env.DisplayName()),
+		slog.String("type", env.Type),
+		slog.String("id", env.EnvID),
+	)
+
+	sendSuccessResponse(w, NewEnvironmentResponse(env))
+}
+
 func (h *EnvironmentHandler) ExtendEnvironment(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
-	ctx := r.Context()
 	envID := r.PathValue("id")
 
-	var req ExtendEnvironmentRequest
+	var req apitypes.ExtendEnvironmentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		slog.Error("error decoding request", slog.Any("error", err))
 		sendErrorResponse(w, http.StatusBadRequest, "error decoding request")
 		return
 	}
 
-	env, err := h.service.ExtendEnvironment(ctx, envID, req.Period, req.Token)
+	// Only authenticated requests carry an actor at this point. A token
+	// takes precedence, so extend links work for logged in users who do
+	// not own the environment.
+	var env *model.Environment
+	var err error
+	if actor, ok := model.ActorFromContext(r.Context()); ok && req.Token == "" {
+		ctx := r.Context()
+		if r.Header.Get(extendPageHeader) != "" {
+			actor.Source = model.AuditSourceExtendPage
+			ctx = model.WithActor(ctx, actor)
+		}
+
+		env, err = h.service.AdminExtendEnvironment(
+			ctx, envID, req.Period, req.DeleteAt, req.IgnoreMax,
+		)
+	} else {
+		// The actor is resolved to the owner by the service, since extend
+		// tokens are only sent to owners.
+		source := model.AuditSourceAPI
+		if r.Header.Get(extendPageHeader) != "" {
+			source = model.AuditSourceExtendPage
+		}
+		ctx := model.WithActor(r.Context(), model.Actor{Source: source})
+
+		env, err = h.service.ExtendEnvironment(ctx, envID, req.Period, req.Token)
+	}
 	if err != nil {
 		handleServiceError(w, err, envID)
 		return
@@ -77,6 +241,101 @@ func (h *EnvironmentHandler) ExtendEnvironment(
 		slog.String("type", env.Type),
 		slog.String("id", env.EnvID),
 		slog.String("period", req.Period),
+		slog.String("delete_at", env.DeleteAt),
+	)
+
+	sendSuccessResponse(w, NewEnvironmentResponse(env))
+}
+
+// BatchEnvironments applies an action to several environments. With
+// dry_run the matched environments are returned without changing them.
+func (h *EnvironmentHandler) BatchEnvironments(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "invalid dry_run value")
+			return
+		}
+	}
+
+	var req apitypes.BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("error decoding request", slog.Any("error", err))
+		sendErrorResponse(w, http.StatusBadRequest, "error decoding request")
+		return
+	}
+
+	op := batchOperationFromRequest(&req, dryRun)
+	results, err := h.service.BatchEnvironments(r.Context(), op)
+	if err != nil {
+		handleServiceError(w, err, "batch "+req.Action)
+		return
+	}
+
+	resp := NewBatchResponse(op, results)
+	slog.Info("applied batch action",
+		slog.String("action", resp.Action),
+		slog.Bool("dry_run", resp.DryRun),
+		slog.Int("matched", resp.Matched),
+		slog.Int("succeeded", resp.Succeeded),
+		slog.Int("failed", resp.Failed),
+	)
+
+	sendSuccessResponse(w, resp)
+}
+
+func (h *EnvironmentHandler) ProtectEnvironment(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	envID := r.PathValue("id")
+
+	var req apitypes.ProtectEnvironmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("error decoding request", slog.Any("error", err))
+		sendErrorResponse(w, http.StatusBadRequest, "error decoding request")
+		return
+	}
+
+	env, err := h.service.ProtectEnvironment(
+		r.Context(), envID, req.Period, req.Reason,
+	)
+	if err != nil {
+		handleServiceError(w, err, envID)
+		return
+	}
+
+	slog.Info("protected environment",
+		slog.String("name", env.DisplayName()),
+		slog.String("type", env.Type),
+		slog.String("id", env.EnvID),
+		slog.String("period", req.Period),
+		slog.String("reason", req.Reason),
+	)
+
+	sendSuccessResponse(w, NewEnvironmentResponse(env))
+}
+
+func (h *EnvironmentHandler) UnprotectEnvironment(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	envID := r.PathValue("id")
+
+	env, err := h.service.UnprotectEnvironment(r.Context(), envID)
+	if err != nil {
+		handleServiceError(w, err, envID)
+		return
+	}
+
+	slog.Info("unprotected environment",
+		slog.String("name", env.DisplayName()),
+		slog.String("type", env.Type),
+		slog.String("id", env.EnvID),
 	)
 
 	sendSuccessResponse(w, NewEnvironmentResponse(env))
@@ -5,10 +5,16 @@ import (
 	"html/template"
 	"log/slog"
 	"net/http"
+	"net/url"
 
 	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/fragpit/env-cleaner/internal/model"
 )
 
+// extendPageHeader marks extend requests sent by the extend page.
+const extendPageHeader = "X-Extend-Page"
+
 //go:embed static/extend.html
 var extendHTML string
 
@@ -34,6 +40,7 @@ type ExtendPageHandler struct {
 	service           EnvironmentService
 	staleThreshold    string
 	maxExtendDuration string
+	loginEnabled      bool
 	tmpl              *template.Template
 }
 
@@ -41,6 +48,7 @@ func NewExtendPageHandler(
 	svc EnvironmentService,
 	staleThreshold string,
 	maxExtendDuration string,
+	loginEnabled bool,
 ) *ExtendPageHandler {
 	tmpl := template.Must(
 		template.New("extend").Parse(extendHTML),
@@ -49,6 +57,7 @@ func NewExtendPageHandler(
 		service:           svc,
 		staleThreshold:    staleThreshold,
 		maxExtendDuration: maxExtendDuration,
+		loginEnabled:      loginEnabled,
 		tmpl:              tmpl,
 	}
 }
@@ -60,7 +69,18 @@ func (h *ExtendPageHandler) ServePage(
 	envID := r.URL.Query().Get("env_id")
 	token := r.URL.Query().Get("token")
 
-	if envID == "" || token == "" {
+	// Without a token the page is served to logged in users, who are sent
+	// to the login first.
+	_, loggedIn := model.ActorFromContext(r.Context())
+	if envID != "" && token == "" && !loggedIn && h.loginEnabled {
+		http.Redirect(w, r,
+			"/auth/login?redirect="+url.QueryEscape(r.URL.RequestURI()),
+			http.StatusFound,
+		)
+		return
+	}
+
+	if envID == "" || (token == "" && !loggedIn) {
 		sendErrorResponse(
 			w, http.StatusBadRequest,
 			"missing env_id or token",
@@ -68,9 +88,15 @@ func (h *ExtendPageHandler) ServePage(
 		return
 	}
 
-	env, err := h.service.GetEnvironmentForExtend(
-		r.Context(), envID, token,
-	)
+	var env *model.Environment
+	var err error
+	if token != "" {
+		env, err = h.service.GetEnvironmentForExtend(
+			r.Context(), envID, token,
+		)
+	} else {
+		env, err = h.service.GetEnvironment(r.Context(), envID)
+	}
 	if err != nil {
 		handleServiceError(w, err, envID)
 		return
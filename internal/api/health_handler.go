@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+)
+
+type HealthHandler struct {
+	service HealthService
+}
+
+func NewHealthHandler(svc HealthService) *HealthHandler {
+	return &HealthHandler{service: svc}
+}
+
+// Liveness serves the liveness probe.
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	sendHealthReport(w, h.service.Liveness(r.Context()))
+}
+
+// Readiness serves the readiness probe.
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	sendHealthReport(w, h.service.Readiness(r.Context()))
+}
+
+// sendHealthReport writes report as is rather than in a Response, with
+// 503 if it is failing so that probes need not parse it. Degraded reports
+// are served with 200.
+func sendHealthReport(w http.ResponseWriter, report *model.HealthReport) {
+	status := http.StatusOK
+	if report.Status == model.HealthFailing {
+		status = http.StatusServiceUnavailable
+	}
+	if report.Status != model.HealthOK {
+		for name, c := range report.Components {
+			if c.Status != model.HealthOK {
+				slog.Warn("health check failing",
+					slog.String("component", name),
+					slog.String("error", c.Error),
+				)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(NewHealthResponse(report)); err != nil {
+		slog.Error("error encoding JSON response", slog.Any("error", err))
+	}
+}
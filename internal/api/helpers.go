@@ -7,24 +7,29 @@ import (
 	"net/http"
 
 	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/pkg/apitypes"
 )
 
-type Response struct {
-	Success bool   `json:"success"`
-	Data    any    `json:"data,omitempty"`
-	Error   *Error `json:"error,omitempty"`
-}
-
-type Error struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+func sendSuccessResponse(w http.ResponseWriter, data any) {
+	sendResponse(w, apitypes.Response{
+		Success: true,
+		Data:    data,
+	})
 }
 
-func sendSuccessResponse(w http.ResponseWriter, data any) {
-	response := Response{
+func sendPagedResponse(
+	w http.ResponseWriter,
+	data any,
+	paging *apitypes.Paging,
+) {
+	sendResponse(w, apitypes.Response{
 		Success: true,
 		Data:    data,
-	}
+		Paging:  paging,
+	})
+}
+
+func sendResponse(w http.ResponseWriter, response apitypes.Response) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -40,9 +45,9 @@ func sendSuccessResponse(w http.ResponseWriter, data any) {
 }
 
 func sendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	response := Response{
+	response := apitypes.Response{
 		Success: false,
-		Error: &Error{
+		Error: &apitypes.Error{
 			Code:    statusCode,
 			Message: message,
 		},
@@ -62,6 +67,7 @@ func handleServiceError(
 	var ve *model.ValidationError
 	var nf *model.NotFoundError
 	var ce *model.ConflictError
+	var fe *model.ForbiddenError
 
 	switch {
 	case errors.As(err, &ve):
@@ -85,6 +91,13 @@ func handleServiceError(
 			slog.Any("error", err),
 		)
 		sendErrorResponse(w, http.StatusConflict, ce.Msg)
+	case errors.As(err, &fe):
+		slog.Warn(
+			"forbidden",
+			slog.String("subject", subject),
+			slog.Any("error", err),
+		)
+		sendErrorResponse(w, http.StatusForbidden, fe.Msg)
 	default:
 		slog.Error(
 			"internal error",
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/fragpit/env-cleaner/internal/config"
+)
+
+const (
+	defaultListenAddress = ":8080"
+	defaultReadTimeout   = 60 * time.Second
+	defaultWriteTimeout  = 60 * time.Second
+
+	// tlsReloadInterval is how often certificate files are checked for
+	// changes.
+	tlsReloadInterval = 30 * time.Second
+)
+
+// newServer returns a server listening as configured by cfg. Certificate
+// files are watched for changes until ctx is done.
+func newServer(
+	ctx context.Context,
+	cfg config.Listen,
+	handler http.Handler,
+) (*http.Server, error) {
+	if cfg.Address == "" {
+		cfg.Address = defaultListenAddress
+	}
+
+	srv := &http.Server{
+		Addr:         cfg.Address,
+		Handler:      handler,
+		ReadTimeout:  defaultReadTimeout,
+		WriteTimeout: defaultWriteTimeout,
+	}
+
+	for _, t := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"read_timeout", cfg.ReadTimeout, &srv.ReadTimeout},
+		{"read_header_timeout", cfg.ReadHeaderTimeout, &srv.ReadHeaderTimeout},
+		{"write_timeout", cfg.WriteTimeout, &srv.WriteTimeout},
+		{"idle_timeout", cfg.IdleTimeout, &srv.IdleTimeout},
+	} {
+		if t.value == "" {
+			continue
+		}
+		d, err := str2duration.ParseDuration(t.value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid %s: %s", t.name, t.value)
+		}
+		*t.dst = d
+	}
+
+	switch {
+	case cfg.TLS.CertFile == "" && cfg.TLS.KeyFile == "":
+		if cfg.TLS.ClientCAFile != "" || cfg.TLS.RequireClientCert {
+			return nil, errors.New("client certificates require cert_file and key_file")
+		}
+		return srv, nil
+	case cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "":
+		return nil, errors.New("both cert_file and key_file are required")
+	case cfg.TLS.RequireClientCert && cfg.TLS.ClientCAFile == "":
+		return nil, errors.New("require_client_cert requires client_ca_file")
+	}
+
+	reloader := &certReloader{cfg: cfg.TLS}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	go reloader.watch(ctx)
+
+	srv.TLSConfig = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: reloader.configForClient,
+	}
+
+	return srv, nil
+}
+
+// serve runs srv until it is shut down.
+func serve(srv *http.Server) error {
+	var err error
+	if srv.TLSConfig != nil {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// hasClientCert reports whether r came over a connection with a verified
+// client certificate.
+func hasClientCert(r *http.Request) bool {
+	return r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+}
+
+// certReloader serves the certificate and client CAs of cfg and reloads
+// them when their files change.
+type certReloader struct {
+	cfg config.TLS
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+func (c *certReloader) files() []string {
+	files := []string{c.cfg.CertFile, c.cfg.KeyFile}
+	if c.cfg.ClientCAFile != "" {
+		files = append(files, c.cfg.ClientCAFile)
+	}
+
+	return files
+}
+
+func (c *certReloader) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, f := range c.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return fmt.Errorf("error reading tls file: %w", err)
+		}
+		modTimes[f] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.cfg.CertFile, c.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("error loading tls certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if c.cfg.ClientCAFile != "" {
+		data, err := os.ReadFile(c.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("error reading client ca file: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates in client ca file %s",
+				c.cfg.ClientCAFile)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert = &cert
+	c.clientCAs = clientCAs
+	c.modTimes = modTimes
+
+	return nil
+}
+
+func (c *certReloader) changed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, f := range c.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			// A file being replaced is picked up on the next check.
+			continue
+		}
+		if !info.ModTime().Equal(c.modTimes[f]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watch reloads the files when they change until ctx is done. Files that
+// fail to load keep the previous certificate in use.
+func (c *certReloader) watch(ctx context.Context) {
+	ticker := time.NewTicker(tlsReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !c.changed() {
+				continue
+			}
+			if err := c.load(); err != nil {
+				slog.Error("error reloading tls certificate",
+					slog.Any("error", err),
+				)
+				continue
+			}
+			slog.Info("reloaded tls certificate",
+				slog.String("cert_file", c.cfg.CertFile),
+			)
+		}
+	}
+}
+
+func (c *certReloader) configForClient(
+	*tls.ClientHelloInfo,
+) (*tls.Config, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*c.cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if c.clientCAs != nil {
+		cfg.ClientCAs = c.clientCAs
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return cfg, nil
+}
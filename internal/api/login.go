@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fragpit/env-cleaner/internal/oidc"
+)
+
+const (
+	// sessionCookie holds the ID token of a browser logged in with OIDC.
+	sessionCookie = "env_cleaner_session"
+	// loginCookie holds the state of a login in progress.
+	loginCookie = "env_cleaner_login"
+
+	loginTimeout = 10 * time.Minute
+)
+
+type LoginHandler struct {
+	verifier    OIDCVerifier
+	redirectURI string
+	secure      bool
+}
+
+func NewLoginHandler(verifier OIDCVerifier, apiURL string) *LoginHandler {
+	apiURL = strings.TrimSuffix(apiURL, "/")
+	return &LoginHandler{
+		verifier:    verifier,
+		redirectURI: apiURL + "/auth/callback",
+		secure:      strings.HasPrefix(apiURL, "https://"),
+	}
+}
+
+// Login sends the browser to the OIDC provider. After the login it
+// returns to the local path in the redirect parameter.
+func (h *LoginHandler) Login(w http.ResponseWriter, r *http.Request) {
+	redirect := r.URL.Query().Get("redirect")
+	if !localPath(redirect) {
+		redirect = "/"
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		slog.Error("error generating login state", slog.Any("error", err))
+		sendErrorResponse(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		slog.Error("error generating login state", slog.Any("error", err))
+		sendErrorResponse(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	authURL, err := h.verifier.AuthCodeURL(
+		r.Context(), h.redirectURI, state, verifier,
+	)
+	if err != nil {
+		slog.Error("error starting login", slog.Any("error", err))
+		sendErrorResponse(w, http.StatusBadGateway, "identity provider unavailable")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name: loginCookie,
+		Value: strings.Join([]string{
+			state,
+			verifier,
+			base64.RawURLEncoding.EncodeToString([]byte(redirect)),
+		}, "."),
+		Path:     "/auth",
+		MaxAge:   int(loginTimeout.Seconds()),
+		HttpOnly: true,
+		Secure:   h.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback completes a login and stores the ID token in the session
+// cookie.
+func (h *LoginHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		slog.Warn("login failed",
+			slog.String("error", e),
+			slog.String("description", query.Get("error_description")),
+		)
+		sendErrorResponse(w, http.StatusUnauthorized, "login failed: "+e)
+		return
+	}
+
+	cookie, err := r.Cookie(loginCookie)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "no login in progress")
+		return
+	}
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 || parts[0] != query.Get("state") {
+		sendErrorResponse(w, http.StatusBadRequest, "invalid login state")
+		return
+	}
+	redirect, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !localPath(string(redirect)) {
+		redirect = []byte("/")
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginCookie,
+		Path:     "/auth",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   h.secure,
+	})
+
+	idToken, err := h.verifier.Exchange(
+		r.Context(), h.redirectURI, query.Get("code"), parts[1],
+	)
+	if err != nil {
+		slog.Error("error completing login", slog.Any("error", err))
+		sendErrorResponse(w, http.StatusUnauthorized, "login failed")
+		return
+	}
+
+	identity, err := h.verifier.Verify(r.Context(), idToken)
+	if err != nil {
+		slog.Error("error verifying id token", slog.Any("error", err))
+		sendErrorResponse(w, http.StatusUnauthorized, "login failed")
+		return
+	}
+
+	slog.Info("user logged in",
+		slog.String("name", identity.Name),
+		slog.Any("groups", identity.Groups),
+	)
+
+	// SameSite=Lax keeps browsers from sending the session with
+	// cross-site API requests other than top-level navigation.
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    idToken,
+		Path:     "/",
+		Expires:  time.Unix(identity.ExpiresAt, 0),
+		HttpOnly: true,
+		Secure:   h.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, string(redirect), http.StatusFound)
+}
+
+// Logout drops the session cookie. The provider session is left as is.
+func (h *LoginHandler) Logout(w http.ResponseWriter, r *http.Request) { //nolint:revive
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   h.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	sendSuccessResponse(w, "logged out")
+}
+
+// localPath reports whether redirect is a path on this server, so logins
+// cannot be used to redirect elsewhere.
+func localPath(redirect string) bool {
+	return strings.HasPrefix(redirect, "/") &&
+		!strings.HasPrefix(redirect, "//") &&
+		!strings.HasPrefix(redirect, "/\\")
+}
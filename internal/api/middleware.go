@@ -1,37 +1,294 @@
 package api
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/base64"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/internal/oidc"
 )
 
 func (a *API) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		serverAPIKey := a.Config.AdminAPIKey
+		ctx, ok := a.authenticate(w, r)
+		if !ok {
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		authHeader := r.Header.Get("Authorization")
+// optionalAuthMiddleware authenticates requests that carry an
+// Authorization header or a valid login session and requires scope from
+// them. Other requests pass through anonymously.
+func (a *API) optionalAuthMiddleware(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				if actor, ok := a.sessionActor(r); ok {
+					r = r.WithContext(model.WithActor(r.Context(), actor))
+					requireScope(scope)(next).ServeHTTP(w, r)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
 
-		if authHeader == "" || !strings.HasPrefix(authHeader, "Basic ") {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			ctx, ok := a.authenticate(w, r)
+			if !ok {
+				return
+			}
+			requireScope(scope)(next).ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// requireScope rejects requests whose actor is not granted scope. It runs
+// after authMiddleware.
+func requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			actor, _ := model.ActorFromContext(r.Context())
+			if !actor.HasScope(scope) {
+				slog.Warn("actor lacks scope",
+					slog.String("actor", actor.Name),
+					slog.String("scope", scope),
+				)
+				sendErrorResponse(w, http.StatusForbidden,
+					"missing the "+scope+" scope")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// sessionMiddleware attaches the actor of a valid login session to the
+// request. Requests without one pass through anonymously.
+func (a *API) sessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if actor, ok := a.sessionActor(r); ok {
+			r = r.WithContext(model.WithActor(r.Context(), actor))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// pageAuthMiddleware authenticates requests for HTML pages. Browsers
+// without credentials are sent to the OIDC login if it is configured, and
+// asked for Basic auth with an API key as the password otherwise.
+func (a *API) pageAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" && !a.missingClientCert(r) {
+			if actor, ok := a.sessionActor(r); ok {
+				next.ServeHTTP(w, r.WithContext(
+					model.WithActor(r.Context(), actor),
+				))
+				return
+			}
+
+			if a.oidc != nil && a.oidc.LoginEnabled() {
+				http.Redirect(w, r,
+					"/auth/login?redirect="+url.QueryEscape(r.URL.RequestURI()),
+					http.StatusFound,
+				)
+				return
+			}
+		}
+
+		// The challenge lets browsers ask again for rejected credentials.
+		w.Header().Set("WWW-Authenticate", `Basic realm="env-cleaner"`)
+		ctx, ok := a.authenticate(w, r)
+		if !ok {
 			return
 		}
+		w.Header().Del("WWW-Authenticate")
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticate checks the credentials of r and returns its context with
+// their actor. Named keys and OIDC tokens are sent as Bearer tokens, the
+// legacy admin_api_key with Basic auth. Browsers send an API key as the
+// Basic auth password, or a session cookie once logged in with OIDC. With
+// require_client_cert no credential is checked without a verified client
+// certificate. On failure the error response is already written.
+func (a *API) authenticate(
+	w http.ResponseWriter,
+	r *http.Request,
+) (context.Context, bool) {
+	authHeader := r.Header.Get("Authorization")
+	bearer := strings.TrimPrefix(authHeader, "Bearer ")
+
+	var actor model.Actor
+	switch {
+	case a.missingClientCert(r):
+		slog.Warn("rejected credentials without client certificate",
+			slog.String("path", r.URL.Path),
+		)
+		sendErrorResponse(
+			w, http.StatusForbidden, "Client certificate required",
+		)
+		return nil, false
+	case authHeader == "" && a.oidc != nil:
+		var ok bool
+		if actor, ok = a.sessionActor(r); !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return nil, false
+		}
+	case strings.HasPrefix(authHeader, "Bearer ") &&
+		a.oidc != nil && oidc.IsJWT(bearer):
+		identity, err := a.oidc.Verify(r.Context(), bearer)
+		if err != nil {
+			slog.Error("error verifying oidc token", slog.Any("error", err))
+			sendErrorResponse(w, http.StatusUnauthorized, "Invalid token")
+			return nil, false
+		}
+
+		actor = a.identityActor(identity)
+	case strings.HasPrefix(authHeader, "Bearer "):
+		key, err := a.keyService.Authenticate(r.Context(), bearer)
+		if err != nil {
+			slog.Error("error authenticating api key", slog.Any("error", err))
+			sendErrorResponse(w, http.StatusUnauthorized, "Invalid API key")
+			return nil, false
+		}
 
+		actor = keyActor(key)
+	case strings.HasPrefix(authHeader, "Basic "):
 		encodedKey := strings.TrimPrefix(authHeader, "Basic ")
 		decodedKeyBytes, err := base64.StdEncoding.DecodeString(encodedKey)
 		if err != nil {
 			slog.Error("error decoding base64", slog.Any("error", err))
 			sendErrorResponse(w, http.StatusBadRequest, "Invalid base64 encoding")
-			return
+			return nil, false
+		}
+		credentials := string(decodedKeyBytes)
+
+		if a.isAdminKey(credentials) {
+			actor = adminActor()
+			break
 		}
 
-		if string(decodedKeyBytes) != serverAPIKey {
-			slog.Error("invalid API key", slog.String("key", string(decodedKeyBytes)))
+		// Browsers send a user name, which is ignored, and the key as the
+		// password. They attach cached credentials to requests from other
+		// sites too, so those may only read.
+		_, password, found := strings.Cut(credentials, ":")
+		if !found {
+			slog.Error("invalid admin API key")
 			sendErrorResponse(w, http.StatusUnauthorized, "Invalid API key")
-			return
+			return nil, false
+		}
+		if crossSite(r) && r.Method != http.MethodGet {
+			slog.Warn("rejected cross-site request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+			)
+			sendErrorResponse(w, http.StatusForbidden, "Cross-site request")
+			return nil, false
 		}
 
-		next.ServeHTTP(w, r)
-	})
+		if a.isAdminKey(password) {
+			actor = adminActor()
+			break
+		}
+		key, err := a.keyService.Authenticate(r.Context(), password)
+		if err != nil {
+			slog.Error("error authenticating api key", slog.Any("error", err))
+			sendErrorResponse(w, http.StatusUnauthorized, "Invalid API key")
+			return nil, false
+		}
+		actor = keyActor(key)
+	default:
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	return model.WithActor(r.Context(), actor), true
+}
+
+// missingClientCert reports whether r lacks the verified client
+// certificate required by require_client_cert.
+func (a *API) missingClientCert(r *http.Request) bool {
+	return a.Config.Listen.TLS.RequireClientCert && !hasClientCert(r)
+}
+
+// isAdminKey reports whether key is the legacy admin_api_key, if enabled.
+func (a *API) isAdminKey(key string) bool {
+	return a.Config.AdminAPIKey != "" && subtle.ConstantTimeCompare(
+		[]byte(key), []byte(a.Config.AdminAPIKey),
+	) == 1
+}
+
+func adminActor() model.Actor {
+	return model.Actor{
+		Name:   model.ActorAdmin,
+		Source: model.AuditSourceAPI,
+		Scopes: []string{model.ScopeAdmin},
+	}
+}
+
+func keyActor(key *model.APIKey) model.Actor {
+	return model.Actor{
+		Name:   key.Name,
+		Source: model.AuditSourceAPI,
+		Scopes: key.Scopes,
+	}
+}
+
+// crossSite reports whether a browser sent r from another site.
+func crossSite(r *http.Request) bool {
+	return r.Header.Get("Sec-Fetch-Site") == "cross-site"
+}
+
+// sessionActor returns the actor of the OIDC login session of r. The
+// second value is false if there is no valid session.
+func (a *API) sessionActor(r *http.Request) (model.Actor, bool) {
+	if a.oidc == nil || a.missingClientCert(r) {
+		return model.Actor{}, false
+	}
+
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return model.Actor{}, false
+	}
+
+	identity, err := a.oidc.Verify(r.Context(), cookie.Value)
+	if err != nil {
+		slog.Warn("invalid login session", slog.Any("error", err))
+		return model.Actor{}, false
+	}
+
+	return a.identityActor(identity), true
+}
+
+// identityActor maps an OIDC identity to an actor. Members of the admin
+// groups are admins, everyone else may read, extend and delete their own
+// environments.
+func (a *API) identityActor(identity *oidc.Identity) model.Actor {
+	for _, group := range identity.Groups {
+		if slices.Contains(a.Config.OIDC.AdminGroups, group) {
+			return model.Actor{
+				Name:   identity.Name,
+				Source: model.AuditSourceAPI,
+				Scopes: []string{model.ScopeAdmin},
+			}
+		}
+	}
+
+	return model.Actor{
+		Name:   identity.Name,
+		Source: model.AuditSourceAPI,
+		Scopes: []string{
+			model.ScopeRead, model.ScopeExtend, model.ScopeDelete,
+		},
+		Owner: identity.Name,
+	}
 }
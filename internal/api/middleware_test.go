@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fragpit/env-cleaner/internal/config"
+	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/internal/oidc"
+)
+
+// fakeVerifier accepts the token "valid.oidc.token" and counts
+// verifications.
+type fakeVerifier struct {
+	calls int
+}
+
+func (v *fakeVerifier) Verify(
+	_ context.Context,
+	token string,
+) (*oidc.Identity, error) {
+	v.calls++
+	if token != "valid.oidc.token" {
+		return nil, oidc.ErrInvalidToken
+	}
+	return &oidc.Identity{Name: "ivanov"}, nil
+}
+
+func (v *fakeVerifier) LoginEnabled() bool { return false }
+
+func (v *fakeVerifier) AuthCodeURL(
+	context.Context, string, string, string,
+) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (v *fakeVerifier) Exchange(
+	context.Context, string, string, string,
+) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+// fakeKeyService accepts the key "valid-key".
+type fakeKeyService struct{}
+
+func (fakeKeyService) Authenticate(
+	_ context.Context,
+	token string,
+) (*model.APIKey, error) {
+	if token != "valid-key" {
+		return nil, errors.New("invalid key")
+	}
+	return &model.APIKey{Name: "ci", Scopes: []string{model.ScopeRead}}, nil
+}
+
+func TestAuthenticateRequiresClientCert(t *testing.T) {
+	withCert := &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{}}},
+	}
+
+	tests := []struct {
+		name       string
+		header     string
+		cookie     string
+		tls        *tls.ConnectionState
+		wantStatus int
+		wantVerify int
+	}{
+		{
+			name:       "api key without certificate",
+			header:     "Bearer valid-key",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "oidc token without certificate",
+			header:     "Bearer valid.oidc.token",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "session without certificate",
+			cookie:     "valid.oidc.token",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "api key with certificate",
+			header:     "Bearer valid-key",
+			tls:        withCert,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "oidc token with certificate",
+			header:     "Bearer valid.oidc.token",
+			tls:        withCert,
+			wantStatus: http.StatusOK,
+			wantVerify: 1,
+		},
+		{
+			name:       "session with certificate",
+			cookie:     "valid.oidc.token",
+			tls:        withCert,
+			wantStatus: http.StatusOK,
+			wantVerify: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.ServerConfig{}
+			cfg.Listen.TLS.RequireClientCert = true
+			verifier := &fakeVerifier{}
+			a := New(cfg, nil, nil, fakeKeyService{}, nil, nil, verifier)
+
+			handler := a.authMiddleware(http.HandlerFunc(func(
+				w http.ResponseWriter,
+				_ *http.Request,
+			) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/environments", nil)
+			req.TLS = tt.tls
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: sessionCookie, Value: tt.cookie})
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if verifier.calls != tt.wantVerify {
+				t.Errorf("token verified %d times, want %d",
+					verifier.calls, tt.wantVerify)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -9,9 +10,11 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/fragpit/env-cleaner/internal/config"
 	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/internal/oidc"
 )
 
 const (
@@ -19,8 +22,22 @@ const (
 )
 
 type EnvironmentService interface {
-	GetEnvironments(ctx context.Context) ([]*model.Environment, error)
+	GetEnvironments(
+		ctx context.Context,
+		filter *model.EnvironmentFilter,
+		cursor string,
+	) ([]*model.Environment, string, error)
+	GetEnvironment(ctx context.Context, envID string) (*model.Environment, error)
 	AddEnvironment(ctx context.Context, env *model.Environment, ttl string) error
+	UpdateEnvironment(
+		ctx context.Context,
+		envID, owner, ttl, deleteAt string,
+	) (*model.Environment, error)
+	DeleteEnvironment(
+		ctx context.Context,
+		envID string,
+		forget bool,
+	) (*model.Environment, error)
 	GetEnvironmentForExtend(
 		ctx context.Context,
 		envID, token string,
@@ -29,20 +46,91 @@ type EnvironmentService interface {
 		ctx context.Context,
 		envID, period, token string,
 	) (*model.Environment, error)
+	AdminExtendEnvironment(
+		ctx context.Context,
+		envID, period, deleteAt string,
+		ignoreMax bool,
+	) (*model.Environment, error)
+	ProtectEnvironment(
+		ctx context.Context,
+		envID, period, reason string,
+	) (*model.Environment, error)
+	UnprotectEnvironment(
+		ctx context.Context,
+		envID string,
+	) (*model.Environment, error)
+	BatchEnvironments(
+		ctx context.Context,
+		op *model.BatchOperation,
+	) ([]*model.BatchResult, error)
+}
+
+type AuditService interface {
+	GetAuditEntries(
+		ctx context.Context,
+		filter *model.AuditFilter,
+	) ([]*model.AuditEntry, error)
+}
+
+type APIKeyService interface {
+	Authenticate(ctx context.Context, token string) (*model.APIKey, error)
+}
+
+type HealthService interface {
+	Liveness(ctx context.Context) *model.HealthReport
+	Readiness(ctx context.Context) *model.HealthReport
+}
+
+type WebhookService interface {
+	HandleMergeRequest(
+		ctx context.Context,
+		ev *model.MergeRequestEvent,
+	) ([]*model.BatchResult, error)
+}
+
+// OIDCVerifier verifies OIDC tokens and runs browser logins.
+type OIDCVerifier interface {
+	Verify(ctx context.Context, token string) (*oidc.Identity, error)
+	LoginEnabled() bool
+	AuthCodeURL(
+		ctx context.Context,
+		redirectURI, state, codeVerifier string,
+	) (string, error)
+	Exchange(
+		ctx context.Context,
+		redirectURI, code, codeVerifier string,
+	) (string, error)
 }
 
 type API struct {
-	Config  config.ServerConfig
-	service EnvironmentService
+	Config       config.ServerConfig
+	service      EnvironmentService
+	auditService AuditService
+	keyService   APIKeyService
+	health       HealthService
+	// webhooks is nil if webhooks are disabled.
+	webhooks WebhookService
+	// oidc is nil if OIDC authentication is disabled.
+	oidc OIDCVerifier
 }
 
 func New(
 	cfg *config.ServerConfig,
 	svc EnvironmentService,
+	auditSvc AuditService,
+	keySvc APIKeyService,
+	healthSvc HealthService,
+	webhookSvc WebhookService,
+	verifier OIDCVerifier,
 ) *API {
 	return &API{
-		Config:  *cfg,
-		service: svc,
+		Config:       *cfg,
+		service:      svc,
+		auditService: auditSvc,
+		keyService:   keySvc,
+		health:       healthSvc,
+		webhooks:     webhookSvc,
+		oidc:         verifier,
 	}
 }
 
@@ -55,51 +143,133 @@ func (a *API) Run(ctx context.Context) error {
 	r.Use(middleware.CleanPath)
 
 	envHandler := NewEnvironmentHandler(a.service)
+	auditHandler := NewAuditHandler(a.auditService)
+	loginEnabled := a.oidc != nil && a.oidc.LoginEnabled()
 	extendPage := NewExtendPageHandler(
 		a.service,
 		a.Config.StaleThreshold,
 		a.Config.MaxExtendDuration,
+		loginEnabled,
+	)
+
+	dashboard := NewDashboardHandler(
+		a.service,
+		a.auditService,
+		a.Config.StaleThreshold,
+		a.Config.MaxExtendDuration,
 	)
 
+	if loginEnabled {
+		login := NewLoginHandler(a.oidc, a.Config.APIURL)
+		r.Get("/auth/login", login.Login)
+		r.Get("/auth/callback", login.Callback)
+		r.Get("/auth/logout", login.Logout)
+	}
+
 	r.Group(func(r chi.Router) {
-		r.Get("/extend", extendPage.ServePage)
+		r.With(a.sessionMiddleware).Get("/extend", extendPage.ServePage)
 		r.Get("/extend/static/extend.css", extendPage.ServeCSS)
 		r.Get("/extend/static/extend.js", extendPage.ServeJS)
+		r.With(a.pageAuthMiddleware, requireScope(model.ScopeRead)).
+			Get("/dashboard", dashboard.ServePage)
+		r.Get("/dashboard/static/dashboard.css", dashboard.ServeCSS)
+		r.Get("/dashboard/static/dashboard.js", dashboard.ServeJS)
 	})
 
 	r.Group(func(r chi.Router) {
 		r.Use(a.authMiddleware)
-		r.Get("/api/environments", envHandler.GetEnvironments)
-		r.Post("/api/environments", envHandler.AddEnvironment)
+
+		r.Group(func(r chi.Router) {
+			r.Use(requireScope(model.ScopeRead))
+			r.Get("/api/environments", envHandler.GetEnvironments)
+			r.Get("/api/environments/{id}", envHandler.GetEnvironment)
+			r.Get("/api/audit", auditHandler.GetAuditEntries)
+			// The scope of the action is checked by the service.
+			r.Post("/api/environments/batch", envHandler.BatchEnvironments)
+		})
+
+		r.With(requireScope(model.ScopeRegister)).
+			Post("/api/environments", envHandler.AddEnvironment)
+
+		r.With(requireScope(model.ScopeDelete)).
+			Delete("/api/environments/{id}", envHandler.DeleteEnvironment)
+
+		r.Group(func(r chi.Router) {
+			r.Use(requireScope(model.ScopeAdmin))
+			r.Patch("/api/environments/{id}", envHandler.UpdateEnvironment)
+			r.Post("/api/environments/{id}/protect", envHandler.ProtectEnvironment)
+			r.Delete("/api/environments/{id}/protect", envHandler.UnprotectEnvironment)
+		})
 	})
 
 	r.Group(func(r chi.Router) {
-		r.Post("/api/environments/{id}/extend", envHandler.ExtendEnvironment)
+		r.With(a.optionalAuthMiddleware(model.ScopeExtend)).
+			Post("/api/environments/{id}/extend", envHandler.ExtendEnvironment)
 		r.Get("/api/openapi.yaml", serveOpenAPISpec)
 	})
 
-	srv := &http.Server{
-		Addr:         ":8080",
-		Handler:      r,
-		ReadTimeout:  60 * time.Second,
-		WriteTimeout: 60 * time.Second,
+	// Webhooks are authenticated by their signature.
+	if a.webhooks != nil {
+		webhooks := NewWebhookHandler(a.webhooks, a.Config.Webhooks.Secret)
+		r.Post("/api/webhooks/{provider}", webhooks.Receive)
+	}
+
+	srv, err := newServer(ctx, a.Config.Listen, r)
+	if err != nil {
+		return fmt.Errorf("error configuring listener: %w", err)
 	}
+	servers := []*http.Server{srv}
 
-	errChan := make(chan error, 1)
-	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			slog.Error("failed to start server", slog.Any("error", err))
-			errChan <- fmt.Errorf("failed to start server: %w", err)
+	// Metrics and health checks move to the internal listener when one is
+	// configured.
+	healthHandler := NewHealthHandler(a.health)
+	if a.Config.InternalListen.Address != "" {
+		internal := chi.NewRouter()
+		internal.Handle("/metrics", promhttp.Handler())
+		internal.Get("/healthz", healthHandler.Liveness)
+		internal.Get("/readyz", healthHandler.Readiness)
+
+		srv, err := newServer(ctx, a.Config.InternalListen, internal)
+		if err != nil {
+			return fmt.Errorf("error configuring internal listener: %w", err)
 		}
-	}()
+		servers = append(servers, srv)
+	} else {
+		r.Handle("/metrics", promhttp.Handler())
+		r.Get("/healthz", healthHandler.Liveness)
+		r.Get("/readyz", healthHandler.Readiness)
+	}
+
+	errChan := make(chan error, len(servers))
+	for _, srv := range servers {
+		slog.Info("listening",
+			slog.String("address", srv.Addr),
+			slog.Bool("tls", srv.TLSConfig != nil),
+		)
+		go func() {
+			if err := serve(srv); err != nil {
+				slog.Error("failed to start server", slog.Any("error", err))
+				errChan <- fmt.Errorf("failed to start server: %w", err)
+			}
+		}()
+	}
 
 	select {
 	case <-ctx.Done():
 		slog.Info("received shutdown signal, shutting down API service")
-		ctx, cancel := context.WithTimeout(ctx, apiShutdownTimeout)
+		ctx, cancel := context.WithTimeout(
+			context.WithoutCancel(ctx),
+			apiShutdownTimeout,
+		)
 		defer cancel()
 
-		if err := srv.Shutdown(ctx); err != nil {
+		var errs []error
+		for _, srv := range servers {
+			if err := srv.Shutdown(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
 			slog.Error(
 				"failed to shutdown API service gracefully",
 				slog.Any("error", err),
@@ -110,11 +280,10 @@ func (a *API) Run(ctx context.Context) error {
 		slog.Info("API service shut down gracefully")
 		return nil
 	case err := <-errChan:
-		if err != nil {
-			slog.Error("API service encountered an error", slog.Any("error", err))
-			return err
+		for _, srv := range servers {
+			_ = srv.Close()
 		}
+		slog.Error("API service encountered an error", slog.Any("error", err))
+		return err
 	}
-
-	return nil
 }
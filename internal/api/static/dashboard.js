@@ -0,0 +1,100 @@
+function formatCountdown(seconds) {
+  if (seconds <= 0) {
+    return "due";
+  }
+
+  var days = Math.floor(seconds / 86400);
+  var hours = Math.floor((seconds % 86400) / 3600);
+  var minutes = Math.floor((seconds % 3600) / 60);
+  var secs = seconds % 60;
+
+  if (days > 0) {
+    return days + "d " + hours + "h " + minutes + "m";
+  }
+  return hours + "h " + minutes + "m " + secs + "s";
+}
+
+function updateCountdowns() {
+  var now = Math.floor(Date.now() / 1000);
+  document.querySelectorAll(".countdown").forEach(function (cell) {
+    var left = parseInt(cell.dataset.deleteAt, 10) - now;
+    cell.textContent = formatCountdown(left);
+    cell.classList.toggle("overdue", left <= 0);
+  });
+}
+
+function setButtonsDisabled(disabled) {
+  document.querySelectorAll(".btn").forEach(function (btn) {
+    btn.disabled = disabled;
+  });
+}
+
+function showError(msg) {
+  var resultDiv = document.getElementById("result");
+  resultDiv.className = "result-error";
+  resultDiv.textContent = msg;
+  setButtonsDisabled(false);
+}
+
+function send(method, path, body) {
+  var options = {
+    method: method,
+    credentials: "same-origin",
+    headers: { "Content-Type": "application/json" }
+  };
+  if (body) {
+    options.body = JSON.stringify(body);
+  }
+
+  setButtonsDisabled(true);
+  fetch(path, options)
+    .then(function (resp) {
+      return resp.json().then(function (data) {
+        return { ok: resp.ok, data: data };
+      });
+    })
+    .then(function (result) {
+      if (result.ok && result.data.success) {
+        window.location.reload();
+        return;
+      }
+
+      var msg = "Request failed";
+      if (result.data.error) {
+        msg = result.data.error.message || msg;
+      }
+      showError(msg);
+    })
+    .catch(function () {
+      showError("Network error. Please try again.");
+    });
+}
+
+function extend(envID, period) {
+  send(
+    "POST",
+    "/api/environments/" + encodeURIComponent(envID) + "/extend",
+    { period: period }
+  );
+}
+
+function deleteNow(envID, name) {
+  if (!window.confirm("Delete " + name + " now? This cannot be undone.")) {
+    return;
+  }
+
+  send("DELETE", "/api/environments/" + encodeURIComponent(envID));
+}
+
+document.querySelectorAll(".btn").forEach(function (btn) {
+  btn.addEventListener("click", function () {
+    if (btn.dataset.period) {
+      extend(btn.dataset.envId, btn.dataset.period);
+    } else {
+      deleteNow(btn.dataset.envId, btn.dataset.name);
+    }
+  });
+});
+
+updateCountdowns();
+setInterval(updateCountdowns, 1000);
@@ -7,7 +7,8 @@ function extend(period) {
   fetch("/api/environments/" + encodeURIComponent(envID) + "/extend", {
     method: "POST",
     headers: {
-      "Content-Type": "application/json"
+      "Content-Type": "application/json",
+      "X-Extend-Page": "1"
     },
     body: JSON.stringify({
       period: period,
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/pkg/apitypes"
+)
+
+// maxWebhookBodySize limits the size of webhook payloads.
+const maxWebhookBodySize = 5 << 20
+
+// errInvalidSignature is returned for events not signed with the secret.
+var errInvalidSignature = errors.New("invalid signature")
+
+type WebhookHandler struct {
+	service WebhookService
+	secret  string
+}
+
+func NewWebhookHandler(svc WebhookService, secret string) *WebhookHandler {
+	return &WebhookHandler{service: svc, secret: secret}
+}
+
+// Receive handles merge request events of the provider in the path. Other
+// events and merge requests that are still open are acknowledged and
+// ignored.
+func (h *WebhookHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	provider := r.PathValue("provider")
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+	if err != nil {
+		slog.Error("error reading webhook", slog.Any("error", err))
+		sendErrorResponse(w, http.StatusBadRequest, "error reading request")
+		return
+	}
+
+	var ev *model.MergeRequestEvent
+	switch provider {
+	case model.ProviderGitHub:
+		ev, err = h.parseGitHubEvent(r, body, "X-GitHub-Event")
+	case model.ProviderGitea:
+		ev, err = h.parseGitHubEvent(r, body, "X-Gitea-Event")
+	case model.ProviderGitLab:
+		ev, err = h.parseGitLabEvent(r, body)
+	default:
+		sendErrorResponse(w, http.StatusNotFound, "unknown provider: "+provider)
+		return
+	}
+	if errors.Is(err, errInvalidSignature) {
+		slog.Warn("rejected webhook with invalid signature",
+			slog.String("provider", provider),
+		)
+		sendErrorResponse(w, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
+	if err != nil {
+		slog.Error("error decoding webhook",
+			slog.String("provider", provider),
+			slog.Any("error", err),
+		)
+		sendErrorResponse(w, http.StatusBadRequest, "error decoding request")
+		return
+	}
+
+	if ev == nil {
+		sendSuccessResponse(w, &apitypes.WebhookResponse{Ignored: true})
+		return
+	}
+	ev.Provider = provider
+
+	results, err := h.service.HandleMergeRequest(r.Context(), ev)
+	if err != nil {
+		handleServiceError(w, err, fmt.Sprintf("%s!%d", ev.Project, ev.Number))
+		return
+	}
+
+	resp := NewWebhookResponse(ev, results)
+	slog.Info("handled merge request webhook",
+		slog.String("provider", provider),
+		slog.String("project", ev.Project),
+		slog.Int("number", ev.Number),
+		slog.String("state", ev.State),
+		slog.Int("environments", len(resp.Items)),
+	)
+
+	sendSuccessResponse(w, resp)
+}
+
+type githubPullRequestEvent struct {
+	Action      string `json:"action"`
+	PullRequest struct {
+		Number int  `json:"number"`
+		Merged bool `json:"merged"`
+		Head   struct {
+			Ref string `json:"ref"`
+		} `json:"head"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// parseGitHubEvent parses a pull request event of GitHub, or of Gitea,
+// which sends the same payload. Both sign the body with HMAC-SHA256, sent
+// in X-Hub-Signature-256 with a sha256= prefix, and by Gitea in
+// X-Gitea-Signature without one.
+func (h *WebhookHandler) parseGitHubEvent(
+	r *http.Request,
+	body []byte,
+	eventHeader string,
+) (*model.MergeRequestEvent, error) {
+	signature := strings.TrimPrefix(
+		r.Header.Get("X-Hub-Signature-256"), "sha256=",
+	)
+	if signature == "" {
+		signature = r.Header.Get("X-Gitea-Signature")
+	}
+	if !h.validSignature(body, signature) {
+		return nil, errInvalidSignature
+	}
+
+	if r.Header.Get(eventHeader) != "pull_request" {
+		return nil, nil
+	}
+
+	var payload githubPullRequestEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Action != "closed" {
+		return nil, nil
+	}
+
+	state := model.MergeRequestClosed
+	if payload.PullRequest.Merged {
+		state = model.MergeRequestMerged
+	}
+
+	return &model.MergeRequestEvent{
+		Project:      payload.Repository.FullName,
+		Number:       payload.PullRequest.Number,
+		SourceBranch: payload.PullRequest.Head.Ref,
+		State:        state,
+	}, nil
+}
+
+type gitlabMergeRequestEvent struct {
+	ObjectKind string `json:"object_kind"`
+	Project    struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+	ObjectAttributes struct {
+		IID          int    `json:"iid"`
+		SourceBranch string `json:"source_branch"`
+		Action       string `json:"action"`
+	} `json:"object_attributes"`
+}
+
+// parseGitLabEvent parses a merge request event of GitLab, which sends the
+// secret as is in X-Gitlab-Token.
+func (h *WebhookHandler) parseGitLabEvent(
+	r *http.Request,
+	body []byte,
+) (*model.MergeRequestEvent, error) {
+	if subtle.ConstantTimeCompare(
+		[]byte(r.Header.Get("X-Gitlab-Token")), []byte(h.secret),
+	) != 1 {
+		return nil, errInvalidSignature
+	}
+
+	var payload gitlabMergeRequestEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.ObjectKind != "merge_request" {
+		return nil, nil
+	}
+
+	var state string
+	switch payload.ObjectAttributes.Action {
+	case "merge":
+		state = model.MergeRequestMerged
+	case "close":
+		state = model.MergeRequestClosed
+	default:
+		return nil, nil
+	}
+
+	return &model.MergeRequestEvent{
+		Project:      payload.Project.PathWithNamespace,
+		Number:       payload.ObjectAttributes.IID,
+		SourceBranch: payload.ObjectAttributes.SourceBranch,
+		State:        state,
+	}, nil
+}
+
+// validSignature reports whether signature is the hex HMAC-SHA256 of body
+// with the secret.
+func (h *WebhookHandler) validSignature(body []byte, signature string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(h.secret))
+	mac.Write(body)
+
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/pkg/apitypes"
+)
+
+const testWebhookSecret = "s3cret"
+
+// fakeWebhookService records the events passed to it.
+type fakeWebhookService struct {
+	events  []*model.MergeRequestEvent
+	results []*model.BatchResult
+}
+
+func (s *fakeWebhookService) HandleMergeRequest(
+	_ context.Context,
+	ev *model.MergeRequestEvent,
+) ([]*model.BatchResult, error) {
+	s.events = append(s.events, ev)
+	return s.results, nil
+}
+
+func readWebhookFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join("testdata", "webhooks", name))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	return body
+}
+
+// hmacSignature returns the hex HMAC-SHA256 of body with secret.
+func hmacSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func githubHeaders(event string, body []byte) map[string]string {
+	return map[string]string{
+		"X-GitHub-Event":      event,
+		"X-Hub-Signature-256": "sha256=" + hmacSignature(testWebhookSecret, body),
+	}
+}
+
+func giteaHeaders(event string, body []byte) map[string]string {
+	return map[string]string{
+		"X-Gitea-Event":     event,
+		"X-Gitea-Signature": hmacSignature(testWebhookSecret, body),
+	}
+}
+
+func gitlabHeaders(string, []byte) map[string]string {
+	return map[string]string{
+		"X-Gitlab-Event": "Merge Request Hook",
+		"X-Gitlab-Token": testWebhookSecret,
+	}
+}
+
+func sendWebhook(
+	t *testing.T,
+	svc WebhookService,
+	provider string,
+	body []byte,
+	headers map[string]string,
+) *httptest.ResponseRecorder {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"POST /api/webhooks/{provider}",
+		NewWebhookHandler(svc, testWebhookSecret).Receive,
+	)
+
+	req := httptest.NewRequest(
+		http.MethodPost, "/api/webhooks/"+provider, bytes.NewReader(body),
+	)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestWebhookMergeRequestEvents(t *testing.T) {
+	merged := &model.MergeRequestEvent{
+		Project:      "team/app",
+		Number:       42,
+		SourceBranch: "Feature/Review_Apps",
+		State:        model.MergeRequestMerged,
+	}
+	closed := *merged
+	closed.State = model.MergeRequestClosed
+
+	for _, tc := range []struct {
+		name     string
+		provider string
+		fixture  string
+		event    string
+		headers  func(event string, body []byte) map[string]string
+		want     *model.MergeRequestEvent
+	}{
+		{"github merged", model.ProviderGitHub, "github_pull_request_merged.json",
+			"pull_request", githubHeaders, merged},
+		{"github closed", model.ProviderGitHub, "github_pull_request_closed.json",
+			"pull_request", githubHeaders, &closed},
+		{"github opened", model.ProviderGitHub, "github_pull_request_opened.json",
+			"pull_request", githubHeaders, nil},
+		{"github other event", model.ProviderGitHub, "github_pull_request_merged.json",
+			"push", githubHeaders, nil},
+		{"gitea merged", model.ProviderGitea, "gitea_pull_request_merged.json",
+			"pull_request", giteaHeaders, merged},
+		{"gitea closed", model.ProviderGitea, "gitea_pull_request_closed.json",
+			"pull_request", giteaHeaders, &closed},
+		{"gitea reopened", model.ProviderGitea, "gitea_pull_request_reopened.json",
+			"pull_request", giteaHeaders, nil},
+		{"gitea with github signature", model.ProviderGitea, "gitea_pull_request_merged.json",
+			"pull_request", func(event string, body []byte) map[string]string {
+				headers := githubHeaders("", body)
+				headers["X-Gitea-Event"] = event
+				return headers
+			}, merged},
+		{"gitlab merge", model.ProviderGitLab, "gitlab_merge_request_merge.json",
+			"", gitlabHeaders, merged},
+		{"gitlab close", model.ProviderGitLab, "gitlab_merge_request_close.json",
+			"", gitlabHeaders, &closed},
+		{"gitlab open", model.ProviderGitLab, "gitlab_merge_request_open.json",
+			"", gitlabHeaders, nil},
+		{"gitlab push", model.ProviderGitLab, "gitlab_push.json",
+			"", gitlabHeaders, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &fakeWebhookService{results: []*model.BatchResult{{
+				EnvID:  "1700000000",
+				Env:    &model.Environment{EnvID: "1700000000", Name: "review-feature-review-apps"},
+				Result: model.BatchResultOK,
+			}}}
+			body := readWebhookFixture(t, tc.fixture)
+
+			rec := sendWebhook(t, svc, tc.provider, body, tc.headers(tc.event, body))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+			}
+
+			var resp struct {
+				Data apitypes.WebhookResponse `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+
+			if tc.want == nil {
+				if len(svc.events) != 0 {
+					t.Fatalf("service called with %+v, want the event ignored", svc.events[0])
+				}
+				if !resp.Data.Ignored {
+					t.Errorf("response %+v is not ignored", resp.Data)
+				}
+				return
+			}
+
+			if len(svc.events) != 1 {
+				t.Fatalf("service called %d times, want once", len(svc.events))
+			}
+			want := *tc.want
+			want.Provider = tc.provider
+			if got := *svc.events[0]; got != want {
+				t.Errorf("event = %+v, want %+v", got, want)
+			}
+			if resp.Data.Ignored || resp.Data.State != want.State ||
+				resp.Data.Project != want.Project || resp.Data.Number != want.Number {
+				t.Errorf("response = %+v, want the %s event", resp.Data, want.State)
+			}
+			if len(resp.Data.Items) != 1 || resp.Data.Items[0].Result != model.BatchResultOK {
+				t.Errorf("items = %+v, want the scheduled environment", resp.Data.Items)
+			}
+		})
+	}
+}
+
+func TestWebhookRejectsInvalidSignatures(t *testing.T) {
+	github := readWebhookFixture(t, "github_pull_request_merged.json")
+	gitea := readWebhookFixture(t, "gitea_pull_request_merged.json")
+	gitlab := readWebhookFixture(t, "gitlab_merge_request_merge.json")
+
+	for _, tc := range []struct {
+		name     string
+		provider string
+		body     []byte
+		headers  map[string]string
+	}{
+		{"github without signature", model.ProviderGitHub, github, map[string]string{
+			"X-GitHub-Event": "pull_request",
+		}},
+		{"github with another secret", model.ProviderGitHub, github, map[string]string{
+			"X-GitHub-Event":      "pull_request",
+			"X-Hub-Signature-256": "sha256=" + hmacSignature("other", github),
+		}},
+		{"github with tampered body", model.ProviderGitHub,
+			bytes.Replace(github, []byte("team/app"), []byte("team/api"), 1),
+			githubHeaders("pull_request", github)},
+		{"github with malformed signature", model.ProviderGitHub, github, map[string]string{
+			"X-GitHub-Event":      "pull_request",
+			"X-Hub-Signature-256": "sha256=zz",
+		}},
+		{"gitea with another secret", model.ProviderGitea, gitea, map[string]string{
+			"X-Gitea-Event":     "pull_request",
+			"X-Gitea-Signature": hmacSignature("other", gitea),
+		}},
+		{"gitea without signature", model.ProviderGitea, gitea, map[string]string{
+			"X-Gitea-Event": "pull_request",
+		}},
+		{"gitlab with another token", model.ProviderGitLab, gitlab, map[string]string{
+			"X-Gitlab-Token": "other",
+		}},
+		{"gitlab without token", model.ProviderGitLab, gitlab, nil},
+		{"gitlab with the signature of github", model.ProviderGitLab, gitlab,
+			githubHeaders("pull_request", gitlab)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &fakeWebhookService{}
+			rec := sendWebhook(t, svc, tc.provider, tc.body, tc.headers)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want 401", rec.Code)
+			}
+			if len(svc.events) != 0 {
+				t.Errorf("service called with %+v", svc.events[0])
+			}
+		})
+	}
+}
+
+func TestWebhookRejectsInvalidRequests(t *testing.T) {
+	malformed := []byte(`{"action":`)
+
+	for _, tc := range []struct {
+		name     string
+		provider string
+		body     []byte
+		headers  map[string]string
+		want     int
+	}{
+		{"unknown provider", "bitbucket", malformed, nil, http.StatusNotFound},
+		{"malformed github payload", model.ProviderGitHub, malformed,
+			githubHeaders("pull_request", malformed), http.StatusBadRequest},
+		{"malformed gitlab payload", model.ProviderGitLab, malformed,
+			gitlabHeaders("", malformed), http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &fakeWebhookService{}
+			rec := sendWebhook(t, svc, tc.provider, tc.body, tc.headers)
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d", rec.Code, tc.want)
+			}
+			if len(svc.events) != 0 {
+				t.Errorf("service called with %+v", svc.events[0])
+			}
+		})
+	}
+}
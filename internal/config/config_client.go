@@ -5,8 +5,20 @@ import (
 )
 
 type ClientConfig struct {
-	APIURL      string `mapstructure:"api_url"`
-	AdminAPIKey string `mapstructure:"admin_api_key"`
+	APIURL string `mapstructure:"api_url"`
+	// APIKey is a named API key sent as a Bearer token. AdminAPIKey is the
+	// legacy server admin key used when APIKey is empty.
+	APIKey      string    `mapstructure:"api_key"`
+	AdminAPIKey string    `mapstructure:"admin_api_key"`
+	TLS         ClientTLS `mapstructure:"tls"`
+}
+
+// ClientTLS configures how the client verifies the server and the
+// certificate it presents to servers that require one.
+type ClientTLS struct {
+	CAFile   string `mapstructure:"ca_file"`
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
 }
 
 func NewClientConfig() (*ClientConfig, error) {
@@ -6,7 +6,11 @@ import (
 
 type ServerConfig struct {
 	APIURL            string        `mapstructure:"api_url"`
+	Listen            Listen        `mapstructure:"listen"`
+	InternalListen    Listen        `mapstructure:"internal_listen"`
 	AdminAPIKey       string        `mapstructure:"admin_api_key"`
+	OIDC              OIDC          `mapstructure:"oidc"`
+	Webhooks          Webhooks      `mapstructure:"webhooks"`
 	DryRun            bool          `mapstructure:"dry_run"`
 	DefaultTTL        string        `mapstructure:"default_ttl"`
 	SQLite            SQLite        `mapstructure:"sqlite"`
@@ -16,11 +20,66 @@ type ServerConfig struct {
 	CrawlInterval     string        `mapstructure:"crawl_interval"`
 	DeleteInterval    string        `mapstructure:"delete_interval"`
 	StaleThreshold    string        `mapstructure:"stale_threshold"`
+	DeletedRetention  string        `mapstructure:"deleted_retention"`
+	DeleteRetry       DeleteRetry   `mapstructure:"delete_retry"`
+	Reconcile         Reconcile     `mapstructure:"reconcile"`
+	Health            Health        `mapstructure:"health"`
 	Notifications     Notifications `mapstructure:"notifications"`
 	Environments      Environments  `mapstructure:"environments"`
 	Connectors        Connectors    `mapstructure:"connectors"`
 }
 
+// Listen configures an HTTP listener. Timeouts are durations like 60s,
+// empty ones use the defaults.
+type Listen struct {
+	Address           string `mapstructure:"address"`
+	ReadTimeout       string `mapstructure:"read_timeout"`
+	ReadHeaderTimeout string `mapstructure:"read_header_timeout"`
+	WriteTimeout      string `mapstructure:"write_timeout"`
+	IdleTimeout       string `mapstructure:"idle_timeout"`
+	TLS               TLS    `mapstructure:"tls"`
+}
+
+type TLS struct {
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	// ClientCAFile verifies client certificates presented to the
+	// listener. With RequireClientCert API keys, OIDC tokens and login
+	// sessions are only accepted from clients with a verified certificate.
+	ClientCAFile      string `mapstructure:"client_ca_file"`
+	RequireClientCert bool   `mapstructure:"require_client_cert"`
+}
+
+// Webhooks configures merge request webhooks. Secret signs GitHub and
+// Gitea events and is the GitLab token. Match holds templates of the
+// fields environments of a merge request are found by.
+type Webhooks struct {
+	Enabled bool         `mapstructure:"enabled"`
+	Secret  string       `mapstructure:"secret"`
+	Match   WebhookMatch `mapstructure:"match"`
+}
+
+type WebhookMatch struct {
+	Name      string `mapstructure:"name"`
+	Namespace string `mapstructure:"namespace"`
+	Type      string `mapstructure:"type"`
+	Labels    string `mapstructure:"labels"`
+}
+
+type OIDC struct {
+	Enabled       bool     `mapstructure:"enabled"`
+	IssuerURL     string   `mapstructure:"issuer_url"`
+	Issuer        string   `mapstructure:"issuer"`
+	JWKSFile      string   `mapstructure:"jwks_file"`
+	Audience      string   `mapstructure:"audience"`
+	IdentityClaim string   `mapstructure:"identity_claim"`
+	GroupsClaim   string   `mapstructure:"groups_claim"`
+	AdminGroups   []string `mapstructure:"admin_groups"`
+	ClientID      string   `mapstructure:"client_id"`
+	ClientSecret  string   `mapstructure:"client_secret"`
+	Scopes        []string `mapstructure:"scopes"`
+}
+
 type SQLite struct {
 	DatabaseFolder string `mapstructure:"database_folder"`
 }
@@ -33,6 +92,25 @@ type Postgresql struct {
 	Database string `mapstructure:"database"`
 }
 
+type DeleteRetry struct {
+	Backoff     string `mapstructure:"backoff"`
+	MaxBackoff  string `mapstructure:"max_backoff"`
+	MaxFailures int    `mapstructure:"max_failures"`
+}
+
+// Health configures how long ago the last successful crawler and deleter
+// runs may be before /readyz reports them failing. Empty values default to
+// three intervals.
+type Health struct {
+	MaxCrawlAge  string `mapstructure:"max_crawl_age"`
+	MaxDeleteAge string `mapstructure:"max_delete_age"`
+}
+
+type Reconcile struct {
+	GracePeriod string `mapstructure:"grace_period"`
+	NotifyOwner bool   `mapstructure:"notify_owner"`
+}
+
 type Notifications struct {
 	AdminOnly bool  `mapstructure:"admin_only"`
 	Slack     Slack `mapstructure:"slack"`
@@ -47,13 +125,16 @@ type Slack struct {
 }
 
 type Email struct {
-	Enabled           bool   `mapstructure:"enabled"`
-	SMTPServerAddress string `mapstructure:"smtp_server_address"`
-	SMTPServerPort    int    `mapstructure:"smtp_server_port"`
-	Username          string `mapstructure:"username"`
-	Password          string `mapstructure:"password"`
-	SenderEmail       string `mapstructure:"sender_email"`
-	AdminEmail        string `mapstructure:"admin_email"`
+	Enabled            bool   `mapstructure:"enabled"`
+	SMTPServerAddress  string `mapstructure:"smtp_server_address"`
+	SMTPServerPort     int    `mapstructure:"smtp_server_port"`
+	TLS                string `mapstructure:"tls"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	Username           string `mapstructure:"username"`
+	Password           string `mapstructure:"password"`
+	SenderEmail        string `mapstructure:"sender_email"`
+	AdminEmail         string `mapstructure:"admin_email"`
+	OwnerDomain        string `mapstructure:"owner_domain"`
 }
 
 type Environments struct {
@@ -0,0 +1,269 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/fragpit/env-cleaner/pkg/notificator"
+)
+
+// Problem is an invalid setting. Key is the path of the setting in the
+// configuration file, e.g. connectors.k8s.kubeconfig.
+type Problem struct {
+	Key string
+	Msg string
+}
+
+func (p Problem) String() string {
+	return p.Key + ": " + p.Msg
+}
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.String()
+	}
+
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// validator collects the problems of a configuration.
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) addf(key, format string, args ...any) {
+	v.problems = append(v.problems, Problem{
+		Key: key,
+		Msg: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) required(key, value string) {
+	if value == "" {
+		v.addf(key, "is required")
+	}
+}
+
+// interval checks a duration that is parsed with time.ParseDuration, which
+// does not know days and weeks.
+func (v *validator) interval(key, value string) {
+	if value == "" {
+		v.addf(key, "is required")
+		return
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		v.addf(key, "invalid duration %q, use h, m or s units", value)
+		return
+	}
+	if d <= 0 {
+		v.addf(key, "must be positive")
+	}
+}
+
+// duration checks a duration like 3d or 2w. Empty values are allowed
+// unless required is set.
+func (v *validator) duration(key, value string, required bool) {
+	if value == "" {
+		if required {
+			v.addf(key, "is required")
+		}
+		return
+	}
+
+	d, err := str2duration.ParseDuration(value)
+	if err != nil {
+		v.addf(key, "invalid duration %q", value)
+		return
+	}
+	if d < 0 {
+		v.addf(key, "must not be negative")
+	}
+}
+
+func (v *validator) file(key, path string) {
+	if path == "" {
+		v.addf(key, "is required")
+		return
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		v.addf(key, "%v", err)
+	}
+}
+
+// Validate checks the whole configuration and returns a *ValidationError
+// listing all problems, or nil if there are none.
+func (c *ServerConfig) Validate() error {
+	v := &validator{}
+
+	if c.SQLite.DatabaseFolder == "" && c.Postgresql.Host == "" {
+		v.addf("sqlite.database_folder",
+			"no storage configured, set it or postgresql.host")
+	}
+
+	v.interval("crawl_interval", c.CrawlInterval)
+	v.interval("delete_interval", c.DeleteInterval)
+	v.duration("stale_threshold", c.StaleThreshold, true)
+	v.duration("max_extend_duration", c.MaxExtendDuration, true)
+	v.duration("default_ttl", c.DefaultTTL, false)
+	v.duration("deleted_retention", c.DeletedRetention, false)
+	v.duration("delete_retry.backoff", c.DeleteRetry.Backoff, false)
+	v.duration("delete_retry.max_backoff", c.DeleteRetry.MaxBackoff, false)
+	if c.DeleteRetry.MaxFailures < 0 {
+		v.addf("delete_retry.max_failures", "must not be negative")
+	}
+	v.duration("reconcile.grace_period", c.Reconcile.GracePeriod, false)
+	v.duration("health.max_crawl_age", c.Health.MaxCrawlAge, false)
+	v.duration("health.max_delete_age", c.Health.MaxDeleteAge, false)
+
+	v.listen("listen", c.Listen)
+	v.listen("internal_listen", c.InternalListen)
+
+	if c.OIDC.Enabled {
+		v.oidc(c.OIDC)
+	}
+
+	if c.Webhooks.Enabled {
+		v.required("webhooks.secret", c.Webhooks.Secret)
+		if c.Webhooks.Match.Name == "" && c.Webhooks.Match.Labels == "" {
+			v.addf("webhooks.match.name", "is required unless webhooks.match.labels is set")
+		}
+		for _, t := range []struct {
+			key  string
+			text string
+		}{
+			{"webhooks.match.name", c.Webhooks.Match.Name},
+			{"webhooks.match.namespace", c.Webhooks.Match.Namespace},
+			{"webhooks.match.type", c.Webhooks.Match.Type},
+			{"webhooks.match.labels", c.Webhooks.Match.Labels},
+		} {
+			if _, err := template.New(t.key).Parse(t.text); err != nil {
+				v.addf(t.key, "invalid template: %v", err)
+			}
+		}
+	}
+
+	v.notifications(c.Notifications)
+	v.environments(c.Environments, c.Connectors)
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+
+	return nil
+}
+
+func (v *validator) listen(key string, l Listen) {
+	v.duration(key+".read_timeout", l.ReadTimeout, false)
+	v.duration(key+".read_header_timeout", l.ReadHeaderTimeout, false)
+	v.duration(key+".write_timeout", l.WriteTimeout, false)
+	v.duration(key+".idle_timeout", l.IdleTimeout, false)
+
+	switch {
+	case l.TLS.CertFile == "" && l.TLS.KeyFile == "":
+		if l.TLS.ClientCAFile != "" || l.TLS.RequireClientCert {
+			v.addf(key+".tls", "client certificates require cert_file and key_file")
+		}
+	case l.TLS.CertFile == "":
+		v.addf(key+".tls.cert_file", "is required with key_file")
+	case l.TLS.KeyFile == "":
+		v.addf(key+".tls.key_file", "is required with cert_file")
+	case l.TLS.RequireClientCert && l.TLS.ClientCAFile == "":
+		v.addf(key+".tls.client_ca_file", "is required by require_client_cert")
+	}
+}
+
+// oidc checks that tokens are always verified against an expected issuer
+// and audience.
+func (v *validator) oidc(o OIDC) {
+	switch {
+	case o.IssuerURL != "":
+	case o.JWKSFile == "":
+		v.addf("oidc.issuer_url", "is required unless oidc.jwks_file is set")
+	case o.Issuer == "":
+		v.addf("oidc.issuer", "is required with oidc.jwks_file unless oidc.issuer_url is set")
+	}
+
+	if o.JWKSFile != "" {
+		v.file("oidc.jwks_file", o.JWKSFile)
+	}
+
+	if o.Audience == "" && o.ClientID == "" {
+		v.addf("oidc.audience", "is required unless oidc.client_id is set")
+	}
+}
+
+func (v *validator) notifications(n Notifications) {
+	if n.Slack.Enabled {
+		v.required("notifications.slack.webhook_url", n.Slack.WebhookURL)
+	}
+
+	if n.Email.Enabled {
+		v.required(
+			"notifications.email.smtp_server_address", n.Email.SMTPServerAddress,
+		)
+		if n.Email.SMTPServerPort <= 0 || n.Email.SMTPServerPort > 65535 {
+			v.addf("notifications.email.smtp_server_port",
+				"invalid port %d", n.Email.SMTPServerPort)
+		}
+		v.required("notifications.email.sender_email", n.Email.SenderEmail)
+
+		switch n.Email.TLS {
+		case "", notificator.EmailTLSAuto, notificator.EmailTLSStartTLS,
+			notificator.EmailTLSImplicit, notificator.EmailTLSNone:
+		default:
+			v.addf("notifications.email.tls",
+				"unknown mode %q, must be one of auto, starttls, tls, none",
+				n.Email.TLS)
+		}
+	}
+}
+
+func (v *validator) environments(envs Environments, conns Connectors) {
+	if !envs.Helm.Enabled && !envs.VSphereVM.Enabled {
+		v.addf("environments", "no connectors enabled")
+	}
+
+	if envs.Helm.Enabled {
+		v.file("connectors.k8s.kubeconfig", conns.K8s.Kubeconfig)
+
+		for i, rule := range envs.Helm.WhitelistReleasesRegex {
+			if _, err := regexp.Compile(rule); err != nil {
+				v.addf(fmt.Sprintf(
+					"environments.helm.whitelist_releases_regex[%d]", i,
+				), "invalid regular expression: %v", err)
+			}
+		}
+
+		if envs.Helm.VeleroBackup.Enabled {
+			v.required(
+				"environments.helm.velero_backup.namespace",
+				envs.Helm.VeleroBackup.Namespace,
+			)
+			v.duration(
+				"environments.helm.velero_backup.ttl",
+				envs.Helm.VeleroBackup.TTL, true,
+			)
+		}
+	}
+
+	if envs.VSphereVM.Enabled {
+		v.required("connectors.vsphere.hostname", conns.VSphere.Hostname)
+		v.required("connectors.vsphere.username", conns.VSphere.Username)
+		v.required("connectors.vsphere.datacenter", conns.VSphere.Datacenter)
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// oidcProblems returns the keys of the oidc problems Validate reports for
+// o.
+func oidcProblems(t *testing.T, o OIDC) []string {
+	t.Helper()
+
+	err := (&ServerConfig{OIDC: o}).Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Validate error = %v, want a *ValidationError", err)
+	}
+
+	var keys []string
+	for _, p := range verr.Problems {
+		if strings.HasPrefix(p.Key, "oidc.") {
+			keys = append(keys, p.Key)
+		}
+	}
+	return keys
+}
+
+func TestValidateOIDC(t *testing.T) {
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(jwksFile, []byte(`{"keys":[]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		oidc OIDC
+		want []string
+	}{
+		{"disabled", OIDC{}, nil},
+		{"issuer url and audience", OIDC{
+			Enabled:   true,
+			IssuerURL: "https://sso.example.com",
+			Audience:  "env-cleaner",
+		}, nil},
+		{"issuer url and client id", OIDC{
+			Enabled:   true,
+			IssuerURL: "https://sso.example.com",
+			ClientID:  "env-cleaner",
+		}, nil},
+		{"jwks file and issuer", OIDC{
+			Enabled:  true,
+			JWKSFile: jwksFile,
+			Issuer:   "https://sso.example.com",
+			Audience: "env-cleaner",
+		}, nil},
+		{"no issuer url or jwks file", OIDC{
+			Enabled:  true,
+			Audience: "env-cleaner",
+		}, []string{"oidc.issuer_url"}},
+		{"jwks file without issuer", OIDC{
+			Enabled:  true,
+			JWKSFile: jwksFile,
+			Audience: "env-cleaner",
+		}, []string{"oidc.issuer"}},
+		{"missing jwks file", OIDC{
+			Enabled:  true,
+			JWKSFile: filepath.Join(t.TempDir(), "missing.json"),
+			Issuer:   "https://sso.example.com",
+			Audience: "env-cleaner",
+		}, []string{"oidc.jwks_file"}},
+		{"no audience or client id", OIDC{
+			Enabled:   true,
+			IssuerURL: "https://sso.example.com",
+		}, []string{"oidc.audience"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := oidcProblems(t, tc.oidc); !slices.Equal(got, tc.want) {
+				t.Errorf("problems = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -158,6 +159,8 @@ func (h *Connector) GetEnvironments(
 			continue
 		}
 
+		protected, _ := strconv.ParseBool(getChartValue(rel, "ec_protected"))
+
 		envID := strconv.Itoa(int(rel.Info.FirstDeployed.Unix()))
 		env := model.Environment{
 			EnvID:       envID,
@@ -167,6 +170,19 @@ func (h *Connector) GetEnvironments(
 			Owner:       owner,
 			DeleteAt:    deleteAt,
 			DeleteAtSec: deleteAtSec,
+			TTL:         ttl,
+			Protected:   protected,
+		}
+		if protected {
+			env.ProtectedReason = getChartValue(rel, "ec_protected_reason")
+		}
+		env.Labels, err = getChartLabels(rel)
+		if err != nil {
+			slog.Warn("ignored helm release labels",
+				slog.String("name", rel.Name),
+				slog.String("namespace", rel.Namespace),
+				slog.Any("error", err),
+			)
 		}
 		envs = append(envs, env)
 	}
@@ -210,11 +226,18 @@ func (h *Connector) CheckEnvironment(
 ) error {
 	envID, err := h.GetEnvironmentID(ctx, env)
 	if err != nil {
+		if errors.Is(err, driver.ErrReleaseNotFound) {
+			err = model.ErrEnvironmentNotFound
+		}
 		return fmt.Errorf("error checking environment: %w", err)
 	}
 
+	// A release installed again under the same name is a new environment.
 	if envID != env.EnvID {
-		return fmt.Errorf("error getting release: environment ID changed")
+		return fmt.Errorf(
+			"error getting release: environment ID changed: %w",
+			model.ErrEnvironmentNotFound,
+		)
 	}
 
 	return nil
@@ -290,16 +313,58 @@ func (h *Connector) DeleteEnvironment(
 	return nil
 }
 
+// Ping checks that the Kubernetes API server is reachable.
+func (h *Connector) Ping(ctx context.Context) error {
+	if err := h.KubeClient.Discovery().RESTClient().Get().
+		AbsPath("/version").Do(ctx).Error(); err != nil {
+		return fmt.Errorf("error reaching kubernetes api: %w", err)
+	}
+
+	return nil
+}
+
 func (h *Connector) GetConnectorType() string {
 	return connectorType
 }
 
+// getChartValue returns a top level value of the release as a string.
+// Values set with --set may be parsed as booleans or numbers.
 func getChartValue(rel *release.Release, key string) string {
-	if rel.Config[key] == nil {
+	switch v := rel.Config[key].(type) {
+	case nil:
 		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// getChartLabels returns the ec_labels value of the release, set either as
+// a map (--set ec_labels.team=qa) or as a string of key=value pairs. It
+// returns nil if the value is not set.
+func getChartLabels(rel *release.Release) (map[string]string, error) {
+	var labels map[string]string
+	switch v := rel.Config["ec_labels"].(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		labels = make(map[string]string, len(v))
+		for key, value := range v {
+			labels[key] = fmt.Sprint(value)
+		}
+	default:
+		var err error
+		if labels, err = model.ParseLabels(fmt.Sprint(v)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := model.ValidateLabels(labels); err != nil {
+		return nil, err
 	}
 
-	return rel.Config[key].(string)
+	return labels, nil
 }
 
 func scaleDeployment(
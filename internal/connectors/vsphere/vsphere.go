@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"net/url"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/vmware/govmomi/property"
 	"github.com/vmware/govmomi/view"
 	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
 	"github.com/vmware/govmomi/vim25/types"
 
 	"github.com/fragpit/env-cleaner/internal/config"
@@ -172,7 +174,11 @@ func (vc *Connector) GetEnvironments(
 			continue
 		}
 
-		env = append(env, model.Environment{
+		protected, _ := strconv.ParseBool(
+			parseAnnotation(vm.Summary.Config.Annotation, "EC_PROTECTED"),
+		)
+
+		e := model.Environment{
 			EnvID:       vm.Self.Value,
 			Type:        connectorType,
 			Name:        vm.Name,
@@ -180,7 +186,26 @@ func (vc *Connector) GetEnvironments(
 			Owner:       owner,
 			DeleteAt:    deleteAt,
 			DeleteAtSec: deleteAtSec,
-		})
+			TTL:         ttl,
+			Protected:   protected,
+		}
+		if protected {
+			e.ProtectedReason = parseAnnotation(
+				vm.Summary.Config.Annotation, "EC_PROTECTED_REASON",
+			)
+		}
+		if labels, ok := annotationValue(
+			vm.Summary.Config.Annotation, "EC_LABELS",
+		); ok {
+			e.Labels, err = model.ParseLabels(labels)
+			if err != nil {
+				slog.Warn("ignored VM labels",
+					slog.String("name", vm.Name),
+					slog.Any("error", err),
+				)
+			}
+		}
+		env = append(env, e)
 	}
 
 	return env, nil
@@ -271,6 +296,9 @@ func (vc *Connector) CheckEnvironment(
 		}
 
 		if _, err := finder.ObjectReference(ctx, vmMOR); err != nil {
+			if isNotFound(err) {
+				err = model.ErrEnvironmentNotFound
+			}
 			return fmt.Errorf("error finding vm: %w", err)
 		}
 	} else if env.Name != "" {
@@ -287,19 +315,42 @@ func (vc *Connector) CheckEnvironment(
 	return nil
 }
 
+// Ping checks that the vCenter session is valid, logging in again if it
+// expired.
+func (vc *Connector) Ping(ctx context.Context) error {
+	return vc.validateSession(ctx)
+}
+
 func (vc *Connector) GetConnectorType() string {
 	return connectorType
 }
 
+// isNotFound reports whether err is a vSphere fault for a missing object.
+func isNotFound(err error) bool {
+	if !soap.IsSoapFault(err) {
+		return false
+	}
+
+	_, ok := soap.ToSoapFault(err).VimFault().(types.ManagedObjectNotFound)
+	return ok
+}
+
 func parseAnnotation(annotation, key string) string {
+	value, _ := annotationValue(annotation, key)
+	return value
+}
+
+// annotationValue returns the value of a KEY: value line of the annotation
+// and whether the line is present.
+func annotationValue(annotation, key string) (string, bool) {
 	lines := strings.Split(annotation, "\n")
 	for _, line := range lines {
 		parts := strings.Split(line, ":")
 		if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
-			return strings.TrimSpace(parts[1])
+			return strings.TrimSpace(parts[1]), true
 		}
 	}
-	return ""
+	return "", false
 }
 
 func (vc *Connector) searchVMbyName(
@@ -332,7 +383,7 @@ func (vc *Connector) searchVMbyName(
 	}
 
 	if len(objs) == 0 {
-		return "", fmt.Errorf("error finding vm: %w", errors.New("vm not found"))
+		return "", fmt.Errorf("error finding vm: %w", model.ErrEnvironmentNotFound)
 	} else if len(objs) > 1 {
 		return "", fmt.Errorf(
 			"error finding vm: %w",
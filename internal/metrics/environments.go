@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+)
+
+const collectTimeout = 10 * time.Second
+
+var (
+	environmentsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "environments"),
+		"Stored environments by type, owner and status.",
+		[]string{"type", "owner", "status"}, nil,
+	)
+
+	staleDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "stale_environments"),
+		"Environments to be deleted within the stale threshold.",
+		[]string{"type"}, nil,
+	)
+
+	outdatedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "outdated_environments"),
+		"Environments past their deletion date that are not deleted yet.",
+		[]string{"type"}, nil,
+	)
+)
+
+// EnvironmentCollector reads environment gauges from the repository on
+// every scrape.
+type EnvironmentCollector struct {
+	repo           model.EnvRepository
+	staleThreshold time.Duration
+}
+
+var _ prometheus.Collector = (*EnvironmentCollector)(nil)
+
+func NewEnvironmentCollector(
+	repo model.EnvRepository,
+	staleThreshold string,
+) (*EnvironmentCollector, error) {
+	tr, err := str2duration.ParseDuration(staleThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EnvironmentCollector{
+		repo:           repo,
+		staleThreshold: tr,
+	}, nil
+}
+
+func (c *EnvironmentCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- environmentsDesc
+	ch <- staleDesc
+	ch <- outdatedDesc
+}
+
+func (c *EnvironmentCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	envs, err := c.repo.GetEnvironments(
+		ctx, &model.EnvironmentFilter{Statuses: model.Statuses},
+	)
+	if err != nil {
+		slog.Error("error collecting environment metrics", slog.Any("error", err))
+		ch <- prometheus.NewInvalidMetric(environmentsDesc, err)
+		return
+	}
+
+	type key struct{ envType, owner, status string }
+	counts := make(map[key]int)
+	stale := make(map[string]int)
+	outdated := make(map[string]int)
+
+	now := time.Now().Unix()
+	staleBefore := now + int64(c.staleThreshold.Seconds())
+	for _, env := range envs {
+		counts[key{env.Type, env.Owner, env.Status}]++
+
+		switch env.Status {
+		case model.StatusDeleted, model.StatusGone:
+			continue
+		}
+
+		switch {
+		case env.DeleteAtSec < now:
+			outdated[env.Type]++
+		case env.DeleteAtSec < staleBefore:
+			stale[env.Type]++
+		}
+	}
+
+	for k, n := range counts {
+		ch <- prometheus.MustNewConstMetric(
+			environmentsDesc, prometheus.GaugeValue, float64(n),
+			k.envType, k.owner, k.status,
+		)
+	}
+
+	for envType, n := range stale {
+		ch <- prometheus.MustNewConstMetric(
+			staleDesc, prometheus.GaugeValue, float64(n), envType,
+		)
+	}
+
+	for envType, n := range outdated {
+		ch <- prometheus.MustNewConstMetric(
+			outdatedDesc, prometheus.GaugeValue, float64(n), envType,
+		)
+	}
+}
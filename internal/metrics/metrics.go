@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "env_cleaner"
+
+// Run and deletion results.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Outcomes of extend requests.
+const (
+	ExtendOutcomeExtended      = "extended"
+	ExtendOutcomeInvalidToken  = "invalid_token"
+	ExtendOutcomeInvalidPeriod = "invalid_period"
+	ExtendOutcomeNotFound      = "not_found"
+	ExtendOutcomeConflict      = "conflict"
+	ExtendOutcomeError         = "error"
+)
+
+var (
+	crawlerRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "runs_total",
+		Help:      "Crawler runs by connector and result.",
+	}, []string{"connector", "result"})
+
+	crawlerRunDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "run_duration_seconds",
+		Help:      "Duration of crawler runs.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
+	}, []string{"connector"})
+
+	crawlerLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "crawler",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful crawler run.",
+	}, []string{"connector"})
+
+	deleterRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "deleter",
+		Name:      "runs_total",
+		Help:      "Deleter runs by result.",
+	}, []string{"result"})
+
+	deleterRunDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "deleter",
+		Name:      "run_duration_seconds",
+		Help:      "Duration of deleter runs.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
+	})
+
+	deleterLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "deleter",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful deleter run.",
+	})
+
+	deletions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "deleter",
+		Name:      "deletions_total",
+		Help:      "Environment deletions by connector and result.",
+	}, []string{"connector", "result"})
+
+	notificationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "notifications",
+		Name:      "failures_total",
+		Help:      "Failed notification sends by channel.",
+	}, []string{"channel"})
+
+	extendRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "api",
+		Name:      "extend_requests_total",
+		Help:      "Extend requests by outcome.",
+	}, []string{"outcome"})
+)
+
+// ObserveCrawlerRun records a crawler run of connector started at start.
+func ObserveCrawlerRun(connector string, start time.Time, ok bool) {
+	crawlerRunDuration.WithLabelValues(connector).
+		Observe(time.Since(start).Seconds())
+	crawlerRuns.WithLabelValues(connector, result(ok)).Inc()
+	if ok {
+		crawlerLastSuccess.WithLabelValues(connector).SetToCurrentTime()
+	}
+}
+
+// ObserveDeleterRun records a deleter run started at start.
+func ObserveDeleterRun(start time.Time, ok bool) {
+	deleterRunDuration.Observe(time.Since(start).Seconds())
+	deleterRuns.WithLabelValues(result(ok)).Inc()
+	if ok {
+		deleterLastSuccess.SetToCurrentTime()
+	}
+}
+
+// ObserveDeletion records an attempt to delete an environment of connector.
+func ObserveDeletion(connector string, ok bool) {
+	deletions.WithLabelValues(connector, result(ok)).Inc()
+}
+
+// NotificationFailed records a failed send on channel.
+func NotificationFailed(channel string) {
+	notificationFailures.WithLabelValues(channel).Inc()
+}
+
+// ObserveExtendRequest records the outcome of an extend request.
+func ObserveExtendRequest(outcome string) {
+	extendRequests.WithLabelValues(outcome).Inc()
+}
+
+func result(ok bool) string {
+	if ok {
+		return ResultSuccess
+	}
+	return ResultFailure
+}
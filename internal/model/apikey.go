@@ -0,0 +1,53 @@
+package model
+
+import (
+	"context"
+	"slices"
+)
+
+// API key scopes. The admin scope grants every other one.
+const (
+	ScopeRead     = "read"
+	ScopeRegister = "register"
+	ScopeExtend   = "extend"
+	ScopeDelete   = "delete"
+	ScopeAdmin    = "admin"
+)
+
+// Scopes lists every API key scope.
+var Scopes = []string{
+	ScopeRead,
+	ScopeRegister,
+	ScopeExtend,
+	ScopeDelete,
+	ScopeAdmin,
+}
+
+// ValidScope reports whether scope is a known API key scope.
+func ValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+// APIKey is a named API key. Only the hash of the key is stored.
+type APIKey struct {
+	Name    string
+	KeyHash string
+	Scopes  []string
+	// CreatedAt, ExpiresAt and RevokedAt are unix times. Zero ExpiresAt
+	// never expires, zero RevokedAt is not revoked.
+	CreatedAt int64
+	ExpiresAt int64
+	RevokedAt int64
+}
+
+// Active reports whether the key can be used at unix time now.
+func (k *APIKey) Active(now int64) bool {
+	return k.RevokedAt == 0 && (k.ExpiresAt == 0 || now < k.ExpiresAt)
+}
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *APIKey) error
+	GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error)
+	GetAPIKeys(ctx context.Context) ([]*APIKey, error)
+	RevokeAPIKey(ctx context.Context, name string, revokedAt int64) error
+}
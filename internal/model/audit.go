@@ -0,0 +1,123 @@
+package model
+
+import (
+	"context"
+	"slices"
+)
+
+// Audited lifecycle actions.
+const (
+	AuditActionCreate       = "create"
+	AuditActionDiscover     = "discover"
+	AuditActionUpdate       = "update"
+	AuditActionExtend       = "extend"
+	AuditActionWarn         = "warn"
+	AuditActionDelete       = "delete"
+	AuditActionDeleteFailed = "delete_failed"
+	AuditActionGone         = "gone"
+	AuditActionReappear     = "reappear"
+	AuditActionProtect      = "protect"
+	AuditActionUnprotect    = "unprotect"
+	// AuditActionDeleteNow is a deletion requested through the API ahead
+	// of delete_at, AuditActionForget untracking without deletion.
+	AuditActionDeleteNow = "delete_now"
+	AuditActionForget    = "forget"
+)
+
+// Sources of audited actions.
+const (
+	AuditSourceAPI        = "api"
+	AuditSourceExtendPage = "extend_page"
+	AuditSourceCrawler    = "crawler"
+	AuditSourceDeleter    = "deleter"
+	AuditSourceWebhook    = "webhook"
+)
+
+// Actors of actions not performed by a person.
+const (
+	ActorAdmin   = "admin"
+	ActorCrawler = "crawler"
+	ActorDeleter = "deleter"
+)
+
+type AuditEntry struct {
+	ID int64
+	// CreatedAt is the unix time of the action.
+	CreatedAt int64
+	EnvID     string
+	EnvType   string
+	EnvName   string
+	Action    string
+	Actor     string
+	Source    string
+	// OldDeleteAt and NewDeleteAt are set for actions that change or
+	// act on the environment deletion date.
+	OldDeleteAt string
+	NewDeleteAt string
+	Details     string
+}
+
+// NewAuditEntry returns an entry for an action on env.
+func NewAuditEntry(env *Environment, action, actor, source string) *AuditEntry {
+	return &AuditEntry{
+		EnvID:       env.EnvID,
+		EnvType:     env.Type,
+		EnvName:     env.DisplayName(),
+		Action:      action,
+		Actor:       actor,
+		Source:      source,
+		NewDeleteAt: env.DeleteAt,
+	}
+}
+
+// AuditFilter narrows down GetAuditEntries results. Empty fields do not
+// filter.
+type AuditFilter struct {
+	EnvID  string
+	Action string
+	Actor  string
+	Source string
+	// Owner matches entries of environments currently stored with that
+	// owner.
+	Owner string
+	// Since and Until bound CreatedAt, both inclusive.
+	Since int64
+	Until int64
+	// Limit caps the number of returned entries, newest first.
+	Limit int
+}
+
+type AuditRepository interface {
+	WriteAuditEntry(ctx context.Context, entry *AuditEntry) error
+	GetAuditEntries(ctx context.Context, filter *AuditFilter) ([]*AuditEntry, error)
+}
+
+// Actor identifies who performs an action coming through the API. Scopes
+// are the scopes the actor authenticated with. A non-empty Owner restricts
+// the actor to the environments of that owner.
+type Actor struct {
+	Name   string
+	Source string
+	Scopes []string
+	Owner  string
+}
+
+// HasScope reports whether the actor is granted scope.
+func (a Actor) HasScope(scope string) bool {
+	return slices.Contains(a.Scopes, scope) ||
+		slices.Contains(a.Scopes, ScopeAdmin)
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying actor.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored in ctx. The second value is
+// false if there is none.
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	return actor, ok
+}
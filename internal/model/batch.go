@@ -0,0 +1,83 @@
+package model
+
+// Batch actions.
+const (
+	BatchActionExtend      = "extend"
+	BatchActionSetExpiry   = "set-expiry"
+	BatchActionDeleteNow   = "delete-now"
+	BatchActionProtect     = "protect"
+	BatchActionChangeOwner = "change-owner"
+)
+
+var BatchActions = []string{
+	BatchActionExtend,
+	BatchActionSetExpiry,
+	BatchActionDeleteNow,
+	BatchActionProtect,
+	BatchActionChangeOwner,
+}
+
+// BatchActionScopes maps batch actions to the scope they require, the same
+// as the single environment endpoints.
+var BatchActionScopes = map[string]string{
+	BatchActionExtend:      ScopeExtend,
+	BatchActionSetExpiry:   ScopeExtend,
+	BatchActionDeleteNow:   ScopeDelete,
+	BatchActionProtect:     ScopeAdmin,
+	BatchActionChangeOwner: ScopeAdmin,
+}
+
+// Results of a batch operation on a single environment.
+const (
+	BatchResultMatched = "matched"
+	BatchResultOK      = "ok"
+	BatchResultFailed  = "failed"
+)
+
+// BatchSelector selects environments by their fields. Empty fields match
+// any value. NameRegex is a regular expression matched against the name,
+// Label a label selector as parsed by ParseLabelSelector.
+type BatchSelector struct {
+	Owner     string
+	Namespace string
+	Type      string
+	NameRegex string
+	Label     string
+}
+
+// IsEmpty reports whether the selector matches every environment.
+func (s BatchSelector) IsEmpty() bool {
+	return s == BatchSelector{}
+}
+
+// BatchOperation applies Action to the environments listed in IDs, or
+// selected by Selector if there are none. Period, DeleteAt, IgnoreMax,
+// Reason and Owner are the arguments of the action. DryRun only resolves
+// the environments.
+type BatchOperation struct {
+	Selector  BatchSelector
+	IDs       []string
+	Action    string
+	Period    string
+	DeleteAt  string
+	IgnoreMax bool
+	Reason    string
+	Owner     string
+	DryRun    bool
+}
+
+// ValidBatchAction reports whether action is a known batch action.
+func ValidBatchAction(action string) bool {
+	_, ok := BatchActionScopes[action]
+	return ok
+}
+
+// BatchResult is the outcome of a batch operation on one environment. Env
+// is the environment after the action, or as matched, and nil if it was
+// not found.
+type BatchResult struct {
+	EnvID  string
+	Env    *Environment
+	Result string
+	Error  string
+}
@@ -2,12 +2,22 @@ package model
 
 import (
 	"context"
+	"errors"
 )
 
+// ErrEnvironmentNotFound is returned, possibly wrapped, by
+// Connector.CheckEnvironment when the environment no longer exists.
+var ErrEnvironmentNotFound = errors.New("environment not found")
+
 type Connector interface {
+	// CheckEnvironment returns ErrEnvironmentNotFound if the environment
+	// does not exist and other errors if it could not be checked.
 	CheckEnvironment(ctx context.Context, env *Environment) error
 	DeleteEnvironment(ctx context.Context, env *Environment) error
 	GetConnectorType() string
 	GetEnvironments(ctx context.Context) ([]Environment, error)
 	GetEnvironmentID(ctx context.Context, env *Environment) (string, error)
+	// Ping checks that the infrastructure behind the connector is
+	// reachable.
+	Ping(ctx context.Context) error
 }
@@ -3,8 +3,62 @@ package model
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 )
 
+// Sources of an environment's current delete_at.
+const (
+	DeleteAtSourceCrawler = "crawler"
+	DeleteAtSourceAPI     = "api"
+	DeleteAtSourceExtend  = "extend"
+)
+
+// Sources of an environment's protection.
+const (
+	ProtectedByMetadata = "metadata"
+	ProtectedByAPI      = "api"
+)
+
+// Environment lifecycle statuses.
+const (
+	StatusActive   = "active"
+	StatusWarned   = "warned"
+	StatusDeleting = "deleting"
+	StatusDeleted  = "deleted"
+	StatusFailed   = "failed"
+	StatusGone     = "gone"
+)
+
+// Statuses lists every environment status.
+var Statuses = []string{
+	StatusActive,
+	StatusWarned,
+	StatusDeleting,
+	StatusDeleted,
+	StatusFailed,
+	StatusGone,
+}
+
+// StatusTransitions maps a status to the statuses it can be entered from.
+var StatusTransitions = map[string][]string{
+	// An environment returns to active when the delete_at it was warned
+	// about changes, when it reappears after being gone, or when a failed
+	// one is given a new delete_at. A failed deletion attempt below the
+	// failure limit returns the environment to its previous status.
+	StatusActive:   {StatusWarned, StatusGone, StatusFailed, StatusDeleting},
+	StatusWarned:   {StatusActive, StatusDeleting},
+	StatusDeleting: {StatusActive, StatusWarned, StatusFailed, StatusDeleting},
+	StatusDeleted:  {StatusDeleting},
+	StatusFailed:   {StatusDeleting},
+	StatusGone:     {StatusActive, StatusWarned, StatusFailed},
+}
+
+// ValidStatus reports whether status is a known environment status.
+func ValidStatus(status string) bool {
+	return slices.Contains(Statuses, status)
+}
+
 type Environment struct {
 	EnvID       string
 	Type        string
@@ -13,6 +67,30 @@ type Environment struct {
 	Owner       string
 	DeleteAt    string
 	DeleteAtSec int64
+	// TTL is the lifetime from the environment metadata or registration
+	// request the current delete_at was calculated from.
+	TTL string
+	// DeleteAtSource records what set the current delete_at.
+	DeleteAtSource string
+	Status         string
+	// StatusChangedAt is the unix time of the last status change.
+	StatusChangedAt int64
+	// FailureCount is the number of failed deletion attempts, LastError
+	// the error of the last one. The Deleter does not retry before
+	// NextAttemptAt (unix time).
+	FailureCount  int
+	LastError     string
+	NextAttemptAt int64
+	// Protected environments are not deleted until ProtectedUntil (unix
+	// time), or indefinitely if it is zero. ProtectedBy records whether the
+	// protection comes from connector metadata or the API.
+	Protected       bool
+	ProtectedUntil  int64
+	ProtectedReason string
+	ProtectedBy     string
+	// Labels are free-form key=value pairs from the connector metadata or
+	// the registration request.
+	Labels map[string]string
 }
 
 func (e *Environment) DisplayName() string {
@@ -24,18 +102,137 @@ func (e *Environment) DisplayName() string {
 	return e.Name
 }
 
+// IsProtected reports whether the environment is protected at unix time now.
+func (e *Environment) IsProtected(now int64) bool {
+	return e.Protected && (e.ProtectedUntil == 0 || now < e.ProtectedUntil)
+}
+
+// Fields environment lists can be sorted by.
+const (
+	SortByEnvID     = "env_id"
+	SortByName      = "name"
+	SortByOwner     = "owner"
+	SortByType      = "type"
+	SortByNamespace = "namespace"
+	SortByDeleteAt  = "delete_at"
+)
+
+// SortFields lists every field environment lists can be sorted by.
+var SortFields = []string{
+	SortByEnvID,
+	SortByName,
+	SortByOwner,
+	SortByType,
+	SortByNamespace,
+	SortByDeleteAt,
+}
+
+// ParseSort splits a sort order such as "-delete_at" into the field and
+// whether it is descending. The field defaults to env_id.
+func ParseSort(sort string) (field string, desc bool) {
+	field, desc = strings.CutPrefix(sort, "-")
+	if field == "" {
+		field = SortByEnvID
+	}
+	return field, desc
+}
+
+// SortKey returns the value of the sort field of the environment.
+func (e *Environment) SortKey(field string) any {
+	switch field {
+	case SortByName:
+		return e.Name
+	case SortByOwner:
+		return e.Owner
+	case SortByType:
+		return e.Type
+	case SortByNamespace:
+		return e.Namespace
+	case SortByDeleteAt:
+		return e.DeleteAtSec
+	default:
+		return e.EnvID
+	}
+}
+
+// EnvironmentCursor is a position in a sorted environment list: the sort
+// key and the env_id of the last environment returned.
+type EnvironmentCursor struct {
+	Key   any
+	EnvID string
+}
+
+// EnvironmentFilter narrows down GetEnvironments results. Empty fields
+// do not filter.
+type EnvironmentFilter struct {
+	// Statuses defaults to every status except deleted.
+	Statuses  []string
+	Owner     string
+	Type      string
+	Namespace string
+	// Name is a glob pattern, * matching any sequence of characters and ?
+	// a single one. Character classes are not supported, except for the
+	// single character ones EscapeGlob matches metacharacters with.
+	Name string
+	// Labels selects environments that have every label, or only the key
+	// for labels with an empty value.
+	Labels map[string]string
+	// DeleteBefore and DeleteAfter bound DeleteAtSec, both exclusive.
+	DeleteBefore int64
+	DeleteAfter  int64
+	// Sort is a sort field, descending with a leading "-". Environments
+	// with equal keys are ordered by env_id.
+	Sort string
+	// After continues a list past the cursor position in Sort order.
+	After *EnvironmentCursor
+	// Limit caps the number of returned environments, zero does not.
+	Limit int
+}
+
 type Repository interface {
 	EnvRepository
 	TokenRepository
+	AuditRepository
+	APIKeyRepository
+	Ping(ctx context.Context) error
 	Close() error
 }
 
 type EnvRepository interface {
 	WriteEnvironments(ctx context.Context, envs []Environment) error
-	GetEnvironments(ctx context.Context) ([]*Environment, error)
+	GetEnvironments(ctx context.Context, filter *EnvironmentFilter) ([]*Environment, error)
+	GetEnvironmentsByType(ctx context.Context, envType string) ([]*Environment, error)
 	GetEnvByID(ctx context.Context, id string) (*Environment, error)
 	GetStaleEnvironments(ctx context.Context, tr int64) ([]*Environment, error)
 	GetOutdatedEnvironments(ctx context.Context) ([]*Environment, error)
 	ExtendEnvironment(ctx context.Context, id, period string) error
+	UpdateEnvironment(ctx context.Context, env *Environment) error
+	SetEnvironmentStatus(ctx context.Context, id, status string) error
+	SetProtection(ctx context.Context, env *Environment) error
+	SetDeleteFailure(
+		ctx context.Context,
+		id string,
+		failureCount int,
+		lastError string,
+		nextAttemptAt int64,
+	) error
 	DeleteEnvironment(ctx context.Context, id string) error
+	PurgeEnvironments(ctx context.Context, status string, before int64) (int64, error)
+}
+
+// EscapeGlob returns s as a glob pattern of EnvironmentFilter.Name matching
+// only s. The metacharacters *, ? and [ are enclosed in brackets.
+func EscapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[':
+			b.WriteRune('[')
+			b.WriteRune(r)
+			b.WriteRune(']')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
 }
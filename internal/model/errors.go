@@ -23,3 +23,11 @@ type ConflictError struct {
 func (e *ConflictError) Error() string {
 	return e.Msg
 }
+
+type ForbiddenError struct {
+	Msg string
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.Msg
+}
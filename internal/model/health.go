@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// Health statuses of components and reports. A report is degraded if only
+// components that do not affect the probe are failing.
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthFailing  = "failing"
+)
+
+// ComponentHealth is the result of checking a single component.
+// LastSuccess is set for periodic runs and is zero if none succeeded yet.
+type ComponentHealth struct {
+	Status      string
+	Error       string
+	LastSuccess time.Time
+}
+
+// HealthReport is the result of a health check. Status is failing if any
+// of the components the probe depends on is, degraded if any other is.
+type HealthReport struct {
+	Status     string
+	Components map[string]ComponentHealth
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// labelPattern matches label keys and values. Commas, equal signs and
+// colons are excluded, so that labels fit the stored form and vSphere
+// annotations.
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+
+// ParseLabels parses labels written as comma separated key=value pairs,
+// e.g. "team=qa,env=dev". An empty string is an empty set of labels.
+func ParseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("label %q: missing value", pair)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if _, dup := labels[key]; dup {
+			return nil, fmt.Errorf("label %q: duplicate key", key)
+		}
+		labels[key] = value
+	}
+
+	if err := ValidateLabels(labels); err != nil {
+		return nil, err
+	}
+
+	return labels, nil
+}
+
+// ValidateLabels checks that label keys and values consist of letters,
+// digits and ._/- characters and start with a letter or digit.
+func ValidateLabels(labels map[string]string) error {
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		if !labelPattern.MatchString(key) {
+			return fmt.Errorf("label %q: invalid key", key)
+		}
+		if !labelPattern.MatchString(labels[key]) {
+			return fmt.Errorf("label %q: invalid value %q", key, labels[key])
+		}
+	}
+
+	return nil
+}
+
+// FormatLabels returns labels in the form parsed by ParseLabels, sorted by
+// key. Labels are stored in this form.
+func FormatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ParseLabelSelector parses a label selector written as comma separated
+// key=value pairs and bare keys, e.g. "team=qa,critical". A bare key
+// matches environments that have the label with any value and is returned
+// with an empty value.
+func ParseLabelSelector(s string) (map[string]string, error) {
+	selector := map[string]string{}
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		key, value, hasValue := strings.Cut(term, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !labelPattern.MatchString(key) {
+			return nil, fmt.Errorf("label selector %q: invalid key", term)
+		}
+		if hasValue && !labelPattern.MatchString(value) {
+			return nil, fmt.Errorf("label selector %q: invalid value", term)
+		}
+		if _, dup := selector[key]; dup {
+			return nil, fmt.Errorf("label selector %q: duplicate key", term)
+		}
+		selector[key] = value
+	}
+
+	return selector, nil
+}
+
+// MatchLabels reports whether labels contain every label of the selector.
+// Selector labels with an empty value only require the key.
+func MatchLabels(labels, selector map[string]string) bool {
+	for key, want := range selector {
+		got, ok := labels[key]
+		if !ok || (want != "" && got != want) {
+			return false
+		}
+	}
+	return true
+}
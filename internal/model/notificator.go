@@ -4,4 +4,7 @@ type Notificator interface {
 	SendOrphanMessage(env *Environment) error
 	SendStaleMessage(env *Environment, tk *Token) error
 	SendDeleteMessage(env *Environment) error
+	SendGoneMessage(env *Environment) error
+	SendDeleteFailedMessage(env *Environment) error
+	SendProtectedReport(envs []*Environment) error
 }
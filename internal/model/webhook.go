@@ -0,0 +1,25 @@
+package model
+
+// Git hosting providers webhooks are accepted from.
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea"
+)
+
+// States of merge requests that end their review environments.
+const (
+	MergeRequestMerged = "merged"
+	MergeRequestClosed = "closed"
+)
+
+// MergeRequestEvent is a merge or pull request that was merged or closed
+// without merging. Project is the path of the repository including its
+// namespace, e.g. group/app.
+type MergeRequestEvent struct {
+	Provider     string
+	Project      string
+	Number       int
+	SourceBranch string
+	State        string
+}
@@ -1,9 +1,14 @@
 package notifications
 
 import (
+	"errors"
 	"fmt"
+	"html"
 	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/fragpit/env-cleaner/internal/metrics"
 	"github.com/fragpit/env-cleaner/internal/model"
 	"github.com/fragpit/env-cleaner/pkg/notificator"
 )
@@ -26,13 +31,16 @@ type SlackConfig struct {
 }
 
 type EmailConfig struct {
-	Enabled           bool
-	SMTPServerAddress string
-	SMTPServerPort    int
-	Username          string
-	Password          string
-	SenderEmail       string
-	AdminEmail        string
+	Enabled            bool
+	SMTPServerAddress  string
+	SMTPServerPort     int
+	TLS                string
+	InsecureSkipVerify bool
+	Username           string
+	Password           string
+	SenderEmail        string
+	AdminEmail         string
+	OwnerDomain        string
 
 	EmailNotificator *notificator.EmailNotificator
 }
@@ -56,6 +64,8 @@ func New(
 			emailCfg.SMTPServerPort,
 			emailCfg.Username,
 			emailCfg.Password,
+			emailCfg.TLS,
+			emailCfg.InsecureSkipVerify,
 		)
 	}
 
@@ -74,13 +84,64 @@ var orphanMessage = `
 
 var staleMessage = `
 **Environment %s, type: %s, is stale and will be deleted in %s**
-[Extend your environment](%[4]s/extend?env_id=%[5]s&token=%[6]s)
+[Extend your environment](%s)
 `
 
 var deleteMessage = `
 **Environment: %s, type: %s, is outdated and has been deleted**
 `
 
+var goneMessage = `
+**Environment: %s, type: %s, disappeared outside env-cleaner and is no longer tracked**
+`
+
+var deleteFailedMessage = `
+**Environment: %s, type: %s, failed to be deleted %d times, last error: %s**
+`
+
+var protectedReportMessage = `
+**Outdated environments skipped because they are protected:**
+%s
+`
+
+var (
+	orphanSubject = "Environment %s is orphaned"
+	orphanText    = "Environment: %s, type: %s, is orphaned.\r\n"
+	orphanHTML    = "<p>Environment: <b>%s</b>, type: <b>%s</b>, is orphaned.</p>"
+
+	staleSubject = "Environment %s will be deleted in %s"
+	staleText    = "Environment %s, type: %s, is stale and will be deleted in %s.\r\n\r\n" +
+		"Extend your environment: %s\r\n"
+	staleHTML = "<p>Environment <b>%s</b>, type: <b>%s</b>, is stale and will be deleted in <b>%s</b>.</p>" +
+		`<p><a href="%s">Extend your environment</a></p>`
+
+	deleteSubject = "Environment %s has been deleted"
+	deleteText    = "Environment: %s, type: %s, is outdated and has been deleted.\r\n"
+	deleteHTML    = "<p>Environment: <b>%s</b>, type: <b>%s</b>, is outdated and has been deleted.</p>"
+
+	goneSubject = "Environment %s disappeared"
+	goneText    = "Environment: %s, type: %s, disappeared outside env-cleaner and is no longer tracked.\r\n"
+	goneHTML    = "<p>Environment: <b>%s</b>, type: <b>%s</b>, disappeared outside env-cleaner and is no longer tracked.</p>"
+
+	protectedReportSubject = "%d outdated environments are protected"
+	protectedReportText    = "Outdated environments skipped because they are protected:\r\n%s"
+	protectedReportHTML    = "<p>Outdated environments skipped because they are protected:</p><ul>%s</ul>"
+
+	deleteFailedSubject = "Environment %s failed to be deleted"
+	deleteFailedText    = "Environment: %s, type: %s, failed to be deleted %d times.\r\n\r\n" +
+		"Last error: %s\r\n"
+	deleteFailedHTML = "<p>Environment: <b>%s</b>, type: <b>%s</b>, failed to be deleted %d times.</p>" +
+		"<p>Last error: %s</p>"
+)
+
+// message is a notification rendered for every supported channel.
+type message struct {
+	slack   string
+	subject string
+	text    string
+	html    string
+}
+
 func (nt *Notificator) SendOrphanMessage(env *model.Environment) error {
 	name := env.DisplayName()
 	slog.Info("sending orphaned message",
@@ -88,27 +149,20 @@ func (nt *Notificator) SendOrphanMessage(env *model.Environment) error {
 		slog.String("type", env.Type),
 	)
 
-	if nt.SlackConfig.Enabled {
-		slackChannel := nt.AdminChannel
-		msg, err := notificator.NewSlackMessage(
-			nt.SenderName,
-			slackChannel,
-			fmt.Sprintf(
-				orphanMessage,
-				name,
-				env.Type,
-			))
-
-		if err != nil {
-			return fmt.Errorf("error creating slack message: %w", err)
-		}
+	msg := message{
+		slack:   fmt.Sprintf(orphanMessage, name, env.Type),
+		subject: fmt.Sprintf(orphanSubject, name),
+		text:    fmt.Sprintf(orphanText, name, env.Type),
+		html: fmt.Sprintf(
+			orphanHTML, html.EscapeString(name), html.EscapeString(env.Type),
+		),
+	}
 
-		if err := nt.SlackNotificator.Send(msg); err != nil {
-			return fmt.Errorf(
-				"error sending slack notification, for environment %s: %w",
-				name, err,
-			)
-		}
+	if err := nt.send(nt.AdminChannel, nt.AdminEmail, msg); err != nil {
+		return fmt.Errorf(
+			"error sending notification, for environment %s: %w",
+			name, err,
+		)
 	}
 
 	return nil
@@ -125,42 +179,25 @@ func (nt *Notificator) SendStaleMessage(
 		slog.String("id", env.EnvID),
 	)
 
-	if nt.SlackConfig.Enabled {
-		slackChannel := env.Owner
-		if nt.adminOnly {
-			slackChannel = nt.AdminChannel
-		}
-
-		msg, err := notificator.NewSlackMessage(
-			nt.SenderName,
-			slackChannel,
-			fmt.Sprintf(staleMessage,
-				name,
-				env.Type,
-				nt.staleThreshold,
-				nt.apiURL,
-				env.EnvID,
-				tk.Token,
-			))
-
-		if err != nil {
-			return fmt.Errorf(
-				"error creating slack message for environment %s, type: %s, id: %s: %w",
-				name, env.Type, env.EnvID, err)
-		}
-
-		if err := nt.SlackNotificator.Send(msg); err != nil {
-			return fmt.Errorf(
-				"error sending slack notification for environment %s, type: %s, id: %s: %w",
-				name,
-				env.Type,
-				env.EnvID,
-				err,
-			)
-		}
+	link := nt.extendURL(env, tk)
+	msg := message{
+		slack: fmt.Sprintf(
+			staleMessage, name, env.Type, nt.staleThreshold, link,
+		),
+		subject: fmt.Sprintf(staleSubject, name, nt.staleThreshold),
+		text: fmt.Sprintf(
+			staleText, name, env.Type, nt.staleThreshold, link,
+		),
+		html: fmt.Sprintf(
+			staleHTML,
+			html.EscapeString(name),
+			html.EscapeString(env.Type),
+			nt.staleThreshold,
+			html.EscapeString(link),
+		),
 	}
 
-	return nil
+	return nt.sendToOwner(env, msg)
 }
 
 func (nt *Notificator) SendDeleteMessage(env *model.Environment) error {
@@ -171,37 +208,214 @@ func (nt *Notificator) SendDeleteMessage(env *model.Environment) error {
 		slog.String("id", env.EnvID),
 	)
 
+	msg := message{
+		slack:   fmt.Sprintf(deleteMessage, name, env.Type),
+		subject: fmt.Sprintf(deleteSubject, name),
+		text:    fmt.Sprintf(deleteText, name, env.Type),
+		html: fmt.Sprintf(
+			deleteHTML, html.EscapeString(name), html.EscapeString(env.Type),
+		),
+	}
+
+	return nt.sendToOwner(env, msg)
+}
+
+func (nt *Notificator) SendGoneMessage(env *model.Environment) error {
+	name := env.DisplayName()
+	slog.Info("sending gone message",
+		slog.String("environment", name),
+		slog.String("type", env.Type),
+		slog.String("id", env.EnvID),
+	)
+
+	msg := message{
+		slack:   fmt.Sprintf(goneMessage, name, env.Type),
+		subject: fmt.Sprintf(goneSubject, name),
+		text:    fmt.Sprintf(goneText, name, env.Type),
+		html: fmt.Sprintf(
+			goneHTML, html.EscapeString(name), html.EscapeString(env.Type),
+		),
+	}
+
+	return nt.sendToOwner(env, msg)
+}
+
+// SendDeleteFailedMessage escalates an environment that keeps failing to be
+// deleted to the admin.
+func (nt *Notificator) SendDeleteFailedMessage(env *model.Environment) error {
+	name := env.DisplayName()
+	slog.Info("sending delete failed message",
+		slog.String("environment", name),
+		slog.String("type", env.Type),
+		slog.String("id", env.EnvID),
+	)
+
+	msg := message{
+		slack: fmt.Sprintf(
+			deleteFailedMessage, name, env.Type, env.FailureCount, env.LastError,
+		),
+		subject: fmt.Sprintf(deleteFailedSubject, name),
+		text: fmt.Sprintf(
+			deleteFailedText, name, env.Type, env.FailureCount, env.LastError,
+		),
+		html: fmt.Sprintf(
+			deleteFailedHTML,
+			html.EscapeString(name),
+			html.EscapeString(env.Type),
+			env.FailureCount,
+			html.EscapeString(env.LastError),
+		),
+	}
+
+	if err := nt.send(nt.AdminChannel, nt.AdminEmail, msg); err != nil {
+		return fmt.Errorf(
+			"error sending notification for environment %s, type: %s, id: %s: %w",
+			name, env.Type, env.EnvID, err,
+		)
+	}
+
+	return nil
+}
+
+// SendProtectedReport lists outdated environments the Deleter skipped
+// because they are protected to the admin.
+func (nt *Notificator) SendProtectedReport(envs []*model.Environment) error {
+	slog.Info("sending protected report", slog.Int("count", len(envs)))
+
+	var slackLines, textLines, htmlItems strings.Builder
+	for _, env := range envs {
+		line := protectedLine(env)
+		fmt.Fprintf(&slackLines, "- %s\n", line)
+		fmt.Fprintf(&textLines, "- %s\r\n", line)
+		fmt.Fprintf(&htmlItems, "<li>%s</li>", html.EscapeString(line))
+	}
+
+	msg := message{
+		slack:   fmt.Sprintf(protectedReportMessage, slackLines.String()),
+		subject: fmt.Sprintf(protectedReportSubject, len(envs)),
+		text:    fmt.Sprintf(protectedReportText, textLines.String()),
+		html:    fmt.Sprintf(protectedReportHTML, htmlItems.String()),
+	}
+
+	if err := nt.send(nt.AdminChannel, nt.AdminEmail, msg); err != nil {
+		return fmt.Errorf("error sending protected report: %w", err)
+	}
+
+	return nil
+}
+
+// protectedLine describes a protected environment in reports.
+func protectedLine(env *model.Environment) string {
+	line := fmt.Sprintf(
+		"%s, type: %s, owner: %s, delete at: %s",
+		env.DisplayName(), env.Type, env.Owner, env.DeleteAt,
+	)
+	if env.ProtectedReason != "" {
+		line += ", reason: " + env.ProtectedReason
+	}
+	if env.ProtectedUntil != 0 {
+		line += ", until: " + time.Unix(env.ProtectedUntil, 0).
+			Format("02-01-06 15:04:05")
+	}
+
+	return line
+}
+
+// sendToOwner delivers msg to the environment owner, or to the admin
+// channels when admin_only is set.
+func (nt *Notificator) sendToOwner(
+	env *model.Environment,
+	msg message,
+) error {
+	slackChannel := env.Owner
+	if nt.adminOnly {
+		slackChannel = nt.AdminChannel
+	}
+
+	if err := nt.send(slackChannel, nt.ownerEmail(env.Owner), msg); err != nil {
+		return fmt.Errorf(
+			"error sending notification for environment %s, type: %s, id: %s: %w",
+			env.DisplayName(), env.Type, env.EnvID, err,
+		)
+	}
+
+	return nil
+}
+
+// send delivers msg to every enabled channel. A failure on one channel
+// does not prevent delivery to the others.
+func (nt *Notificator) send(slackChannel, email string, msg message) error {
+	var errs []error
+
 	if nt.SlackConfig.Enabled {
-		slackChannel := env.Owner
-		if nt.adminOnly {
-			slackChannel = nt.AdminChannel
+		if err := nt.sendSlack(slackChannel, msg.slack); err != nil {
+			metrics.NotificationFailed("slack")
+			errs = append(errs, err)
 		}
+	}
 
-		msg, err := notificator.NewSlackMessage(
-			nt.SenderName,
-			slackChannel,
-			fmt.Sprintf(
-				deleteMessage,
-				name,
-				env.Type,
-			))
-
-		if err != nil {
-			return fmt.Errorf(
-				"error creating slack message for environment %s, type: %s, id: %s: %w",
-				name, env.Type, env.EnvID, err)
+	if nt.EmailConfig.Enabled {
+		if err := nt.sendEmail(
+			email, msg.subject, msg.text, msg.html,
+		); err != nil {
+			metrics.NotificationFailed("email")
+			errs = append(errs, err)
 		}
+	}
 
-		if err := nt.SlackNotificator.Send(msg); err != nil {
-			return fmt.Errorf(
-				"error sending slack notification for environment %s, type: %s, id: %s: %w",
-				name,
-				env.Type,
-				env.EnvID,
-				err,
-			)
-		}
+	return errors.Join(errs...)
+}
+
+func (nt *Notificator) sendSlack(channel, text string) error {
+	msg, err := notificator.NewSlackMessage(nt.SenderName, channel, text)
+	if err != nil {
+		return fmt.Errorf("error creating slack message: %w", err)
+	}
+
+	if err := nt.SlackNotificator.Send(msg); err != nil {
+		return fmt.Errorf("error sending slack notification: %w", err)
+	}
+
+	return nil
+}
+
+func (nt *Notificator) sendEmail(
+	to, subject, text, htmlBody string,
+) error {
+	msg, err := notificator.NewEmailMessage(
+		nt.SenderEmail,
+		to,
+		subject,
+		text,
+		htmlBody,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating email message: %w", err)
+	}
+
+	if err := nt.EmailNotificator.Send(msg); err != nil {
+		return fmt.Errorf("error sending email notification: %w", err)
 	}
 
 	return nil
 }
+
+func (nt *Notificator) extendURL(env *model.Environment, tk *model.Token) string {
+	return fmt.Sprintf(
+		"%s/extend?env_id=%s&token=%s", nt.apiURL, env.EnvID, tk.Token,
+	)
+}
+
+// ownerEmail returns the recipient address for owner notifications.
+// Owners that are not email addresses get owner_domain appended.
+func (nt *Notificator) ownerEmail(owner string) string {
+	if nt.adminOnly {
+		return nt.AdminEmail
+	}
+
+	if !strings.Contains(owner, "@") && nt.OwnerDomain != "" {
+		return owner + "@" + nt.OwnerDomain
+	}
+
+	return owner
+}
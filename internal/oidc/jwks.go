@@ -0,0 +1,79 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// parseJWKS parses a JWK set document and returns its RSA and EC
+// signature verification keys. Encryption keys and keys of other types are
+// skipped.
+func parseJWKS(data []byte) ([]jose.JSONWebKey, error) {
+	var set jose.JSONWebKeySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("error decoding jwks: %w", err)
+	}
+
+	var keys []jose.JSONWebKey
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		switch key := k.Key.(type) {
+		case *rsa.PublicKey:
+			if key.E < 2 {
+				return nil, fmt.Errorf("error parsing key %q: invalid exponent", k.KeyID)
+			}
+			keys = append(keys, k)
+		case *ecdsa.PublicKey:
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks has no supported signing keys")
+	}
+
+	return keys, nil
+}
+
+// fileKeySet verifies token signatures with the keys of a JWKS file. The
+// key is picked by the kid of the token, tokens without one match a set
+// with a single key.
+type fileKeySet struct {
+	keys []jose.JSONWebKey
+}
+
+func (s *fileKeySet) VerifySignature(
+	_ context.Context,
+	token string,
+) ([]byte, error) {
+	algs := make([]jose.SignatureAlgorithm, 0, len(signingAlgorithms))
+	for _, alg := range signingAlgorithms {
+		algs = append(algs, jose.SignatureAlgorithm(alg))
+	}
+
+	jws, err := jose.ParseSigned(token, algs)
+	if err != nil {
+		return nil, err
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, fmt.Errorf("expected one signature")
+	}
+
+	kid := jws.Signatures[0].Header.KeyID
+	for _, key := range s.keys {
+		if key.KeyID != kid && (kid != "" || len(s.keys) != 1) {
+			continue
+		}
+		return jws.Verify(key)
+	}
+
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
@@ -0,0 +1,97 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var defaultScopes = []string{oidc.ScopeOpenID, "profile", "email"}
+
+// RandomString returns a random URL safe string for login states and PKCE
+// code verifiers.
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthCodeURL returns the provider URL a browser is sent to for login.
+// The provider redirects back to redirectURI with state and a code for
+// Exchange. codeVerifier is the PKCE secret passed to Exchange later.
+func (v *Verifier) AuthCodeURL(
+	ctx context.Context,
+	redirectURI, state, codeVerifier string,
+) (string, error) {
+	conf, err := v.oauth2Config(ctx, redirectURI)
+	if err != nil {
+		return "", err
+	}
+
+	return conf.AuthCodeURL(state, oauth2.S256ChallengeOption(codeVerifier)), nil
+}
+
+// Exchange redeems a login code and returns the ID token.
+func (v *Verifier) Exchange(
+	ctx context.Context,
+	redirectURI, code, codeVerifier string,
+) (string, error) {
+	conf, err := v.oauth2Config(ctx, redirectURI)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := conf.Exchange(
+		oidc.ClientContext(ctx, v.client), code,
+		oauth2.VerifierOption(codeVerifier),
+	)
+	if err != nil {
+		return "", fmt.Errorf("error exchanging login code: %w", err)
+	}
+
+	idToken, _ := token.Extra("id_token").(string)
+	if idToken == "" {
+		return "", fmt.Errorf("token response has no id_token")
+	}
+
+	return idToken, nil
+}
+
+func (v *Verifier) oauth2Config(
+	ctx context.Context,
+	redirectURI string,
+) (*oauth2.Config, error) {
+	if !v.LoginEnabled() {
+		return nil, fmt.Errorf("oidc login is not configured")
+	}
+
+	d, err := v.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := d.provider.Endpoint()
+	if endpoint.AuthURL == "" || endpoint.TokenURL == "" {
+		return nil, fmt.Errorf("oidc discovery has no login endpoints")
+	}
+
+	scopes := v.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+
+	return &oauth2.Config{
+		ClientID:     v.cfg.ClientID,
+		ClientSecret: v.cfg.ClientSecret,
+		Endpoint:     endpoint,
+		RedirectURL:  redirectURI,
+		Scopes:       scopes,
+	}, nil
+}
@@ -0,0 +1,278 @@
+// Package oidc verifies JWTs issued by an OpenID Connect provider and
+// runs the authorization code flow for browser logins.
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultIdentityClaim = "preferred_username"
+	defaultGroupsClaim   = "groups"
+
+	// minRefreshInterval limits discovery retries after a failure.
+	minRefreshInterval = time.Minute
+	httpTimeout        = 10 * time.Second
+)
+
+// signingAlgorithms are the JWS algorithms tokens may be signed with.
+var signingAlgorithms = []string{
+	oidc.RS256, oidc.RS384, oidc.RS512,
+	oidc.PS256, oidc.PS384, oidc.PS512,
+	oidc.ES256, oidc.ES384, oidc.ES512,
+}
+
+// ErrInvalidToken is returned for tokens that fail verification.
+var ErrInvalidToken = errors.New("invalid token")
+
+type Config struct {
+	// IssuerURL is used for discovery of the JWKS and the login endpoints.
+	IssuerURL string
+	// Issuer is checked against the iss claim, defaults to IssuerURL.
+	Issuer string
+	// JWKSFile is a static JWK set used instead of the issuer keys.
+	JWKSFile string
+	// Audience is checked against the aud claim, defaults to ClientID.
+	Audience      string
+	IdentityClaim string
+	GroupsClaim   string
+	// ClientID and ClientSecret enable browser logins.
+	ClientID     string
+	ClientSecret string
+	// Scopes are requested on browser logins.
+	Scopes []string
+}
+
+// Identity is the person a verified token was issued to.
+type Identity struct {
+	Name   string
+	Groups []string
+	// ExpiresAt is the unix time the token expires.
+	ExpiresAt int64
+}
+
+// discovery is the result of OpenID Connect Discovery: the provider and a
+// verifier using the issuer keys.
+type discovery struct {
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+}
+
+type Verifier struct {
+	cfg    Config
+	client *http.Client
+	// fileVerifier verifies tokens with the keys of JWKSFile.
+	fileVerifier *oidc.IDTokenVerifier
+
+	// discovery runs once at a time, outside of mu, so that a slow
+	// issuer only holds up the requests waiting for it.
+	group    singleflight.Group
+	mu       sync.Mutex
+	provider *discovery
+	err      error
+	failedAt time.Time
+}
+
+// New returns a verifier for cfg. A static JWKS file is read here, the
+// issuer is discovered on first use.
+func New(cfg Config) (*Verifier, error) {
+	if cfg.IssuerURL == "" && cfg.JWKSFile == "" {
+		return nil, fmt.Errorf("either issuer_url or jwks_file is required")
+	}
+	if cfg.Issuer == "" {
+		cfg.Issuer = cfg.IssuerURL
+	}
+	if cfg.Issuer == "" {
+		return nil, fmt.Errorf("issuer is required with jwks_file")
+	}
+	if cfg.Audience == "" && cfg.ClientID == "" {
+		return nil, fmt.Errorf("either audience or client_id is required")
+	}
+	if cfg.IdentityClaim == "" {
+		cfg.IdentityClaim = defaultIdentityClaim
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = defaultGroupsClaim
+	}
+
+	v := &Verifier{
+		cfg:    cfg,
+		client: &http.Client{Timeout: httpTimeout},
+	}
+
+	if cfg.JWKSFile != "" {
+		data, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading jwks file: %w", err)
+		}
+		keys, err := parseJWKS(data)
+		if err != nil {
+			return nil, err
+		}
+		v.fileVerifier = v.newVerifier(&fileKeySet{keys: keys})
+	}
+
+	return v, nil
+}
+
+// LoginEnabled reports whether browser logins are configured.
+func (v *Verifier) LoginEnabled() bool {
+	return v.cfg.ClientID != "" && v.cfg.IssuerURL != ""
+}
+
+// IsJWT reports whether token looks like a compact serialized JWT, as
+// opposed to an opaque API key.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Verify checks the signature and the claims of token and returns the
+// identity it was issued to.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Identity, error) {
+	verifier := v.fileVerifier
+	if verifier == nil {
+		d, err := v.discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		verifier = d.verifier
+	}
+
+	idToken, err := verifier.Verify(oidc.ClientContext(ctx, v.client), token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	name, _ := claims[v.cfg.IdentityClaim].(string)
+	if name == "" {
+		return nil, fmt.Errorf(
+			"%w: missing %s claim", ErrInvalidToken, v.cfg.IdentityClaim,
+		)
+	}
+
+	return &Identity{
+		Name:      name,
+		Groups:    stringsClaim(claims[v.cfg.GroupsClaim]),
+		ExpiresAt: idToken.Expiry.Unix(),
+	}, nil
+}
+
+// newVerifier returns a token verifier checking the configured issuer and
+// audience with keys. ID tokens of browser logins are issued for the
+// client.
+func (v *Verifier) newVerifier(keys oidc.KeySet) *oidc.IDTokenVerifier {
+	audience := v.cfg.Audience
+	if audience == "" {
+		audience = v.cfg.ClientID
+	}
+
+	return oidc.NewVerifier(v.cfg.Issuer, keys, &oidc.Config{
+		ClientID:             audience,
+		SupportedSigningAlgs: signingAlgorithms,
+	})
+}
+
+// discover returns the provider, running OpenID Connect Discovery on
+// first use. A failed discovery is retried at most once per
+// minRefreshInterval. Callers stop waiting when ctx is done.
+func (v *Verifier) discover(ctx context.Context) (*discovery, error) {
+	v.mu.Lock()
+	d, err, failedAt := v.provider, v.err, v.failedAt
+	v.mu.Unlock()
+
+	if d != nil {
+		return d, nil
+	}
+	if err != nil && time.Since(failedAt) < minRefreshInterval {
+		return nil, err
+	}
+
+	ch := v.group.DoChan("discovery", func() (any, error) {
+		d, err := v.fetchProvider()
+
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		v.provider, v.err = d, err
+		if err != nil {
+			v.failedAt = time.Now()
+		}
+
+		return d, err
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*discovery), nil
+	}
+}
+
+func (v *Verifier) fetchProvider() (*discovery, error) {
+	// Key sets keep the context for their requests, so it carries the
+	// client with its timeout but no deadline.
+	ctx := oidc.ClientContext(context.Background(), v.client)
+
+	provider, err := oidc.NewProvider(ctx, v.cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching oidc discovery: %w", err)
+	}
+
+	var claims struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := provider.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("error decoding oidc discovery: %w", err)
+	}
+	if claims.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery has no jwks_uri")
+	}
+
+	slog.Info("discovered oidc provider",
+		slog.String("issuer", v.cfg.IssuerURL),
+		slog.String("jwks_uri", claims.JWKSURI),
+	)
+
+	return &discovery{
+		provider: provider,
+		verifier: v.newVerifier(oidc.NewRemoteKeySet(ctx, claims.JWKSURI)),
+	}, nil
+}
+
+// stringsClaim returns a claim that is either a string or a list of
+// strings.
+func stringsClaim(claim any) []string {
+	switch c := claim.(type) {
+	case string:
+		return []string{c}
+	case []any:
+		values := make([]string, 0, len(c))
+		for _, v := range c {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
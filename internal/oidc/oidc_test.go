@@ -0,0 +1,527 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://sso.example.com/realms/dev"
+	testAudience = "env-cleaner"
+)
+
+// testKeys is a locally generated signing key set.
+type testKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ec key: %v", err)
+	}
+
+	return &testKeys{rsa: rsaKey, ec: ecKey}
+}
+
+// jwks returns the public keys as a JWK set with the key IDs "rsa" and
+// "ec", and an encryption key that has to be skipped.
+func (k *testKeys) jwks(t *testing.T) []byte {
+	t.Helper()
+
+	enc := base64.RawURLEncoding.EncodeToString
+	size := (k.ec.Curve.Params().BitSize + 7) / 8
+	doc := map[string]any{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa",
+				"use": "sig",
+				"n":   enc(k.rsa.N.Bytes()),
+				"e":   enc(big.NewInt(int64(k.rsa.E)).Bytes()),
+			},
+			{
+				"kty": "EC",
+				"kid": "ec",
+				"crv": "P-256",
+				"x":   enc(k.ec.X.FillBytes(make([]byte, size))),
+				"y":   enc(k.ec.Y.FillBytes(make([]byte, size))),
+			},
+			{
+				"kty": "RSA",
+				"kid": "enc",
+				"use": "enc",
+				"n":   enc(k.rsa.N.Bytes()),
+				"e":   enc(big.NewInt(int64(k.rsa.E)).Bytes()),
+			},
+		},
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal jwks: %v", err)
+	}
+	return data
+}
+
+// jwksFile writes the JWK set to a temporary file.
+func (k *testKeys) jwksFile(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, k.jwks(t), 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+	return path
+}
+
+// sign returns a compact serialized JWS of claims. The signing key is
+// picked by the algorithm family, so that the header can claim another
+// kid.
+func (k *testKeys) sign(
+	t *testing.T,
+	alg, kid string,
+	claims map[string]any,
+) string {
+	t.Helper()
+
+	signed := encodeSegments(t, map[string]any{"alg": alg, "kid": kid}, claims)
+
+	var sig []byte
+	switch alg {
+	case "none":
+	case "HS256":
+		// Signs with the public RSA key as the HMAC secret, as in
+		// algorithm confusion attacks.
+		pub, err := x509.MarshalPKIXPublicKey(&k.rsa.PublicKey)
+		if err != nil {
+			t.Fatalf("marshal public key: %v", err)
+		}
+		mac := hmac.New(sha256.New, pub)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	default:
+		digest := sha256.Sum256([]byte(signed))
+		var err error
+		switch alg[:2] {
+		case "RS":
+			sig, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+		case "PS":
+			sig, err = rsa.SignPSS(rand.Reader, k.rsa, crypto.SHA256, digest[:],
+				&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		case "ES":
+			var r, s *big.Int
+			r, s, err = ecdsa.Sign(rand.Reader, k.ec, digest[:])
+			if err == nil {
+				sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+			}
+		default:
+			t.Fatalf("unsupported test algorithm %s", alg)
+		}
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func encodeSegments(t *testing.T, header, claims map[string]any) string {
+	t.Helper()
+
+	segments := make([]string, 0, 2)
+	for _, v := range []map[string]any{header, claims} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("marshal token: %v", err)
+		}
+		segments = append(segments, base64.RawURLEncoding.EncodeToString(data))
+	}
+	return strings.Join(segments, ".")
+}
+
+// validClaims returns the claims of a token valid for an hour.
+func validClaims() map[string]any {
+	now := time.Now().Unix()
+	return map[string]any{
+		"iss":                testIssuer,
+		"aud":                testAudience,
+		"exp":                now + 3600,
+		"iat":                now,
+		"preferred_username": "ivanov",
+		"groups":             []string{"dev", "platform"},
+	}
+}
+
+func withClaims(changes map[string]any) map[string]any {
+	claims := validClaims()
+	for name, value := range changes {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	return claims
+}
+
+func newFileVerifier(t *testing.T, keys *testKeys) *Verifier {
+	t.Helper()
+
+	v, err := New(Config{
+		Issuer:   testIssuer,
+		JWKSFile: keys.jwksFile(t),
+		Audience: testAudience,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return v
+}
+
+func TestVerify(t *testing.T) {
+	keys := newTestKeys(t)
+	v := newFileVerifier(t, keys)
+
+	for _, tc := range []struct {
+		name  string
+		token string
+	}{
+		{"RS256", keys.sign(t, "RS256", "rsa", validClaims())},
+		{"PS256", keys.sign(t, "PS256", "rsa", validClaims())},
+		{"ES256", keys.sign(t, "ES256", "ec", validClaims())},
+		{"audience list", keys.sign(t, "RS256", "rsa", withClaims(map[string]any{
+			"aud": []string{"other", testAudience},
+		}))},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			id, err := v.Verify(context.Background(), tc.token)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if id.Name != "ivanov" {
+				t.Errorf("name = %q, want ivanov", id.Name)
+			}
+			if strings.Join(id.Groups, ",") != "dev,platform" {
+				t.Errorf("groups = %v, want [dev platform]", id.Groups)
+			}
+			if id.ExpiresAt <= time.Now().Unix() {
+				t.Errorf("expires at %d, want a future time", id.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	keys := newTestKeys(t)
+	v := newFileVerifier(t, keys)
+	now := time.Now().Unix()
+
+	valid := keys.sign(t, "RS256", "rsa", validClaims())
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"`+testIssuer+
+			`","aud":"`+testAudience+`","exp":9999999999,"preferred_username":"admin"}`)) +
+		"." + parts[2]
+
+	for _, tc := range []struct {
+		name  string
+		token string
+	}{
+		{"alg none", keys.sign(t, "none", "rsa", validClaims())},
+		{"alg none without kid", keys.sign(t, "none", "", validClaims())},
+		{"HMAC with the public key", keys.sign(t, "HS256", "rsa", validClaims())},
+		{"RSA algorithm with EC key", keys.sign(t, "RS256", "ec", validClaims())},
+		{"EC algorithm with RSA key", keys.sign(t, "ES256", "rsa", validClaims())},
+		{"unknown key", keys.sign(t, "RS256", "other", validClaims())},
+		{"encryption key", keys.sign(t, "RS256", "enc", validClaims())},
+		{"tampered payload", tampered},
+		{"expired", keys.sign(t, "RS256", "rsa", withClaims(map[string]any{
+			"exp": now - 60,
+		}))},
+		{"missing exp", keys.sign(t, "RS256", "rsa", withClaims(map[string]any{
+			"exp": nil,
+		}))},
+		{"not valid yet", keys.sign(t, "RS256", "rsa", withClaims(map[string]any{
+			"nbf": now + 3600,
+		}))},
+		{"wrong issuer", keys.sign(t, "RS256", "rsa", withClaims(map[string]any{
+			"iss": "https://evil.example.com",
+		}))},
+		{"issuer with trailing slash", keys.sign(t, "RS256", "rsa", withClaims(map[string]any{
+			"iss": testIssuer + "/",
+		}))},
+		{"missing issuer", keys.sign(t, "RS256", "rsa", withClaims(map[string]any{
+			"iss": nil,
+		}))},
+		{"wrong audience", keys.sign(t, "RS256", "rsa", withClaims(map[string]any{
+			"aud": "other",
+		}))},
+		{"missing audience", keys.sign(t, "RS256", "rsa", withClaims(map[string]any{
+			"aud": nil,
+		}))},
+		{"missing identity", keys.sign(t, "RS256", "rsa", withClaims(map[string]any{
+			"preferred_username": nil,
+		}))},
+		{"malformed", "a.b.c"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := v.Verify(context.Background(), tc.token)
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("Verify error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyDiscoveredKeys(t *testing.T) {
+	keys := newTestKeys(t)
+
+	var issuer string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(
+		w http.ResponseWriter,
+		_ *http.Request,
+	) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   issuer,
+			"jwks_uri": issuer + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(keys.jwks(t))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	issuer = srv.URL
+
+	v, err := New(Config{IssuerURL: issuer, ClientID: testAudience})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx := context.Background()
+	token := keys.sign(t, "ES256", "ec", withClaims(map[string]any{
+		"iss": issuer,
+	}))
+	if _, err := v.Verify(ctx, token); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	token = keys.sign(t, "ES256", "ec", validClaims())
+	if _, err := v.Verify(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Verify with another issuer error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestNewRequiresIssuerAndAudience(t *testing.T) {
+	jwksFile := newTestKeys(t).jwksFile(t)
+
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+	}{
+		{"no keys", Config{Audience: testAudience}},
+		{"jwks file without issuer", Config{
+			JWKSFile: jwksFile,
+			Audience: testAudience,
+		}},
+		{"no audience", Config{IssuerURL: testIssuer}},
+		{"jwks file without audience", Config{
+			JWKSFile: jwksFile,
+			Issuer:   testIssuer,
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := New(tc.cfg); err == nil {
+				t.Fatal("New succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	keys := newTestKeys(t)
+
+	set, err := parseJWKS(keys.jwks(t))
+	if err != nil {
+		t.Fatalf("parseJWKS: %v", err)
+	}
+	if len(set) != 2 {
+		t.Fatalf("got %d keys, want the rsa and ec signing keys", len(set))
+	}
+	if _, ok := set[0].Key.(*rsa.PublicKey); !ok || set[0].KeyID != "rsa" {
+		t.Errorf("first key is %s %T", set[0].KeyID, set[0].Key)
+	}
+	if _, ok := set[1].Key.(*ecdsa.PublicKey); !ok || set[1].KeyID != "ec" {
+		t.Errorf("second key is %s %T", set[1].KeyID, set[1].Key)
+	}
+
+	enc := base64.RawURLEncoding.EncodeToString
+	for _, tc := range []struct {
+		name string
+		doc  string
+	}{
+		{"no signing keys", `{"keys":[{"kty":"oct","kid":"a","k":"c2VjcmV0"}]}`},
+		{"point not on curve", `{"keys":[{"kty":"EC","kid":"a","crv":"P-256","x":"` +
+			enc(make([]byte, 32)) + `","y":"` + enc(make([]byte, 32)) + `"}]}`},
+		{"unsupported curve", `{"keys":[{"kty":"EC","kid":"a","crv":"P-192"}]}`},
+		{"invalid exponent", `{"keys":[{"kty":"RSA","kid":"a","n":"` +
+			enc(keys.rsa.N.Bytes()) + `","e":"AQ"}]}`},
+		{"malformed", `{"keys":`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseJWKS([]byte(tc.doc)); err == nil {
+				t.Fatal("parseJWKS succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestVerifyHangingIssuer(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(
+		_ http.ResponseWriter,
+		r *http.Request,
+	) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+
+	v, err := New(Config{IssuerURL: srv.URL, ClientID: testAudience})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	token := newTestKeys(t).sign(t, "RS256", "rsa", withClaims(map[string]any{
+		"iss": srv.URL,
+	}))
+
+	// Requests waiting for the issuer give up with their own context
+	// instead of queueing behind the discovery.
+	errs := make(chan error, 3)
+	for range 3 {
+		go func() {
+			ctx, cancel := context.WithTimeout(
+				context.Background(), 100*time.Millisecond,
+			)
+			defer cancel()
+			_, err := v.Verify(ctx, token)
+			errs <- err
+		}()
+	}
+
+	timeout := time.After(2 * time.Second)
+	for range 3 {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Verify error = %v, want context.DeadlineExceeded", err)
+			}
+		case <-timeout:
+			t.Fatal("Verify blocked on the hanging issuer")
+		}
+	}
+}
+
+func TestLogin(t *testing.T) {
+	keys := newTestKeys(t)
+
+	var issuer, gotVerifier string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(
+		w http.ResponseWriter,
+		_ *http.Request,
+	) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"jwks_uri":               issuer + "/keys",
+			"authorization_endpoint": issuer + "/auth",
+			"token_endpoint":         issuer + "/token",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(keys.jwks(t))
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		gotVerifier = r.FormValue("code_verifier")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token": keys.sign(t, "RS256", "rsa", withClaims(map[string]any{
+				"iss": issuer,
+			})),
+		})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	issuer = srv.URL
+
+	v, err := New(Config{
+		IssuerURL:    issuer,
+		ClientID:     testAudience,
+		ClientSecret: "secret",
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx := context.Background()
+	const redirectURI = "https://env-cleaner.example.com/auth/callback"
+
+	authURL, err := v.AuthCodeURL(ctx, redirectURI, "state", "verifier")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	for _, want := range []string{
+		issuer + "/auth?",
+		"state=state",
+		"code_challenge_method=S256",
+		"client_id=" + testAudience,
+	} {
+		if !strings.Contains(authURL, want) {
+			t.Errorf("auth URL %s does not contain %s", authURL, want)
+		}
+	}
+
+	idToken, err := v.Exchange(ctx, redirectURI, "code", "verifier")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if gotVerifier != "verifier" {
+		t.Errorf("code_verifier = %q, want verifier", gotVerifier)
+	}
+	if _, err := v.Verify(ctx, idToken); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/fragpit/env-cleaner/internal/config"
+	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/internal/notifications"
+	"github.com/fragpit/env-cleaner/internal/service"
+	"github.com/fragpit/env-cleaner/internal/storage"
+)
+
+// CrawlResult is the outcome of a single crawl of one connector. Err is
+// set if the crawl failed, Summary then covers what was done before.
+type CrawlResult struct {
+	Summary *service.CrawlSummary
+	Err     error
+}
+
+// Crawl runs a single crawl of each enabled connector, or of the listed
+// ones, and returns the results sorted by connector type. A failed crawl
+// does not stop the others.
+func Crawl(connectors []string) ([]CrawlResult, error) {
+	var results []CrawlResult
+	err := runOnce(false, func(
+		ctx context.Context,
+		cfg *config.ServerConfig,
+		st model.Repository,
+		nt *notifications.Notificator,
+	) error {
+		conns, err := newConnectors(ctx, cfg, nt, connectors)
+		if err != nil {
+			return err
+		}
+
+		types := make([]string, 0, len(conns))
+		for t := range conns {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+
+		for _, t := range types {
+			cr := service.NewCrawler(crawlerConfig(cfg), conns[t], st, nt)
+			sum, err := cr.RunOnce(ctx)
+			if err != nil {
+				slog.Error("error running crawler",
+					slog.String("type", t),
+					slog.Any("error", err),
+				)
+			}
+			results = append(results, CrawlResult{Summary: sum, Err: err})
+		}
+
+		return nil
+	})
+
+	return results, err
+}
+
+// Reap runs a single deleter pass. planOnly only plans what the pass would
+// warn and delete, without sending or writing anything.
+func Reap(planOnly bool) (*service.DeleterSummary, error) {
+	var sum *service.DeleterSummary
+	err := runOnce(planOnly, func(
+		ctx context.Context,
+		cfg *config.ServerConfig,
+		st model.Repository,
+		nt *notifications.Notificator,
+	) error {
+		if cfg.DryRun && !planOnly {
+			slog.Warn("dry run mode is enabled")
+		}
+
+		conns, err := newConnectors(ctx, cfg, nt, nil)
+		if err != nil {
+			return err
+		}
+
+		dc := deleterConfig(cfg)
+		dc.PlanOnly = planOnly
+		deleter := service.NewDeleter(
+			dc, &service.ConnectorList{Connectors: conns}, st, nt,
+		)
+		sum, err = deleter.RunOnce(ctx)
+		return err
+	})
+
+	return sum, err
+}
+
+// Notify warns the owners of stale environments once, without deleting
+// anything.
+func Notify() (*service.DeleterSummary, error) {
+	var sum *service.DeleterSummary
+	err := runOnce(false, func(
+		ctx context.Context,
+		cfg *config.ServerConfig,
+		st model.Repository,
+		nt *notifications.Notificator,
+	) error {
+		deleter := service.NewDeleter(
+			deleterConfig(cfg), &service.ConnectorList{}, st, nt,
+		)
+
+		var err error
+		sum, err = deleter.NotifyOnce(ctx)
+		return err
+	})
+
+	return sum, err
+}
+
+// runOnce reads the server configuration, opens the storage and calls f
+// with them, for commands that run a single pass of a service instead of
+// the server. With readOnly the storage is neither created nor migrated,
+// and a missing database or pending migrations are an error.
+func runOnce(readOnly bool, f func(
+	ctx context.Context,
+	cfg *config.ServerConfig,
+	st model.Repository,
+	nt *notifications.Notificator,
+) error) error {
+	cfg, err := config.NewServerConfig()
+	if err != nil {
+		slog.Error("error reading configuration", slog.Any("error", err))
+		return err
+	}
+
+	if err := validateConfig(cfg); err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(
+		context.Background(),
+		syscall.SIGTERM,
+		syscall.SIGINT,
+	)
+	defer cancel()
+
+	var st storage.Storage
+	if readOnly {
+		st, err = storage.OpenCurrent(ctx, cfg)
+	} else {
+		st, err = storage.New(cfg)
+	}
+	if err != nil {
+		slog.Error("error creating storage", slog.Any("error", err))
+		return err
+	}
+
+	defer func() {
+		if err := st.Close(); err != nil {
+			slog.Error("error closing storage", slog.Any("error", err))
+		}
+	}()
+
+	return f(ctx, cfg, st, newNotificator(cfg))
+}
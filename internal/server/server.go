@@ -2,20 +2,25 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os/signal"
 	"sync"
 	"syscall"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"github.com/fragpit/env-cleaner/internal/api"
 	"github.com/fragpit/env-cleaner/internal/config"
 	"github.com/fragpit/env-cleaner/internal/connectors/helm"
 	"github.com/fragpit/env-cleaner/internal/connectors/vsphere"
+	"github.com/fragpit/env-cleaner/internal/metrics"
 	"github.com/fragpit/env-cleaner/internal/model"
 	"github.com/fragpit/env-cleaner/internal/notifications"
+	"github.com/fragpit/env-cleaner/internal/oidc"
 	"github.com/fragpit/env-cleaner/internal/service"
-	"github.com/fragpit/env-cleaner/internal/storage/postgresql"
-	"github.com/fragpit/env-cleaner/internal/storage/sqlite"
+	"github.com/fragpit/env-cleaner/internal/storage"
 )
 
 func Run() error {
@@ -28,6 +33,10 @@ func Run() error {
 		return err
 	}
 
+	if err := validateConfig(cfg); err != nil {
+		return err
+	}
+
 	ctx, cancel := signal.NotifyContext(
 		context.Background(),
 		syscall.SIGTERM,
@@ -35,47 +44,145 @@ func Run() error {
 	)
 	defer cancel()
 
-	var st model.Repository
-	if cfg.SQLite.DatabaseFolder != "" {
-		st, err = sqlite.New(cfg.SQLite.DatabaseFolder)
-		if err == nil {
-			slog.Info("successfully connected to SQLite database",
-				slog.String("folder", cfg.SQLite.DatabaseFolder),
-			)
+	st, err := storage.New(cfg)
+	if err != nil {
+		slog.Error("error creating storage", slog.Any("error", err))
+		return err
+	}
+
+	defer func() {
+		if err := st.Close(); err != nil {
+			slog.Error("error closing storage", slog.Any("error", err))
 		}
-	} else if cfg.Postgresql.Host != "" {
-		st, err = postgresql.New(
-			cfg.Postgresql.Host,
-			cfg.Postgresql.Port,
-			cfg.Postgresql.Username,
-			cfg.Postgresql.Password,
-			cfg.Postgresql.Database,
-		)
-		if err == nil {
-			slog.Info("successfully connected to PostgreSQL database",
-				slog.String("host", cfg.Postgresql.Host),
-				slog.Int("port", cfg.Postgresql.Port),
-				slog.String("database", cfg.Postgresql.Database),
+	}()
+
+	nt := newNotificator(cfg)
+
+	if cfg.DryRun {
+		slog.Warn("dry run mode is enabled")
+	}
+
+	enabledConnectors, err := newConnectors(ctx, cfg, nt, nil)
+	if err != nil {
+		return err
+	}
+
+	var crawlers []*service.Crawler
+	for _, conn := range enabledConnectors {
+		cr := service.NewCrawler(crawlerConfig(cfg), conn, st, nt)
+		crawlers = append(crawlers, cr)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cr.Run(ctx); err != nil {
+				slog.Error(
+					"shutdown env-cleaner, error running crawler",
+					slog.Any("error", err),
+				)
+				cancel()
+			}
+		}()
+	}
+
+	factory := &service.ConnectorList{Connectors: enabledConnectors}
+	deleter := service.NewDeleter(deleterConfig(cfg), factory, st, nt)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := deleter.Run(ctx); err != nil {
+			slog.Error(
+				"shutdown env-cleaner, error running deleter",
+				slog.Any("error", err),
 			)
+			cancel()
 		}
-	}
+	}()
+
+	envCollector, err := metrics.NewEnvironmentCollector(st, cfg.StaleThreshold)
 	if err != nil {
-		slog.Error("error creating storage", slog.Any("error", err))
+		slog.Error("error creating environment metrics", slog.Any("error", err))
 		return err
 	}
+	prometheus.MustRegister(envCollector)
 
-	if st == nil {
-		slog.Error("check storage configuration settings")
+	healthSvc, err := service.NewHealthService(
+		service.HealthConfig{
+			CrawlInterval:  cfg.CrawlInterval,
+			DeleteInterval: cfg.DeleteInterval,
+			MaxCrawlAge:    cfg.Health.MaxCrawlAge,
+			MaxDeleteAge:   cfg.Health.MaxDeleteAge,
+		},
+		st, enabledConnectors, crawlers, deleter,
+	)
+	if err != nil {
+		slog.Error("error creating health service", slog.Any("error", err))
 		return err
 	}
 
-	defer func() {
-		if err := st.Close(); err != nil {
-			slog.Error("error closing storage", slog.Any("error", err))
+	svc := service.NewEnvironmentService(
+		st, factory, deleter, cfg.MaxExtendDuration,
+	)
+
+	var webhookSvc api.WebhookService
+	if cfg.Webhooks.Enabled {
+		webhookSvc, err = service.NewWebhookService(
+			service.WebhookConfig{
+				NameTemplate:      cfg.Webhooks.Match.Name,
+				NamespaceTemplate: cfg.Webhooks.Match.Namespace,
+				TypeTemplate:      cfg.Webhooks.Match.Type,
+				LabelsTemplate:    cfg.Webhooks.Match.Labels,
+			},
+			svc, st,
+		)
+		if err != nil {
+			slog.Error("error creating webhook service", slog.Any("error", err))
+			return err
+		}
+	}
+
+	var verifier api.OIDCVerifier
+	if cfg.OIDC.Enabled {
+		verifier, err = oidc.New(oidc.Config{
+			IssuerURL:     cfg.OIDC.IssuerURL,
+			Issuer:        cfg.OIDC.Issuer,
+			JWKSFile:      cfg.OIDC.JWKSFile,
+			Audience:      cfg.OIDC.Audience,
+			IdentityClaim: cfg.OIDC.IdentityClaim,
+			GroupsClaim:   cfg.OIDC.GroupsClaim,
+			ClientID:      cfg.OIDC.ClientID,
+			ClientSecret:  cfg.OIDC.ClientSecret,
+			Scopes:        cfg.OIDC.Scopes,
+		})
+		if err != nil {
+			slog.Error("error creating oidc verifier", slog.Any("error", err))
+			return err
+		}
+	}
+
+	a := api.New(
+		cfg, svc, service.NewAuditService(st), service.NewAPIKeyService(st),
+		healthSvc, webhookSvc, verifier,
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := a.Run(ctx); err != nil {
+			slog.Error(
+				"shutdown env-cleaner, error running API",
+				slog.Any("error", err),
+			)
+			cancel()
 		}
 	}()
 
-	nt := notifications.New(
+	wg.Wait()
+
+	slog.Info("env-cleaner shut down gracefully")
+	return nil
+}
+
+func newNotificator(cfg *config.ServerConfig) *notifications.Notificator {
+	return notifications.New(
 		cfg.Notifications.AdminOnly,
 		cfg.APIURL,
 		cfg.StaleThreshold,
@@ -86,30 +193,54 @@ func Run() error {
 			AdminChannel: cfg.Notifications.Slack.AdminChannel,
 		},
 		&notifications.EmailConfig{
-			Enabled:           cfg.Notifications.Email.Enabled,
-			SMTPServerAddress: cfg.Notifications.Email.SMTPServerAddress,
-			SMTPServerPort:    cfg.Notifications.Email.SMTPServerPort,
-			Username:          cfg.Notifications.Email.Username,
-			Password:          cfg.Notifications.Email.Password,
-			SenderEmail:       cfg.Notifications.Email.SenderEmail,
-			AdminEmail:        cfg.Notifications.Email.AdminEmail,
+			Enabled:            cfg.Notifications.Email.Enabled,
+			SMTPServerAddress:  cfg.Notifications.Email.SMTPServerAddress,
+			SMTPServerPort:     cfg.Notifications.Email.SMTPServerPort,
+			TLS:                cfg.Notifications.Email.TLS,
+			InsecureSkipVerify: cfg.Notifications.Email.InsecureSkipVerify,
+			Username:           cfg.Notifications.Email.Username,
+			Password:           cfg.Notifications.Email.Password,
+			SenderEmail:        cfg.Notifications.Email.SenderEmail,
+			AdminEmail:         cfg.Notifications.Email.AdminEmail,
+			OwnerDomain:        cfg.Notifications.Email.OwnerDomain,
 		},
 	)
+}
 
-	if cfg.DryRun {
-		slog.Warn("dry run mode is enabled")
-	}
-
-	enabledConnectors := make(map[string]model.Connector)
-
+// newConnectors creates the enabled connectors keyed by their type. If
+// only is not empty, just the listed types are created and each of them
+// must be enabled.
+func newConnectors(
+	ctx context.Context,
+	cfg *config.ServerConfig,
+	nt model.Notificator,
+	only []string,
+) (map[string]model.Connector, error) {
 	if !cfg.Environments.VSphereVM.Enabled && !cfg.Environments.Helm.Enabled {
 		slog.Error(
 			"check environments configuration settings: no connectors enabled",
 		)
-		return err
+		return nil, errors.New("no connectors enabled")
+	}
+
+	enabled := map[string]bool{
+		"vsphere_vm": cfg.Environments.VSphereVM.Enabled,
+		"helm":       cfg.Environments.Helm.Enabled,
+	}
+	want := make(map[string]bool, len(only))
+	for _, t := range only {
+		if !enabled[t] {
+			return nil, fmt.Errorf("connector %s is not enabled", t)
+		}
+		want[t] = true
+	}
+	use := func(t string) bool {
+		return enabled[t] && (len(want) == 0 || want[t])
 	}
 
-	if cfg.Environments.VSphereVM.Enabled {
+	connectors := make(map[string]model.Connector)
+
+	if use("vsphere_vm") {
 		vsConfig := vsphere.Config{
 			EnvCfg:  cfg.Environments.VSphereVM,
 			ConnCfg: cfg.Connectors.VSphere,
@@ -118,20 +249,13 @@ func Run() error {
 		vsConn, err := vsphere.New(ctx, &vsConfig, nt)
 		if err != nil {
 			slog.Error("error creating vSphere connector", slog.Any("error", err))
-			return err
+			return nil, err
 		}
 
-		vsCr := service.NewCrawler(cfg.CrawlInterval, vsConn, st)
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			vsCr.Run(ctx)
-		}()
-
-		enabledConnectors["vsphere_vm"] = vsConn
+		connectors["vsphere_vm"] = vsConn
 	}
 
-	if cfg.Environments.Helm.Enabled {
+	if use("helm") {
 		helmConfig := helm.Config{
 			EnvCfg:  cfg.Environments.Helm,
 			ConnCfg: cfg.Connectors.K8s,
@@ -140,52 +264,48 @@ func Run() error {
 		helmConn, err := helm.New(&helmConfig, nt)
 		if err != nil {
 			slog.Error("error creating Helm connector", slog.Any("error", err))
-			return err
+			return nil, err
 		}
 
-		helmCr := service.NewCrawler(cfg.CrawlInterval, helmConn, st)
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			helmCr.Run(ctx)
-		}()
+		connectors["helm"] = helmConn
+	}
+
+	return connectors, nil
+}
 
-		enabledConnectors["helm"] = helmConn
+func crawlerConfig(cfg *config.ServerConfig) service.CrawlerConfig {
+	return service.CrawlerConfig{
+		CrawlInterval:   cfg.CrawlInterval,
+		GoneGracePeriod: cfg.Reconcile.GracePeriod,
+		NotifyGone:      cfg.Reconcile.NotifyOwner,
 	}
+}
 
-	factory := &service.ConnectorList{Connectors: enabledConnectors}
-	deleter := service.NewDeleter(
-		service.DeleterConfig{
-			DeleteInterval: cfg.DeleteInterval,
-			StaleThreshold: cfg.StaleThreshold,
-			DryRun:         cfg.DryRun,
-		},
-		factory,
-		st,
-		nt,
-	)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		deleter.Run(ctx)
-	}()
+func deleterConfig(cfg *config.ServerConfig) service.DeleterConfig {
+	return service.DeleterConfig{
+		DeleteInterval:   cfg.DeleteInterval,
+		StaleThreshold:   cfg.StaleThreshold,
+		DeletedRetention: cfg.DeletedRetention,
+		RetryBackoff:     cfg.DeleteRetry.Backoff,
+		RetryMaxBackoff:  cfg.DeleteRetry.MaxBackoff,
+		MaxFailures:      cfg.DeleteRetry.MaxFailures,
+		DryRun:           cfg.DryRun,
+	}
+}
 
-	svc := service.NewEnvironmentService(st, factory, cfg.MaxExtendDuration)
-	a := api.New(cfg, svc)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := a.Run(ctx); err != nil {
-			slog.Error(
-				"shutdown env-cleaner, error running API",
-				slog.Any("error", err),
+// validateConfig logs every problem of cfg and returns an error if there
+// are any.
+func validateConfig(cfg *config.ServerConfig) error {
+	err := cfg.Validate()
+	var ve *config.ValidationError
+	if errors.As(err, &ve) {
+		for _, p := range ve.Problems {
+			slog.Error("invalid configuration",
+				slog.String("key", p.Key),
+				slog.String("error", p.Msg),
 			)
-			cancel()
 		}
-	}()
-
-	wg.Wait()
+	}
 
-	slog.Info("env-cleaner shut down gracefully")
-	return nil
+	return err
 }
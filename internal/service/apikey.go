@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/pkg/utils"
+)
+
+const (
+	apiKeyPrefix = "ec_"
+	apiKeyLength = 32
+)
+
+var apiKeyNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// ErrInvalidAPIKey is returned for unknown, expired and revoked keys.
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+type APIKeyService struct {
+	repo model.APIKeyRepository
+}
+
+func NewAPIKeyService(repo model.APIKeyRepository) *APIKeyService {
+	return &APIKeyService{repo: repo}
+}
+
+// CreateAPIKey creates a key named name with scopes, expiring after ttl or
+// never if ttl is empty. The key itself is only returned here, the
+// repository keeps its hash.
+func (s *APIKeyService) CreateAPIKey(
+	ctx context.Context,
+	name string,
+	scopes []string,
+	ttl string,
+) (*model.APIKey, string, error) {
+	if !apiKeyNameRe.MatchString(name) {
+		return nil, "", &model.ValidationError{
+			Msg: fmt.Sprintf("invalid api key name: %s", name),
+		}
+	}
+
+	if len(scopes) == 0 {
+		return nil, "", &model.ValidationError{Msg: "no scopes given"}
+	}
+	for _, sc := range scopes {
+		if !model.ValidScope(sc) {
+			return nil, "", &model.ValidationError{
+				Msg: fmt.Sprintf("unknown scope: %s", sc),
+			}
+		}
+	}
+
+	now := time.Now()
+	key := &model.APIKey{
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: now.Unix(),
+	}
+
+	if ttl != "" {
+		dur, err := str2duration.ParseDuration(ttl)
+		if err != nil || dur <= 0 {
+			return nil, "", &model.ValidationError{
+				Msg: fmt.Sprintf("invalid ttl: %s", ttl),
+			}
+		}
+		key.ExpiresAt = now.Add(dur).Unix()
+	}
+
+	token, err := utils.GenerateToken(apiKeyLength)
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating api key: %w", err)
+	}
+	token = apiKeyPrefix + token
+	key.KeyHash = hashAPIKey(token)
+
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	return key, token, nil
+}
+
+func (s *APIKeyService) GetAPIKeys(ctx context.Context) ([]*model.APIKey, error) {
+	return s.repo.GetAPIKeys(ctx)
+}
+
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, name string) error {
+	return s.repo.RevokeAPIKey(ctx, name, time.Now().Unix())
+}
+
+// Authenticate returns the active key matching token.
+func (s *APIKeyService) Authenticate(
+	ctx context.Context,
+	token string,
+) (*model.APIKey, error) {
+	key, err := s.repo.GetAPIKeyByHash(ctx, hashAPIKey(token))
+	if err != nil {
+		var nf *model.NotFoundError
+		if errors.As(err, &nf) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	if !key.Active(time.Now().Unix()) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	return key, nil
+}
+
+// hashAPIKey returns the hex SHA-256 of an API key. Keys are random, so an
+// unsalted fast hash is enough.
+func hashAPIKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
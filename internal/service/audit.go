@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type AuditService struct {
+	repo model.AuditRepository
+}
+
+func NewAuditService(repo model.AuditRepository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+func (s *AuditService) GetAuditEntries(
+	ctx context.Context,
+	filter *model.AuditFilter,
+) ([]*model.AuditEntry, error) {
+	if filter == nil {
+		filter = &model.AuditFilter{}
+	}
+
+	if owner := actorFromContext(ctx).Owner; owner != "" {
+		if filter.Owner != "" && filter.Owner != owner {
+			return nil, &model.ForbiddenError{
+				Msg: "environments of other owners are not accessible",
+			}
+		}
+		filter.Owner = owner
+	}
+
+	switch {
+	case filter.Limit < 0 || filter.Limit > maxAuditLimit:
+		return nil, &model.ValidationError{
+			Msg: "limit must be between 1 and 1000",
+		}
+	case filter.Limit == 0:
+		filter.Limit = defaultAuditLimit
+	}
+
+	if filter.Since != 0 && filter.Until != 0 && filter.Since > filter.Until {
+		return nil, &model.ValidationError{Msg: "since is after until"}
+	}
+
+	return s.repo.GetAuditEntries(ctx, filter)
+}
+
+// recordAudit writes an audit entry. Failing to write it is logged and
+// does not fail the audited action.
+func recordAudit(
+	ctx context.Context,
+	repo model.AuditRepository,
+	entry *model.AuditEntry,
+) {
+	if err := repo.WriteAuditEntry(ctx, entry); err != nil {
+		slog.Error("error writing audit entry",
+			slog.String("env_id", entry.EnvID),
+			slog.String("action", entry.Action),
+			slog.Any("error", err),
+		)
+	}
+}
+
+// actorFromContext returns the API actor of ctx. Requests that reached the
+// service without one are attributed to the API.
+func actorFromContext(ctx context.Context) model.Actor {
+	actor, ok := model.ActorFromContext(ctx)
+	if !ok {
+		return model.Actor{Source: model.AuditSourceAPI}
+	}
+
+	return actor
+}
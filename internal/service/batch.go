@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/pkg/utils"
+)
+
+// maxBatchSize is the number of environments a batch operation may apply
+// to.
+const maxBatchSize = 1000
+
+// BatchEnvironments applies a batch operation to each environment it
+// selects and returns the per environment results. A failure on one
+// environment does not stop the others. The operation is rejected as a
+// whole if it is invalid or the actor lacks the scope of the action.
+func (s *EnvironmentService) BatchEnvironments(
+	ctx context.Context,
+	op *model.BatchOperation,
+) ([]*model.BatchResult, error) {
+	if err := validateBatch(ctx, op); err != nil {
+		return nil, err
+	}
+
+	var results []*model.BatchResult
+	var err error
+	if len(op.IDs) > 0 {
+		results, err = s.batchByIDs(ctx, op.IDs)
+	} else {
+		results, err = s.batchBySelector(ctx, op.Selector)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) > maxBatchSize {
+		return nil, &model.ValidationError{Msg: fmt.Sprintf(
+			"batch matches %d environments, more than %d",
+			len(results), maxBatchSize,
+		)}
+	}
+
+	if op.DryRun {
+		return results, nil
+	}
+
+	for _, res := range results {
+		if res.Result == model.BatchResultFailed {
+			continue
+		}
+
+		env, err := s.applyBatchAction(ctx, op, res.EnvID)
+		if err != nil {
+			slog.Warn("error applying batch action",
+				slog.String("action", op.Action),
+				slog.String("env_id", res.EnvID),
+				slog.Any("error", err),
+			)
+			res.Result = model.BatchResultFailed
+			res.Error = batchError(err)
+			continue
+		}
+		res.Env = env
+		res.Result = model.BatchResultOK
+	}
+
+	return results, nil
+}
+
+func validateBatch(ctx context.Context, op *model.BatchOperation) error {
+	if !model.ValidBatchAction(op.Action) {
+		return &model.ValidationError{Msg: fmt.Sprintf(
+			"unknown action: %s, must be one of %s",
+			op.Action, strings.Join(model.BatchActions, ", "),
+		)}
+	}
+
+	// Without an actor no scope is granted, like in requireScope.
+	actor, ok := model.ActorFromContext(ctx)
+	if !ok || !actor.HasScope(model.BatchActionScopes[op.Action]) {
+		return &model.ForbiddenError{Msg: fmt.Sprintf(
+			"%s requires the %s scope",
+			op.Action, model.BatchActionScopes[op.Action],
+		)}
+	}
+
+	switch {
+	case len(op.IDs) > 0 && !op.Selector.IsEmpty():
+		return &model.ValidationError{
+			Msg: "ids and selector are mutually exclusive",
+		}
+	case len(op.IDs) == 0 && op.Selector.IsEmpty():
+		return &model.ValidationError{
+			Msg: "either ids or a selector is required",
+		}
+	case len(op.IDs) > maxBatchSize:
+		return &model.ValidationError{
+			Msg: fmt.Sprintf("at most %d ids are allowed", maxBatchSize),
+		}
+	}
+
+	if op.Selector.NameRegex != "" {
+		if _, err := regexp.Compile(op.Selector.NameRegex); err != nil {
+			return &model.ValidationError{
+				Msg: fmt.Sprintf("invalid name_regex: %v", err),
+			}
+		}
+	}
+
+	if _, err := model.ParseLabelSelector(op.Selector.Label); err != nil {
+		return &model.ValidationError{
+			Msg: fmt.Sprintf("invalid label: %v", err),
+		}
+	}
+
+	// Arguments are checked up front so that dry runs report them too.
+	switch op.Action {
+	case model.BatchActionExtend:
+		if op.Period == "" {
+			return &model.ValidationError{Msg: "extend requires period"}
+		}
+	case model.BatchActionSetExpiry:
+		if op.DeleteAt == "" {
+			return &model.ValidationError{Msg: "set-expiry requires delete_at"}
+		}
+		if _, _, err := utils.ParseDeleteAt(op.DeleteAt); err != nil {
+			return &model.ValidationError{
+				Msg: fmt.Sprintf("invalid delete_at: %v", err),
+			}
+		}
+	case model.BatchActionChangeOwner:
+		if op.Owner == "" {
+			return &model.ValidationError{Msg: "change-owner requires owner"}
+		}
+	}
+
+	if op.Period != "" {
+		dur, err := str2duration.ParseDuration(op.Period)
+		if err != nil || dur <= 0 {
+			return &model.ValidationError{
+				Msg: fmt.Sprintf("invalid period: %s", op.Period),
+			}
+		}
+	}
+
+	if op.IgnoreMax && op.Action != model.BatchActionExtend &&
+		op.Action != model.BatchActionSetExpiry {
+		return &model.ValidationError{
+			Msg: "ignore_max only applies to extend and set-expiry",
+		}
+	}
+
+	if op.IgnoreMax && !actor.HasScope(model.ScopeAdmin) {
+		return &model.ForbiddenError{
+			Msg: "ignore_max requires the admin scope",
+		}
+	}
+
+	return nil
+}
+
+// batchByIDs resolves the listed environments. Unknown ones fail without
+// failing the batch.
+func (s *EnvironmentService) batchByIDs(
+	ctx context.Context,
+	ids []string,
+) ([]*model.BatchResult, error) {
+	seen := make(map[string]bool, len(ids))
+	results := make([]*model.BatchResult, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		res := &model.BatchResult{EnvID: id, Result: model.BatchResultMatched}
+		env, err := s.GetEnvironment(ctx, id)
+		if err != nil {
+			var nf *model.NotFoundError
+			if !errors.As(err, &nf) {
+				return nil, err
+			}
+			res.Result = model.BatchResultFailed
+			res.Error = batchError(err)
+		}
+		res.Env = env
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// batchBySelector resolves the environments matching sel. Actors
+// restricted to an owner only select their own environments.
+func (s *EnvironmentService) batchBySelector(
+	ctx context.Context,
+	sel model.BatchSelector,
+) ([]*model.BatchResult, error) {
+	labels, err := model.ParseLabelSelector(sel.Label)
+	if err != nil {
+		return nil, &model.ValidationError{
+			Msg: fmt.Sprintf("invalid label: %v", err),
+		}
+	}
+
+	filter := &model.EnvironmentFilter{
+		Owner:     sel.Owner,
+		Type:      sel.Type,
+		Namespace: sel.Namespace,
+		Labels:    labels,
+	}
+
+	if owner := actorFromContext(ctx).Owner; owner != "" {
+		if filter.Owner != "" && filter.Owner != owner {
+			return nil, &model.ForbiddenError{
+				Msg: "environments of other owners are not accessible",
+			}
+		}
+		filter.Owner = owner
+	}
+
+	envs, err := s.repo.GetEnvironments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var nameRe *regexp.Regexp
+	if sel.NameRegex != "" {
+		nameRe = regexp.MustCompile(sel.NameRegex)
+	}
+
+	var results []*model.BatchResult
+	for _, env := range envs {
+		if nameRe != nil && !nameRe.MatchString(env.Name) {
+			continue
+		}
+		results = append(results, &model.BatchResult{
+			EnvID:  env.EnvID,
+			Env:    env,
+			Result: model.BatchResultMatched,
+		})
+	}
+
+	return results, nil
+}
+
+func (s *EnvironmentService) applyBatchAction(
+	ctx context.Context,
+	op *model.BatchOperation,
+	envID string,
+) (*model.Environment, error) {
+	switch op.Action {
+	case model.BatchActionExtend:
+		return s.AdminExtendEnvironment(ctx, envID, op.Period, "", op.IgnoreMax)
+	case model.BatchActionSetExpiry:
+		return s.AdminExtendEnvironment(
+			ctx, envID, "", op.DeleteAt, op.IgnoreMax,
+		)
+	case model.BatchActionDeleteNow:
+		return s.DeleteEnvironment(ctx, envID, false)
+	case model.BatchActionProtect:
+		return s.ProtectEnvironment(ctx, envID, op.Period, op.Reason)
+	case model.BatchActionChangeOwner:
+		return s.UpdateEnvironment(ctx, envID, op.Owner, "", "")
+	}
+
+	return nil, fmt.Errorf("unknown batch action: %s", op.Action)
+}
+
+// batchError describes err for a batch result without exposing internal
+// errors.
+func batchError(err error) string {
+	var (
+		ve *model.ValidationError
+		nf *model.NotFoundError
+		ce *model.ConflictError
+		fe *model.ForbiddenError
+	)
+	if errors.As(err, &ve) || errors.As(err, &nf) ||
+		errors.As(err, &ce) || errors.As(err, &fe) {
+		return err.Error()
+	}
+
+	return "internal error"
+}
@@ -2,52 +2,79 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
-	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/fragpit/env-cleaner/internal/metrics"
 	"github.com/fragpit/env-cleaner/internal/model"
 )
 
 const (
 	crawlerOperationTimeout = 120 * time.Second
+	defaultGoneGracePeriod  = 24 * time.Hour
 )
 
-type Crawler struct {
+type CrawlerConfig struct {
 	CrawlInterval string
-	Connector     model.Connector
-	Repository    model.Repository
+	// GoneGracePeriod is how long an environment that disappeared from its
+	// connector is kept before it is removed from the database.
+	GoneGracePeriod string
+	NotifyGone      bool
+}
+
+type Crawler struct {
+	config      CrawlerConfig
+	Connector   model.Connector
+	Repository  model.Repository
+	Notificator model.Notificator
+	// lastSuccess is the unix time of the last successful run.
+	lastSuccess atomic.Int64
 }
 
 func NewCrawler(
-	crawlInt string,
+	cfg CrawlerConfig,
 	conn model.Connector,
 	repo model.Repository,
+	nt model.Notificator,
 ) *Crawler {
 	return &Crawler{
-		CrawlInterval: crawlInt,
-		Connector:     conn,
-		Repository:    repo,
+		config:      cfg,
+		Connector:   conn,
+		Repository:  repo,
+		Notificator: nt,
 	}
 }
 
-func (c *Crawler) Run(ctx context.Context) {
+// LastSuccess returns the time of the last successful run, or the zero
+// time if none succeeded yet.
+func (c *Crawler) LastSuccess() time.Time {
+	return unixTime(c.lastSuccess.Load())
+}
+
+// Run crawls periodically until ctx is done. It returns an error if the
+// crawl interval is invalid.
+func (c *Crawler) Run(ctx context.Context) error {
 	slog.Info("crawler service started",
 		slog.String("type", c.Connector.GetConnectorType()),
-		slog.String("interval", c.CrawlInterval),
+		slog.String("interval", c.config.CrawlInterval),
 	)
-	runPeriodically(ctx, startCrawler, c)
+	return runPeriodically(ctx, startCrawler, c)
 }
 
 func runPeriodically(
 	ctx context.Context,
 	f func(context.Context, *Crawler),
 	c *Crawler,
-) {
-	interval, err := time.ParseDuration(c.CrawlInterval)
+) error {
+	interval, err := time.ParseDuration(c.config.CrawlInterval)
 	if err != nil {
-		slog.Error("error parsing duration", slog.Any("error", err))
-		os.Exit(1)
+		return fmt.Errorf("error parsing crawl interval: %w", err)
 	}
 
 	f(ctx, c)
@@ -66,7 +93,7 @@ func runPeriodically(
 			slog.Info("crawler service shut down",
 				slog.String("type", c.Connector.GetConnectorType()),
 			)
-			return
+			return nil
 		}
 	}
 }
@@ -76,6 +103,39 @@ func startCrawler(ctx context.Context, c *Crawler) {
 		slog.String("type", c.Connector.GetConnectorType()),
 	)
 
+	start := time.Now()
+	_, err := c.RunOnce(ctx)
+	metrics.ObserveCrawlerRun(c.Connector.GetConnectorType(), start, err == nil)
+	if err != nil {
+		slog.Error("error running crawler",
+			slog.String("type", c.Connector.GetConnectorType()),
+			slog.Any("error", err),
+		)
+		return
+	}
+
+	c.lastSuccess.Store(time.Now().Unix())
+	slog.Info("crawler task finished",
+		slog.String("type", c.Connector.GetConnectorType()),
+	)
+}
+
+// CrawlSummary counts what a single crawler run found and changed.
+type CrawlSummary struct {
+	Type       string
+	Found      int
+	Discovered int
+	Updated    int
+	Gone       int
+	Reappeared int
+	Removed    int
+}
+
+// RunOnce runs a single crawl of the connector and returns its summary.
+// The summary covers the changes made before an error stopped the run.
+func (c *Crawler) RunOnce(ctx context.Context) (*CrawlSummary, error) {
+	sum := &CrawlSummary{Type: c.Connector.GetConnectorType()}
+
 	ctx, cancel := context.WithTimeout(
 		ctx, crawlerOperationTimeout,
 	)
@@ -83,8 +143,22 @@ func startCrawler(ctx context.Context, c *Crawler) {
 
 	envs, err := c.Connector.GetEnvironments(ctx)
 	if err != nil {
-		slog.Error("error finding VMs", slog.Any("error", err))
-		return
+		return sum, fmt.Errorf("error finding environments: %w", err)
+	}
+	sum.Found = len(envs)
+
+	stored, err := c.Repository.GetEnvironmentsByType(
+		ctx, c.Connector.GetConnectorType(),
+	)
+	if err != nil {
+		return sum, fmt.Errorf("error reading from DB: %w", err)
+	}
+
+	for i := range envs {
+		envs[i].DeleteAtSource = model.DeleteAtSourceCrawler
+		if envs[i].Protected {
+			envs[i].ProtectedBy = model.ProtectedByMetadata
+		}
 	}
 
 	if envs != nil {
@@ -92,12 +166,335 @@ func startCrawler(ctx context.Context, c *Crawler) {
 		if err := c.Repository.WriteEnvironments(
 			ctx, envs,
 		); err != nil {
-			slog.Error("error writing to DB", slog.Any("error", err))
-			return
+			return sum, fmt.Errorf("error writing to DB: %w", err)
 		}
+		c.auditDiscovered(ctx, envs, stored, sum)
 	}
 
-	slog.Info("crawler task finished",
-		slog.String("type", c.Connector.GetConnectorType()),
+	if err := c.updateEnvironments(ctx, envs, stored, sum); err != nil {
+		return sum, fmt.Errorf("error updating environments: %w", err)
+	}
+
+	if err := c.reconcile(ctx, envs, stored, sum); err != nil {
+		return sum, fmt.Errorf("error reconciling environments: %w", err)
+	}
+
+	return sum, nil
+}
+
+// auditDiscovered records the found environments that were not tracked
+// before the crawl.
+func (c *Crawler) auditDiscovered(
+	ctx context.Context,
+	found []model.Environment,
+	stored []*model.Environment,
+	sum *CrawlSummary,
+) {
+	known := make(map[string]struct{}, len(stored))
+	for _, env := range stored {
+		if env.Status != model.StatusDeleted {
+			known[env.EnvID] = struct{}{}
+		}
+	}
+
+	for i := range found {
+		env := &found[i]
+		if _, ok := known[env.EnvID]; ok {
+			continue
+		}
+
+		entry := model.NewAuditEntry(
+			env, model.AuditActionDiscover,
+			model.ActorCrawler, model.AuditSourceCrawler,
+		)
+		entry.Details = "ttl " + env.TTL
+		recordAudit(ctx, c.Repository, entry)
+		sum.Discovered++
+	}
+}
+
+// updateEnvironments applies owner, TTL and label changes from the
+// connector metadata to already stored environments. Labels are only
+// replaced if the metadata sets them. A changed TTL resets delete_at,
+// unless the environment was extended through the API past the new date.
+// Records with an unknown TTL only have it recorded, so that upgrading
+// env-cleaner does not reset every lifetime.
+func (c *Crawler) updateEnvironments(
+	ctx context.Context,
+	found []model.Environment,
+	stored []*model.Environment,
+	sum *CrawlSummary,
+) error {
+	known := make(map[string]*model.Environment, len(stored))
+	for _, env := range stored {
+		known[env.EnvID] = env
+	}
+
+	for i := range found {
+		cur := &found[i]
+		env, ok := known[cur.EnvID]
+		if !ok || env.Status == model.StatusDeleted {
+			continue
+		}
+
+		protectionChanged, err := c.syncProtection(ctx, env, cur)
+		if err != nil {
+			return err
+		}
+
+		oldDeleteAt := env.DeleteAt
+		var details []string
+		changed := false
+		if cur.Owner != env.Owner {
+			slog.Info("environment owner changed",
+				slog.String("name", env.DisplayName()),
+				slog.String("id", env.EnvID),
+				slog.String("old_owner", env.Owner),
+				slog.String("new_owner", cur.Owner),
+			)
+			details = append(details, fmt.Sprintf(
+				"owner %s -> %s", env.Owner, cur.Owner,
+			))
+			env.Owner = cur.Owner
+			changed = true
+		}
+
+		if cur.TTL != env.TTL {
+			switch {
+			case env.TTL == "":
+			case env.DeleteAtSource == model.DeleteAtSourceExtend &&
+				env.DeleteAtSec > cur.DeleteAtSec:
+				slog.Info("environment ttl changed, keeping extended delete_at",
+					slog.String("name", env.DisplayName()),
+					slog.String("id", env.EnvID),
+					slog.String("ttl", cur.TTL),
+					slog.String("delete_at", env.DeleteAt),
+				)
+			default:
+				slog.Info("environment ttl changed, resetting delete_at",
+					slog.String("name", env.DisplayName()),
+					slog.String("id", env.EnvID),
+					slog.String("old_ttl", env.TTL),
+					slog.String("new_ttl", cur.TTL),
+					slog.String("delete_at", cur.DeleteAt),
+				)
+				env.DeleteAt = cur.DeleteAt
+				env.DeleteAtSec = cur.DeleteAtSec
+				env.DeleteAtSource = model.DeleteAtSourceCrawler
+			}
+			details = append(details, fmt.Sprintf(
+				"ttl %s -> %s", env.TTL, cur.TTL,
+			))
+			env.TTL = cur.TTL
+			changed = true
+		}
+
+		if cur.Labels != nil {
+			oldLabels := model.FormatLabels(env.Labels)
+			newLabels := model.FormatLabels(cur.Labels)
+			if newLabels != oldLabels {
+				details = append(details, fmt.Sprintf(
+					"labels %s -> %s", oldLabels, newLabels,
+				))
+				env.Labels = cur.Labels
+				changed = true
+			}
+		}
+
+		if !changed {
+			if protectionChanged {
+				sum.Updated++
+			}
+			continue
+		}
+
+		if err := c.Repository.UpdateEnvironment(ctx, env); err != nil {
+			return err
+		}
+		sum.Updated++
+
+		entry := model.NewAuditEntry(
+			env, model.AuditActionUpdate,
+			model.ActorCrawler, model.AuditSourceCrawler,
+		)
+		entry.OldDeleteAt = oldDeleteAt
+		entry.Details = strings.Join(details, ", ")
+		recordAudit(ctx, c.Repository, entry)
+	}
+
+	return nil
+}
+
+// syncProtection applies protection from the connector metadata of cur to
+// the stored env. Metadata protection replaces protection set through the
+// API. Removing it from the metadata only lifts protection that came from
+// the metadata. It reports whether the protection changed.
+func (c *Crawler) syncProtection(
+	ctx context.Context,
+	env *model.Environment,
+	cur *model.Environment,
+) (bool, error) {
+	var action string
+	switch {
+	case cur.Protected:
+		if env.Protected &&
+			env.ProtectedBy == model.ProtectedByMetadata &&
+			env.ProtectedReason == cur.ProtectedReason {
+			return false, nil
+		}
+		env.Protected = true
+		env.ProtectedUntil = 0
+		env.ProtectedReason = cur.ProtectedReason
+		env.ProtectedBy = model.ProtectedByMetadata
+		action = model.AuditActionProtect
+	case env.Protected && env.ProtectedBy == model.ProtectedByMetadata:
+		env.Protected = false
+		env.ProtectedUntil = 0
+		env.ProtectedReason = ""
+		env.ProtectedBy = ""
+		action = model.AuditActionUnprotect
+	default:
+		return false, nil
+	}
+
+	slog.Info("environment protection changed by metadata",
+		slog.String("name", env.DisplayName()),
+		slog.String("id", env.EnvID),
+		slog.Bool("protected", env.Protected),
+	)
+	if err := c.Repository.SetProtection(ctx, env); err != nil {
+		return false, err
+	}
+
+	entry := model.NewAuditEntry(
+		env, action, model.ActorCrawler, model.AuditSourceCrawler,
 	)
+	entry.Details = env.ProtectedReason
+	recordAudit(ctx, c.Repository, entry)
+
+	return true, nil
+}
+
+// reconcile finds stored environments of the crawler's connector type that
+// the connector no longer reports. Environments not reported by the
+// connector are confirmed with CheckEnvironment, since releases or VMs
+// without metadata are skipped by the connector but may still exist.
+// Only environments the check reports as not found are marked gone, and
+// removed from the database once the grace period passes. Any other check
+// error stops the pass before anything is changed.
+func (c *Crawler) reconcile(
+	ctx context.Context,
+	found []model.Environment,
+	stored []*model.Environment,
+	sum *CrawlSummary,
+) error {
+	gracePeriod := defaultGoneGracePeriod
+	if c.config.GoneGracePeriod != "" {
+		var err error
+		gracePeriod, err = str2duration.ParseDuration(c.config.GoneGracePeriod)
+		if err != nil {
+			return err
+		}
+	}
+
+	reported := make(map[string]struct{}, len(found))
+	for i := range found {
+		reported[found[i].EnvID] = struct{}{}
+	}
+
+	missing := make(map[string]struct{})
+	for _, env := range stored {
+		if skipReconcile(env) {
+			continue
+		}
+
+		if _, ok := reported[env.EnvID]; ok {
+			continue
+		}
+
+		err := c.Connector.CheckEnvironment(ctx, env)
+		switch {
+		case err == nil:
+		case errors.Is(err, model.ErrEnvironmentNotFound):
+			missing[env.EnvID] = struct{}{}
+		default:
+			return fmt.Errorf("error checking environment %s: %w", env.EnvID, err)
+		}
+	}
+
+	now := time.Now()
+	for _, env := range stored {
+		if skipReconcile(env) {
+			continue
+		}
+
+		if _, ok := missing[env.EnvID]; !ok {
+			if env.Status == model.StatusGone {
+				slog.Info("environment reappeared",
+					slog.String("name", env.DisplayName()),
+					slog.String("type", env.Type),
+					slog.String("id", env.EnvID),
+				)
+				if err := c.Repository.SetEnvironmentStatus(
+					ctx, env.EnvID, model.StatusActive,
+				); err != nil {
+					return err
+				}
+				recordAudit(ctx, c.Repository, model.NewAuditEntry(
+					env, model.AuditActionReappear,
+					model.ActorCrawler, model.AuditSourceCrawler,
+				))
+				sum.Reappeared++
+			}
+			continue
+		}
+
+		if env.Status != model.StatusGone {
+			slog.Warn("environment disappeared outside env-cleaner",
+				slog.String("name", env.DisplayName()),
+				slog.String("type", env.Type),
+				slog.String("id", env.EnvID),
+			)
+			if err := c.Repository.SetEnvironmentStatus(
+				ctx, env.EnvID, model.StatusGone,
+			); err != nil {
+				return err
+			}
+			recordAudit(ctx, c.Repository, model.NewAuditEntry(
+				env, model.AuditActionGone,
+				model.ActorCrawler, model.AuditSourceCrawler,
+			))
+			sum.Gone++
+
+			if c.config.NotifyGone {
+				if err := c.Notificator.SendGoneMessage(env); err != nil {
+					slog.Error("error sending gone message", slog.Any("error", err))
+				}
+			}
+			continue
+		}
+
+		if now.Sub(time.Unix(env.StatusChangedAt, 0)) < gracePeriod {
+			continue
+		}
+
+		slog.Info("removing gone environment",
+			slog.String("name", env.DisplayName()),
+			slog.String("type", env.Type),
+			slog.String("id", env.EnvID),
+		)
+		if err := c.Repository.DeleteEnvironment(ctx, env.EnvID); err != nil {
+			return err
+		}
+		sum.Removed++
+	}
+
+	return nil
+}
+
+// skipReconcile reports whether env is left out of reconciliation because
+// env-cleaner itself is deleting or has deleted it.
+func skipReconcile(env *model.Environment) bool {
+	return env.Status == model.StatusDeleting ||
+		env.Status == model.StatusDeleted
 }
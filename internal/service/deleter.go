@@ -4,20 +4,44 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/fragpit/env-cleaner/internal/metrics"
 	"github.com/fragpit/env-cleaner/internal/model"
 )
 
 const (
 	deleterOperationTimeout = 120 * time.Second
+	defaultDeletedRetention = "30d"
+	defaultRetryBackoff     = time.Hour
+	defaultRetryMaxBackoff  = 24 * time.Hour
+	defaultMaxFailures      = 3
 )
 
 type DeleterConfig struct {
 	DeleteInterval string
 	StaleThreshold string
-	DryRun         bool
+	// DeletedRetention is how long deleted environments are kept in the
+	// database before being purged.
+	DeletedRetention string
+	// RetryBackoff is the delay before retrying a failed deletion. It
+	// doubles with every failure up to RetryMaxBackoff.
+	RetryBackoff    string
+	RetryMaxBackoff string
+	// MaxFailures is the number of failed deletions after which the
+	// environment is marked failed and escalated to the admin.
+	MaxFailures int
+	// DryRun skips the deletion of outdated environments, which are still
+	// counted and reported to their owners as deleted.
+	DryRun bool
+	// PlanOnly makes a run only plan what it would warn and delete, without
+	// sending notifications or writing to the repository. It implies DryRun.
+	PlanOnly bool
 }
 
 type Deleter struct {
@@ -25,6 +49,12 @@ type Deleter struct {
 	Factory     ConnectorFactory
 	Repository  model.Repository
 	Notificator model.Notificator
+	wake        chan struct{}
+	// lastSuccess is the unix time of the last successful run.
+	lastSuccess atomic.Int64
+	// protectedReport lists the IDs of the environments in the last
+	// protected report, so that it is only sent again when they change.
+	protectedReport string
 }
 
 func NewDeleter(
@@ -38,27 +68,44 @@ func NewDeleter(
 		Factory:     factory,
 		Repository:  repo,
 		Notificator: nt,
+		wake:        make(chan struct{}, 1),
 	}
 }
 
-func (d *Deleter) Run(ctx context.Context) {
+// Wake starts a deleter run without waiting for the next interval. Wakes
+// arriving while a run is pending are merged into it.
+func (d *Deleter) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// LastSuccess returns the time of the last successful run, or the zero
+// time if none succeeded yet.
+func (d *Deleter) LastSuccess() time.Time {
+	return unixTime(d.lastSuccess.Load())
+}
+
+// Run runs the deleter periodically and on wakes until ctx is done. It
+// returns an error if the delete interval is invalid.
+func (d *Deleter) Run(ctx context.Context) error {
 	slog.Info("deleter service started",
 		slog.String("interval", d.config.DeleteInterval),
 	)
-	runDeleterPeriodically(ctx, startDeleter, d)
+	return runDeleterPeriodically(ctx, startDeleter, d)
 }
 
 func runDeleterPeriodically(
 	ctx context.Context,
 	f func(ctx context.Context, d *Deleter),
 	d *Deleter,
-) {
+) error {
 	interval, err := time.ParseDuration(
 		d.config.DeleteInterval,
 	)
 	if err != nil {
-		slog.Error("error parsing duration", slog.Any("error", err))
-		os.Exit(1)
+		return fmt.Errorf("error parsing delete interval: %w", err)
 	}
 
 	f(ctx, d)
@@ -72,9 +119,14 @@ func runDeleterPeriodically(
 				continue
 			}
 			f(ctx, d)
+		case <-d.wake:
+			if ctx.Err() != nil {
+				continue
+			}
+			f(ctx, d)
 		case <-ctx.Done():
 			slog.Info("deleter service shut down")
-			return
+			return nil
 		}
 	}
 }
@@ -82,21 +134,97 @@ func runDeleterPeriodically(
 func startDeleter(ctx context.Context, d *Deleter) {
 	slog.Info("deleter task started")
 
+	start := time.Now()
+	sum, err := d.RunOnce(ctx)
+	ok := err == nil && sum.Failed == 0
+	metrics.ObserveDeleterRun(start, ok)
+	if err != nil {
+		slog.Error("error running deleter", slog.Any("error", err))
+		return
+	}
+	if ok {
+		d.lastSuccess.Store(time.Now().Unix())
+	}
+
+	slog.Info("deleter task finished")
+}
+
+// DeleterSummary counts what a single deleter run did. In dry run mode
+// Deleted counts the environments that would have been deleted. In plan
+// only mode Warned counts the environments that would have been warned as
+// well, and Plan lists both. Skipped counts the environments that failed
+// the connector check, Failed the ones that could not be deleted and Errors
+// the other errors the run went on after.
+type DeleterSummary struct {
+	Warned    int
+	Deleted   int
+	Protected int
+	Skipped   int
+	Failed    int
+	Purged    int64
+	Errors    int
+	DryRun    bool
+	PlanOnly  bool
+	Plan      []PlannedAction
+}
+
+// PlannedAction is an action a plan only run would have taken. Action is
+// model.AuditActionWarn or model.AuditActionDelete.
+type PlannedAction struct {
+	Action string
+	Env    *model.Environment
+}
+
+// plan records an action skipped in plan only mode.
+func (s *DeleterSummary) plan(action string, env *model.Environment) {
+	slog.Info("plan: would "+action+" environment",
+		slog.String("name", env.DisplayName()),
+		slog.String("type", env.Type),
+		slog.String("id", env.EnvID),
+	)
+	s.Plan = append(s.Plan, PlannedAction{Action: action, Env: env})
+}
+
+// RunOnce runs a single deleter pass: it warns the owners of stale
+// environments, deletes the outdated ones and purges the deleted ones past
+// retention. In plan only mode it only checks the outdated environments
+// with their connectors and plans the rest, without sending notifications
+// or writing to the repository.
+func (d *Deleter) RunOnce(ctx context.Context) (*DeleterSummary, error) {
+	sum := d.newSummary()
+
 	ctx, cancel := context.WithTimeout(
 		ctx, deleterOperationTimeout,
 	)
 	defer cancel()
 
+	if err := d.warnStaleEnvironments(ctx, sum); err != nil {
+		slog.Error("error warning stale environments", slog.Any("error", err))
+		sum.Errors++
+	}
+
 	envs, err := d.GetOutdatedEnvironments(ctx)
 	if err != nil {
-		slog.Error("error getting outdated environments", slog.Any("error", err))
-		return
+		return sum, err
 	}
 
+	now := time.Now().Unix()
+	var protected []*model.Environment
 	for _, env := range envs {
+		if env.IsProtected(now) {
+			slog.Info("skipped deleting protected environment",
+				slog.String("name", env.DisplayName()),
+				slog.String("type", env.Type),
+				slog.String("id", env.EnvID),
+			)
+			protected = append(protected, env)
+			continue
+		}
+
 		connector, err := d.Factory.GetConnector(env.Type)
 		if err != nil {
 			slog.Error("error getting connector", slog.Any("error", err))
+			sum.Failed++
 			continue
 		}
 
@@ -104,40 +232,316 @@ func startDeleter(ctx context.Context, d *Deleter) {
 			ctx, env,
 		); err != nil {
 			slog.Error("error checking environment", slog.Any("error", err))
+			sum.Skipped++
+			continue
+		}
+
+		if d.config.PlanOnly {
+			sum.Deleted++
+			sum.plan(model.AuditActionDelete, env)
 			continue
 		}
 
 		if !d.config.DryRun {
+			if err := d.Repository.SetEnvironmentStatus(
+				ctx, env.EnvID, model.StatusDeleting,
+			); err != nil {
+				slog.Error("error marking environment as deleting",
+					slog.String("env_id", env.EnvID),
+					slog.Any("error", err),
+				)
+				sum.Errors++
+				continue
+			}
+
 			if err := connector.DeleteEnvironment(
 				ctx, env,
 			); err != nil {
 				slog.Error("error deleting environment", slog.Any("error", err))
+				metrics.ObserveDeletion(env.Type, false)
+				sum.Failed++
+				d.recordDeleteFailure(ctx, env, err)
 				continue
 			}
 
-			if err := d.Repository.DeleteEnvironment(
+			metrics.ObserveDeletion(env.Type, true)
+			d.setStatus(ctx, env.EnvID, model.StatusDeleted)
+			recordAudit(ctx, d.Repository, model.NewAuditEntry(
+				env, model.AuditActionDelete,
+				model.ActorDeleter, model.AuditSourceDeleter,
+			))
+
+			if err := d.Repository.DeleteToken(
 				ctx, env.EnvID,
 			); err != nil {
-				slog.Error("error deleting environment from DB", slog.Any("error", err))
-				continue
+				slog.Error("error deleting token", slog.Any("error", err))
 			}
 		}
+		sum.Deleted++
 
 		if err := d.Notificator.SendDeleteMessage(
 			env,
 		); err != nil {
 			slog.Error("error sending delete message", slog.Any("error", err))
+			sum.Errors++
 			continue
 		}
 	}
 
-	slog.Info("deleter task finished")
+	sum.Protected = len(protected)
+	if d.config.PlanOnly {
+		return sum, nil
+	}
+
+	if err := d.reportProtected(protected); err != nil {
+		slog.Error("error sending protected report", slog.Any("error", err))
+		sum.Errors++
+	}
+
+	purged, err := d.purgeDeletedEnvironments(ctx)
+	if err != nil {
+		slog.Error("error purging deleted environments", slog.Any("error", err))
+		sum.Errors++
+	}
+	sum.Purged = purged
+
+	return sum, nil
+}
+
+func (d *Deleter) newSummary() *DeleterSummary {
+	return &DeleterSummary{
+		DryRun:   d.config.DryRun || d.config.PlanOnly,
+		PlanOnly: d.config.PlanOnly,
+	}
+}
+
+// reportProtected sends the admin the report of outdated protected
+// environments, unless they are the ones already reported.
+func (d *Deleter) reportProtected(envs []*model.Environment) error {
+	ids := make([]string, len(envs))
+	for i, env := range envs {
+		ids[i] = env.EnvID
+	}
+	slices.Sort(ids)
+
+	key := strings.Join(ids, ",")
+	if key == d.protectedReport {
+		return nil
+	}
+
+	if len(envs) > 0 {
+		if err := d.Notificator.SendProtectedReport(envs); err != nil {
+			return err
+		}
+	}
+	d.protectedReport = key
+
+	return nil
+}
+
+// NotifyOnce only warns the owners of stale environments, without deleting
+// anything.
+func (d *Deleter) NotifyOnce(ctx context.Context) (*DeleterSummary, error) {
+	sum := d.newSummary()
+
+	ctx, cancel := context.WithTimeout(
+		ctx, deleterOperationTimeout,
+	)
+	defer cancel()
+
+	if err := d.warnStaleEnvironments(ctx, sum); err != nil {
+		return sum, err
+	}
+
+	return sum, nil
+}
+
+// warnStaleEnvironments notifies owners of unprotected environments that
+// will be deleted within the stale threshold. Warned environments move to the
+// warned status until their delete_at changes, so extending one re-arms the
+// warning. In plan only mode they are only added to the plan.
+func (d *Deleter) warnStaleEnvironments(
+	ctx context.Context,
+	sum *DeleterSummary,
+) error {
+	envs, err := d.GetStaleEnvironments(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, env := range envs {
+		if env.IsProtected(now) {
+			continue
+		}
+
+		if d.config.PlanOnly {
+			sum.Warned++
+			sum.plan(model.AuditActionWarn, env)
+			continue
+		}
+
+		tk, err := d.Repository.GetToken(ctx, env.EnvID)
+		if err != nil {
+			tk, err = d.Repository.SetToken(ctx, env.EnvID)
+			if err != nil {
+				slog.Error("error setting token",
+					slog.String("env_id", env.EnvID),
+					slog.Any("error", err),
+				)
+				sum.Errors++
+				continue
+			}
+		}
+
+		if err := d.Notificator.SendStaleMessage(env, tk); err != nil {
+			slog.Error("error sending stale message", slog.Any("error", err))
+			sum.Errors++
+			continue
+		}
+
+		d.setStatus(ctx, env.EnvID, model.StatusWarned)
+		recordAudit(ctx, d.Repository, model.NewAuditEntry(
+			env, model.AuditActionWarn,
+			model.ActorDeleter, model.AuditSourceDeleter,
+		))
+		sum.Warned++
+	}
+
+	return nil
+}
+
+// recordDeleteFailure stores a failed deletion attempt and schedules the
+// next one with exponential backoff. Environments below the failure limit
+// return to the status they had before the attempt. Reaching the limit
+// marks the environment failed and escalates it to the admin once; failed
+// environments keep being retried at the maximum backoff.
+func (d *Deleter) recordDeleteFailure(
+	ctx context.Context,
+	env *model.Environment,
+	deleteErr error,
+) {
+	env.FailureCount++
+	env.LastError = deleteErr.Error()
+	env.NextAttemptAt = time.Now().Add(d.retryBackoff(env.FailureCount)).Unix()
+
+	if err := d.Repository.SetDeleteFailure(
+		ctx, env.EnvID, env.FailureCount, env.LastError, env.NextAttemptAt,
+	); err != nil {
+		slog.Error("error recording delete failure",
+			slog.String("env_id", env.EnvID),
+			slog.Any("error", err),
+		)
+	}
+
+	maxFailures := d.config.MaxFailures
+	if maxFailures <= 0 {
+		maxFailures = defaultMaxFailures
+	}
+
+	entry := model.NewAuditEntry(
+		env, model.AuditActionDeleteFailed,
+		model.ActorDeleter, model.AuditSourceDeleter,
+	)
+	entry.Details = fmt.Sprintf("attempt %d: %s", env.FailureCount, env.LastError)
+	recordAudit(ctx, d.Repository, entry)
+
+	if env.FailureCount < maxFailures {
+		status := env.Status
+		if status == model.StatusDeleting {
+			status = model.StatusActive
+		}
+		d.setStatus(ctx, env.EnvID, status)
+		return
+	}
+
+	d.setStatus(ctx, env.EnvID, model.StatusFailed)
+	if env.FailureCount != maxFailures {
+		return
+	}
+
+	slog.Warn("environment failed to be deleted, escalating to admin",
+		slog.String("name", env.DisplayName()),
+		slog.String("type", env.Type),
+		slog.String("id", env.EnvID),
+		slog.Int("failures", env.FailureCount),
+	)
+	if err := d.Notificator.SendDeleteFailedMessage(env); err != nil {
+		slog.Error("error sending delete failed message", slog.Any("error", err))
+	}
+}
+
+// retryBackoff returns the delay before the next deletion attempt after
+// failures failed ones.
+func (d *Deleter) retryBackoff(failures int) time.Duration {
+	backoff := parseDurationOr(d.config.RetryBackoff, defaultRetryBackoff)
+	maxBackoff := parseDurationOr(d.config.RetryMaxBackoff, defaultRetryMaxBackoff)
+
+	for i := 1; i < failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, maxBackoff)
+}
+
+// parseDurationOr parses value, returning def if it is empty or invalid.
+func parseDurationOr(value string, def time.Duration) time.Duration {
+	if value == "" {
+		return def
+	}
+
+	dur, err := str2duration.ParseDuration(value)
+	if err != nil {
+		slog.Error("error parsing duration",
+			slog.String("value", value),
+			slog.Any("error", err),
+		)
+		return def
+	}
+
+	return dur
+}
+
+// purgeDeletedEnvironments removes environments that have been deleted for
+// longer than the retention period and returns their number.
+func (d *Deleter) purgeDeletedEnvironments(ctx context.Context) (int64, error) {
+	retention := d.config.DeletedRetention
+	if retention == "" {
+		retention = defaultDeletedRetention
+	}
+
+	period, err := str2duration.ParseDuration(retention)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing deleted retention: %w", err)
+	}
+
+	before := time.Now().Add(-period).Unix()
+	n, err := d.Repository.PurgeEnvironments(ctx, model.StatusDeleted, before)
+	if err != nil {
+		return 0, err
+	}
+
+	if n > 0 {
+		slog.Info("purged deleted environments", slog.Int64("count", n))
+	}
+
+	return n, nil
+}
+
+func (d *Deleter) setStatus(ctx context.Context, id, status string) {
+	if err := d.Repository.SetEnvironmentStatus(ctx, id, status); err != nil {
+		slog.Error("error setting environment status",
+			slog.String("env_id", id),
+			slog.String("status", status),
+			slog.Any("error", err),
+		)
+	}
 }
 
 func (d *Deleter) GetStaleEnvironments(
 	ctx context.Context,
 ) ([]*model.Environment, error) {
-	staleThreshold, err := time.ParseDuration(
+	staleThreshold, err := str2duration.ParseDuration(
 		d.config.StaleThreshold,
 	)
 	if err != nil {
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+)
+
+// fakeNotificator counts the messages sent through it.
+type fakeNotificator struct {
+	sent int
+}
+
+func (n *fakeNotificator) SendOrphanMessage(*model.Environment) error {
+	n.sent++
+	return nil
+}
+
+func (n *fakeNotificator) SendStaleMessage(*model.Environment, *model.Token) error {
+	n.sent++
+	return nil
+}
+
+func (n *fakeNotificator) SendDeleteMessage(*model.Environment) error {
+	n.sent++
+	return nil
+}
+
+func (n *fakeNotificator) SendGoneMessage(*model.Environment) error {
+	n.sent++
+	return nil
+}
+
+func (n *fakeNotificator) SendDeleteFailedMessage(*model.Environment) error {
+	n.sent++
+	return nil
+}
+
+func (n *fakeNotificator) SendProtectedReport([]*model.Environment) error {
+	n.sent++
+	return nil
+}
+
+// fakeConnector records the environments deleted through it.
+type fakeConnector struct {
+	deleted []string
+}
+
+func (c *fakeConnector) CheckEnvironment(context.Context, *model.Environment) error {
+	return nil
+}
+
+func (c *fakeConnector) DeleteEnvironment(_ context.Context, env *model.Environment) error {
+	c.deleted = append(c.deleted, env.EnvID)
+	return nil
+}
+
+func (c *fakeConnector) GetConnectorType() string { return "helm" }
+
+func (c *fakeConnector) GetEnvironments(context.Context) ([]model.Environment, error) {
+	return nil, nil
+}
+
+func (c *fakeConnector) GetEnvironmentID(context.Context, *model.Environment) (string, error) {
+	return "", nil
+}
+
+func (c *fakeConnector) Ping(context.Context) error { return nil }
+
+func TestDeleterDryRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		dryRun      bool
+		planOnly    bool
+		wantSent    int
+		wantDeleted int
+		wantPlan    int
+		// wantStatus is the status of the stale environment 1 and the
+		// outdated environment 2 after the run.
+		wantStatus [2]string
+	}{
+		{
+			name:        "real run",
+			wantSent:    3,
+			wantDeleted: 1,
+			wantStatus:  [2]string{model.StatusWarned, model.StatusDeleted},
+		},
+		{
+			name:       "dry run",
+			dryRun:     true,
+			wantSent:   3,
+			wantStatus: [2]string{model.StatusWarned, model.StatusActive},
+		},
+		{
+			name:       "plan only",
+			planOnly:   true,
+			wantPlan:   2,
+			wantStatus: [2]string{model.StatusActive, model.StatusActive},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newTestStorage(t)
+			ctx := context.Background()
+
+			past := time.Now().Add(-time.Hour)
+			outdated := func(id string) model.Environment {
+				env := testEnvironment(id, "app-"+id, "review", "helm")
+				env.DeleteAt = past.Format("02-01-06 15:04:05")
+				env.DeleteAtSec = past.Unix()
+				return env
+			}
+			stale := testEnvironment("1", "app-1", "review", "helm")
+			protected := outdated("3")
+			protected.Protected = true
+			protected.ProtectedBy = model.ProtectedByAPI
+			if err := st.WriteEnvironments(ctx, []model.Environment{
+				stale, outdated("2"), protected,
+			}); err != nil {
+				t.Fatalf("write environments: %v", err)
+			}
+
+			nt := &fakeNotificator{}
+			conn := &fakeConnector{}
+			d := NewDeleter(DeleterConfig{
+				StaleThreshold:   "2d",
+				DeletedRetention: "1d",
+				DryRun:           tt.dryRun,
+				PlanOnly:         tt.planOnly,
+			}, &ConnectorList{
+				Connectors: map[string]model.Connector{"helm": conn},
+			}, st, nt)
+
+			sum, err := d.RunOnce(ctx)
+			if err != nil {
+				t.Fatalf("RunOnce: %v", err)
+			}
+			if sum.Warned != 1 || sum.Deleted != 1 || sum.Protected != 1 ||
+				sum.Errors != 0 {
+				t.Errorf("summary = %+v, want 1 warned, deleted and protected",
+					sum)
+			}
+			if nt.sent != tt.wantSent || len(conn.deleted) != tt.wantDeleted ||
+				len(sum.Plan) != tt.wantPlan {
+				t.Errorf("sent %d messages, deleted %v, planned %+v, "+
+					"want %d messages, %d deleted and %d planned",
+					nt.sent, conn.deleted, sum.Plan,
+					tt.wantSent, tt.wantDeleted, tt.wantPlan)
+			}
+
+			for i, id := range []string{"1", "2"} {
+				env, err := st.GetEnvByID(ctx, id)
+				if err != nil {
+					t.Fatalf("get environment %s: %v", id, err)
+				}
+				if env.Status != tt.wantStatus[i] {
+					t.Errorf("environment %s status = %s, want %s",
+						id, env.Status, tt.wantStatus[i])
+				}
+			}
+
+			if !tt.planOnly {
+				return
+			}
+
+			if sum.Plan[0].Action != model.AuditActionWarn ||
+				sum.Plan[0].Env.EnvID != "1" ||
+				sum.Plan[1].Action != model.AuditActionDelete ||
+				sum.Plan[1].Env.EnvID != "2" {
+				t.Errorf("plan = %+v, want warn 1 and delete 2", sum.Plan)
+			}
+			if _, err := st.GetToken(ctx, "1"); err == nil {
+				t.Error("plan only run created a token")
+			}
+			entries, err := st.GetAuditEntries(ctx, &model.AuditFilter{})
+			if err != nil {
+				t.Fatalf("get audit entries: %v", err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("plan only run wrote audit entries %+v", entries)
+			}
+		})
+	}
+}
+
+func TestDeleterRunInvalidInterval(t *testing.T) {
+	d := NewDeleter(DeleterConfig{DeleteInterval: "1d"},
+		&ConnectorList{}, newTestStorage(t), &fakeNotificator{})
+
+	if err := d.Run(context.Background()); err == nil {
+		t.Fatal("Run returned no error for an invalid delete interval")
+	}
+}
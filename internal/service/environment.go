@@ -1,36 +1,208 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/fragpit/env-cleaner/internal/metrics"
 	"github.com/fragpit/env-cleaner/internal/model"
 	"github.com/fragpit/env-cleaner/pkg/utils"
 )
 
+const (
+	defaultEnvironmentsLimit = 100
+	maxEnvironmentsLimit     = 1000
+)
+
+// DeleterWaker starts a deleter run ahead of its interval.
+type DeleterWaker interface {
+	Wake()
+}
+
 type EnvironmentService struct {
 	repo              model.Repository
 	connectorFactory  ConnectorFactory
+	deleter           DeleterWaker
 	maxExtendDuration string
 }
 
 func NewEnvironmentService(
 	repo model.Repository,
 	connectorFactory ConnectorFactory,
+	deleter DeleterWaker,
 	maxExtendDuration string,
 ) *EnvironmentService {
 	return &EnvironmentService{
 		repo:              repo,
 		connectorFactory:  connectorFactory,
+		deleter:           deleter,
 		maxExtendDuration: maxExtendDuration,
 	}
 }
 
+// GetEnvironments returns a page of environments matching filter and the
+// cursor of the next page, empty on the last one. cursor continues a
+// previous listing with the same filter.
 func (s *EnvironmentService) GetEnvironments(
 	ctx context.Context,
-) ([]*model.Environment, error) {
-	return s.repo.GetEnvironments(ctx)
+	filter *model.EnvironmentFilter,
+	cursor string,
+) ([]*model.Environment, string, error) {
+	if filter == nil {
+		filter = &model.EnvironmentFilter{}
+	}
+
+	if owner := actorFromContext(ctx).Owner; owner != "" {
+		if filter.Owner != "" && filter.Owner != owner {
+			return nil, "", &model.ForbiddenError{
+				Msg: "environments of other owners are not accessible",
+			}
+		}
+		filter.Owner = owner
+	}
+
+	for _, st := range filter.Statuses {
+		if !model.ValidStatus(st) {
+			return nil, "", &model.ValidationError{
+				Msg: fmt.Sprintf("unknown status: %s", st),
+			}
+		}
+	}
+
+	// SQLite GLOB supports character classes, the PostgreSQL LIKE the
+	// pattern is converted to does not. Reject them on both.
+	if strings.ContainsAny(filter.Name, "[]") {
+		return nil, "", &model.ValidationError{
+			Msg: "name pattern: character classes are not supported",
+		}
+	}
+
+	field, _ := model.ParseSort(filter.Sort)
+	if !slices.Contains(model.SortFields, field) {
+		return nil, "", &model.ValidationError{
+			Msg: fmt.Sprintf("unknown sort field: %s", field),
+		}
+	}
+
+	switch {
+	case filter.Limit < 0 || filter.Limit > maxEnvironmentsLimit:
+		return nil, "", &model.ValidationError{
+			Msg: "limit must be between 1 and 1000",
+		}
+	case filter.Limit == 0:
+		filter.Limit = defaultEnvironmentsLimit
+	}
+
+	if cursor != "" {
+		after, err := decodeCursor(cursor, field)
+		if err != nil {
+			return nil, "", &model.ValidationError{Msg: "invalid cursor"}
+		}
+		filter.After = after
+	}
+
+	// One more environment than requested tells whether there is a next
+	// page.
+	limit := filter.Limit
+	query := *filter
+	query.Limit++
+	envs, err := s.repo.GetEnvironments(ctx, &query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(envs) <= limit {
+		return envs, "", nil
+	}
+
+	envs = envs[:limit]
+	last := envs[limit-1]
+	next, err := encodeCursor(&model.EnvironmentCursor{
+		Key:   last.SortKey(field),
+		EnvID: last.EnvID,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("error encoding cursor: %w", err)
+	}
+
+	return envs, next, nil
+}
+
+type cursorJSON struct {
+	Key   any    `json:"k"`
+	EnvID string `json:"id"`
+}
+
+// encodeCursor returns an opaque string for a list position.
+func encodeCursor(c *model.EnvironmentCursor) (string, error) {
+	data, err := json.Marshal(cursorJSON{Key: c.Key, EnvID: c.EnvID})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor parses a cursor returned by encodeCursor for a list sorted
+// by field.
+func decodeCursor(cursor, field string) (*model.EnvironmentCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var c cursorJSON
+	if err := dec.Decode(&c); err != nil {
+		return nil, err
+	}
+
+	switch key := c.Key.(type) {
+	case json.Number:
+		if field != model.SortByDeleteAt {
+			return nil, errors.New("cursor does not match sort field")
+		}
+		n, err := key.Int64()
+		if err != nil {
+			return nil, err
+		}
+		return &model.EnvironmentCursor{Key: n, EnvID: c.EnvID}, nil
+	case string:
+		if field == model.SortByDeleteAt {
+			return nil, errors.New("cursor does not match sort field")
+		}
+		return &model.EnvironmentCursor{Key: key, EnvID: c.EnvID}, nil
+	default:
+		return nil, errors.New("invalid cursor key")
+	}
+}
+
+// GetEnvironment returns a stored environment in any status.
+func (s *EnvironmentService) GetEnvironment(
+	ctx context.Context,
+	envID string,
+) (*model.Environment, error) {
+	env, err := s.repo.GetEnvByID(ctx, envID)
+	if err != nil {
+		return nil, &model.NotFoundError{
+			Msg: fmt.Sprintf("environment not found: %v", err),
+		}
+	}
+	if err := checkOwner(ctx, env); err != nil {
+		return nil, err
+	}
+
+	return env, nil
 }
 
 func (s *EnvironmentService) AddEnvironment(
@@ -54,6 +226,12 @@ func (s *EnvironmentService) AddEnvironment(
 
 	env.DeleteAt = deleteAt
 	env.DeleteAtSec = deleteAtSec
+	env.TTL = ttl
+	env.DeleteAtSource = model.DeleteAtSourceAPI
+
+	if err := model.ValidateLabels(env.Labels); err != nil {
+		return &model.ValidationError{Msg: err.Error()}
+	}
 
 	env.EnvID, err = conn.GetEnvironmentID(ctx, env)
 	if err != nil {
@@ -68,7 +246,8 @@ func (s *EnvironmentService) AddEnvironment(
 		}
 	}
 
-	if _, err := s.repo.GetEnvByID(ctx, env.EnvID); err == nil {
+	if stored, err := s.repo.GetEnvByID(ctx, env.EnvID); err == nil &&
+		stored.Status != model.StatusDeleted {
 		return &model.ConflictError{Msg: "environment already exists"}
 	}
 
@@ -77,6 +256,13 @@ func (s *EnvironmentService) AddEnvironment(
 		return fmt.Errorf("error writing environments: %w", err)
 	}
 
+	actor := actorFromContext(ctx)
+	entry := model.NewAuditEntry(
+		env, model.AuditActionCreate, actor.Name, actor.Source,
+	)
+	entry.Details = "ttl " + ttl
+	recordAudit(ctx, s.repo, entry)
+
 	return nil
 }
 
@@ -107,8 +293,12 @@ func (s *EnvironmentService) ExtendEnvironment(
 	ctx context.Context,
 	envID, period, token string,
 ) (*model.Environment, error) {
+	outcome := metrics.ExtendOutcomeError
+	defer func() { metrics.ObserveExtendRequest(outcome) }()
+
 	tk, err := s.repo.GetToken(ctx, envID)
 	if err != nil || tk.Token != token {
+		outcome = metrics.ExtendOutcomeInvalidToken
 		return nil, &model.ValidationError{
 			Msg: "invalid token",
 		}
@@ -117,6 +307,7 @@ func (s *EnvironmentService) ExtendEnvironment(
 	if err := utils.PeriodValidate(
 		period, s.maxExtendDuration,
 	); err != nil {
+		outcome = metrics.ExtendOutcomeInvalidPeriod
 		return nil, &model.ValidationError{
 			Msg: fmt.Sprintf("invalid period: %v", err),
 		}
@@ -124,6 +315,7 @@ func (s *EnvironmentService) ExtendEnvironment(
 
 	env, err := s.repo.GetEnvByID(ctx, envID)
 	if err != nil {
+		outcome = metrics.ExtendOutcomeNotFound
 		return nil, &model.NotFoundError{
 			Msg: fmt.Sprintf(
 				"environment not found: %v", err,
@@ -131,6 +323,14 @@ func (s *EnvironmentService) ExtendEnvironment(
 		}
 	}
 
+	switch env.Status {
+	case model.StatusDeleting, model.StatusDeleted:
+		outcome = metrics.ExtendOutcomeConflict
+		return nil, &model.ConflictError{
+			Msg: fmt.Sprintf("environment is %s", env.Status),
+		}
+	}
+
 	if err := s.repo.ExtendEnvironment(
 		ctx, envID, period,
 	); err != nil {
@@ -138,6 +338,18 @@ func (s *EnvironmentService) ExtendEnvironment(
 			"error extending environment: %w", err,
 		)
 	}
+	outcome = metrics.ExtendOutcomeExtended
+
+	if env.Status == model.StatusFailed {
+		if err := s.repo.SetEnvironmentStatus(
+			ctx, envID, model.StatusActive,
+		); err != nil {
+			slog.Error("error reactivating environment",
+				slog.String("env_id", envID),
+				slog.Any("error", err),
+			)
+		}
+	}
 
 	if err := s.repo.DeleteToken(ctx, env.EnvID); err != nil {
 		slog.Error("error deleting token",
@@ -146,6 +358,7 @@ func (s *EnvironmentService) ExtendEnvironment(
 		)
 	}
 
+	oldDeleteAt := env.DeleteAt
 	env, err = s.repo.GetEnvByID(ctx, envID)
 	if err != nil {
 		return nil, fmt.Errorf(
@@ -154,5 +367,447 @@ func (s *EnvironmentService) ExtendEnvironment(
 		)
 	}
 
+	// Extend links are only sent to the owner, so whoever holds the token
+	// acts on their behalf.
+	actor := actorFromContext(ctx)
+	if actor.Name == "" {
+		actor.Name = env.Owner
+	}
+
+	entry := model.NewAuditEntry(
+		env, model.AuditActionExtend, actor.Name, actor.Source,
+	)
+	entry.OldDeleteAt = oldDeleteAt
+	entry.Details = "period " + period
+	recordAudit(ctx, s.repo, entry)
+
+	return env, nil
+}
+
+// AdminExtendEnvironment extends an environment by period or moves its
+// deletion date to deleteAt on behalf of an authenticated actor, without a
+// token. The extension is limited by max_extend_duration unless ignoreMax
+// is set, which requires the admin scope.
+func (s *EnvironmentService) AdminExtendEnvironment(
+	ctx context.Context,
+	envID, period, deleteAt string,
+	ignoreMax bool,
+) (*model.Environment, error) {
+	if (period == "") == (deleteAt == "") {
+		return nil, &model.ValidationError{
+			Msg: "either period or delete_at is required",
+		}
+	}
+
+	if ignoreMax {
+		if actor, ok := model.ActorFromContext(ctx); !ok ||
+			!actor.HasScope(model.ScopeAdmin) {
+			return nil, &model.ForbiddenError{
+				Msg: "ignore_max requires the admin scope",
+			}
+		}
+	}
+
+	maxExtend, err := str2duration.ParseDuration(s.maxExtendDuration)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing max extend duration: %w", err)
+	}
+
+	env, err := s.getLiveEnvironment(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+
+	// An environment already past its deletion date is extended from now,
+	// so that it is not deleted on the next deleter pass.
+	oldDeleteAt := env.DeleteAt
+	now := time.Now().Unix()
+	base := max(now, env.DeleteAtSec)
+	limit := base + int64(maxExtend.Seconds())
+
+	var details []string
+	if period != "" {
+		dur, err := str2duration.ParseDuration(period)
+		if err != nil || dur <= 0 {
+			return nil, &model.ValidationError{
+				Msg: fmt.Sprintf("invalid period: %s", period),
+			}
+		}
+
+		deleteAt := time.Unix(base, 0).Add(dur)
+		if !ignoreMax && deleteAt.Unix() > limit {
+			return nil, &model.ValidationError{
+				Msg: "invalid period: period is greater than max duration",
+			}
+		}
+
+		env.DeleteAt = deleteAt.Format("02-01-06 15:04:05")
+		env.DeleteAtSec = deleteAt.Unix()
+		details = append(details, "period "+period)
+	} else {
+		env.DeleteAt, env.DeleteAtSec, err = utils.ParseDeleteAt(deleteAt)
+		if err != nil {
+			return nil, &model.ValidationError{
+				Msg: fmt.Sprintf("invalid delete_at: %v", err),
+			}
+		}
+		if env.DeleteAtSec <= now {
+			return nil, &model.ValidationError{
+				Msg: "delete_at is in the past",
+			}
+		}
+		if !ignoreMax && env.DeleteAtSec > limit {
+			return nil, &model.ValidationError{
+				Msg: "invalid delete_at: extension is greater than max duration",
+			}
+		}
+		details = append(details, "delete_at "+env.DeleteAt)
+	}
+
+	env.DeleteAtSource = model.DeleteAtSourceExtend
+	if err := s.repo.UpdateEnvironment(ctx, env); err != nil {
+		return nil, fmt.Errorf("error extending environment: %w", err)
+	}
+	if ignoreMax {
+		details = append(details, "ignoring max_extend_duration")
+	}
+
+	s.resetDeleteFailures(ctx, env)
+
+	env, err = s.GetEnvironment(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+
+	actor := actorFromContext(ctx)
+	entry := model.NewAuditEntry(
+		env, model.AuditActionExtend, actor.Name, actor.Source,
+	)
+	entry.OldDeleteAt = oldDeleteAt
+	entry.Details = strings.Join(details, ", ")
+	recordAudit(ctx, s.repo, entry)
+
+	return env, nil
+}
+
+// UpdateEnvironment changes the owner and the deletion date of an
+// environment. The deletion date is set either from ttl counted from now or
+// from the absolute deleteAt. Empty arguments are left unchanged.
+func (s *EnvironmentService) UpdateEnvironment(
+	ctx context.Context,
+	envID, owner, ttl, deleteAt string,
+) (*model.Environment, error) {
+	if ttl != "" && deleteAt != "" {
+		return nil, &model.ValidationError{
+			Msg: "ttl and delete_at are mutually exclusive",
+		}
+	}
+
+	env, err := s.getLiveEnvironment(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+
+	oldDeleteAt := env.DeleteAt
+	var details []string
+	changed := false
+	if owner != "" && owner != env.Owner {
+		details = append(details, fmt.Sprintf(
+			"owner %s -> %s", env.Owner, owner,
+		))
+		env.Owner = owner
+		changed = true
+	}
+
+	switch {
+	case ttl != "":
+		env.DeleteAt, env.DeleteAtSec, err = utils.SetDeleteAt(ttl)
+		if err != nil {
+			return nil, &model.ValidationError{
+				Msg: fmt.Sprintf("invalid ttl: %v", err),
+			}
+		}
+		details = append(details, fmt.Sprintf("ttl %s -> %s", env.TTL, ttl))
+		env.TTL = ttl
+		env.DeleteAtSource = model.DeleteAtSourceAPI
+		changed = true
+	case deleteAt != "":
+		env.DeleteAt, env.DeleteAtSec, err = utils.ParseDeleteAt(deleteAt)
+		if err != nil {
+			return nil, &model.ValidationError{
+				Msg: fmt.Sprintf("invalid delete_at: %v", err),
+			}
+		}
+		if env.DeleteAtSec <= time.Now().Unix() {
+			return nil, &model.ValidationError{
+				Msg: "delete_at is in the past",
+			}
+		}
+		env.DeleteAtSource = model.DeleteAtSourceAPI
+		changed = true
+	}
+
+	if !changed {
+		return env, nil
+	}
+
+	if err := s.repo.UpdateEnvironment(ctx, env); err != nil {
+		return nil, fmt.Errorf("error updating environment: %w", err)
+	}
+
+	if env.DeleteAt != oldDeleteAt {
+		s.resetDeleteFailures(ctx, env)
+	}
+
+	actor := actorFromContext(ctx)
+	entry := model.NewAuditEntry(
+		env, model.AuditActionUpdate, actor.Name, actor.Source,
+	)
+	entry.OldDeleteAt = oldDeleteAt
+	entry.Details = strings.Join(details, ", ")
+	recordAudit(ctx, s.repo, entry)
+
+	return s.GetEnvironment(ctx, envID)
+}
+
+// DeleteEnvironment moves the deletion date of an environment to now and
+// wakes the deleter, which tears it down through its connector. With forget,
+// which requires the admin scope, the environment is only removed from the
+// database.
+func (s *EnvironmentService) DeleteEnvironment(
+	ctx context.Context,
+	envID string,
+	forget bool,
+) (*model.Environment, error) {
+	if forget {
+		if actor, ok := model.ActorFromContext(ctx); !ok ||
+			!actor.HasScope(model.ScopeAdmin) {
+			return nil, &model.ForbiddenError{
+				Msg: "forget requires the admin scope",
+			}
+		}
+		return s.forgetEnvironment(ctx, envID)
+	}
+
+	env, err := s.getLiveEnvironment(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+
+	if env.Status == model.StatusGone {
+		return nil, &model.ConflictError{
+			Msg: "environment is gone, forget it instead",
+		}
+	}
+
+	if env.IsProtected(time.Now().Unix()) {
+		return nil, &model.ConflictError{Msg: "environment is protected"}
+	}
+
+	// The deleter picks up environments strictly past delete_at, so the
+	// date is set a second back to be due on the run woken below.
+	oldDeleteAt := env.DeleteAt
+	deleteAt := time.Now().Add(-time.Second)
+	env.DeleteAt = deleteAt.Format("02-01-06 15:04:05")
+	env.DeleteAtSec = deleteAt.Unix()
+	env.DeleteAtSource = model.DeleteAtSourceAPI
+	if err := s.repo.UpdateEnvironment(ctx, env); err != nil {
+		return nil, fmt.Errorf("error scheduling environment deletion: %w", err)
+	}
+
+	if err := s.repo.SetDeleteFailure(
+		ctx, env.EnvID, env.FailureCount, env.LastError, 0,
+	); err != nil {
+		slog.Error("error resetting next deletion attempt",
+			slog.String("env_id", env.EnvID),
+			slog.Any("error", err),
+		)
+	}
+
+	actor := actorFromContext(ctx)
+	entry := model.NewAuditEntry(
+		env, model.AuditActionDeleteNow, actor.Name, actor.Source,
+	)
+	entry.OldDeleteAt = oldDeleteAt
+	recordAudit(ctx, s.repo, entry)
+
+	if s.deleter != nil {
+		s.deleter.Wake()
+	}
+
+	return s.GetEnvironment(ctx, envID)
+}
+
+// forgetEnvironment stops tracking an environment without touching it.
+// Environments with connector metadata are discovered again on the next
+// crawl unless the metadata is removed too.
+func (s *EnvironmentService) forgetEnvironment(
+	ctx context.Context,
+	envID string,
+) (*model.Environment, error) {
+	env, err := s.GetEnvironment(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+
+	if env.Status == model.StatusDeleting {
+		return nil, &model.ConflictError{Msg: "environment is deleting"}
+	}
+
+	if err := s.repo.DeleteEnvironment(ctx, env.EnvID); err != nil {
+		return nil, fmt.Errorf("error forgetting environment: %w", err)
+	}
+
+	actor := actorFromContext(ctx)
+	recordAudit(ctx, s.repo, model.NewAuditEntry(
+		env, model.AuditActionForget, actor.Name, actor.Source,
+	))
+
 	return env, nil
 }
+
+// resetDeleteFailures clears failed deletion attempts of an environment
+// given a new deletion date and reactivates it if it was failed.
+func (s *EnvironmentService) resetDeleteFailures(
+	ctx context.Context,
+	env *model.Environment,
+) {
+	if env.FailureCount > 0 {
+		if err := s.repo.SetDeleteFailure(
+			ctx, env.EnvID, 0, "", 0,
+		); err != nil {
+			slog.Error("error resetting deletion failures",
+				slog.String("env_id", env.EnvID),
+				slog.Any("error", err),
+			)
+		}
+	}
+
+	if env.Status == model.StatusFailed {
+		if err := s.repo.SetEnvironmentStatus(
+			ctx, env.EnvID, model.StatusActive,
+		); err != nil {
+			slog.Error("error reactivating environment",
+				slog.String("env_id", env.EnvID),
+				slog.Any("error", err),
+			)
+		}
+	}
+}
+
+// ProtectEnvironment protects an environment from deletion for period, or
+// indefinitely if period is empty.
+func (s *EnvironmentService) ProtectEnvironment(
+	ctx context.Context,
+	envID, period, reason string,
+) (*model.Environment, error) {
+	env, err := s.getLiveEnvironment(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+
+	var until int64
+	if period != "" {
+		dur, err := str2duration.ParseDuration(period)
+		if err != nil || dur <= 0 {
+			return nil, &model.ValidationError{
+				Msg: fmt.Sprintf("invalid period: %s", period),
+			}
+		}
+		until = time.Now().Add(dur).Unix()
+	}
+
+	env.Protected = true
+	env.ProtectedUntil = until
+	env.ProtectedReason = reason
+	env.ProtectedBy = model.ProtectedByAPI
+	if err := s.repo.SetProtection(ctx, env); err != nil {
+		return nil, fmt.Errorf("error protecting environment: %w", err)
+	}
+
+	actor := actorFromContext(ctx)
+	entry := model.NewAuditEntry(
+		env, model.AuditActionProtect, actor.Name, actor.Source,
+	)
+	var details []string
+	if reason != "" {
+		details = append(details, "reason "+reason)
+	}
+	if period != "" {
+		details = append(details, "period "+period)
+	}
+	entry.Details = strings.Join(details, ", ")
+	recordAudit(ctx, s.repo, entry)
+
+	return env, nil
+}
+
+// UnprotectEnvironment lifts the protection of an environment, whatever
+// set it. Protection from connector metadata is restored on the next crawl
+// unless it is removed from the metadata too.
+func (s *EnvironmentService) UnprotectEnvironment(
+	ctx context.Context,
+	envID string,
+) (*model.Environment, error) {
+	env, err := s.getLiveEnvironment(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !env.Protected {
+		return env, nil
+	}
+
+	env.Protected = false
+	env.ProtectedUntil = 0
+	env.ProtectedReason = ""
+	env.ProtectedBy = ""
+	if err := s.repo.SetProtection(ctx, env); err != nil {
+		return nil, fmt.Errorf("error unprotecting environment: %w", err)
+	}
+
+	actor := actorFromContext(ctx)
+	recordAudit(ctx, s.repo, model.NewAuditEntry(
+		env, model.AuditActionUnprotect, actor.Name, actor.Source,
+	))
+
+	return env, nil
+}
+
+// getLiveEnvironment returns a stored environment that is not being or has
+// not been deleted.
+func (s *EnvironmentService) getLiveEnvironment(
+	ctx context.Context,
+	envID string,
+) (*model.Environment, error) {
+	env, err := s.repo.GetEnvByID(ctx, envID)
+	if err != nil {
+		return nil, &model.NotFoundError{
+			Msg: fmt.Sprintf("environment not found: %v", err),
+		}
+	}
+	if err := checkOwner(ctx, env); err != nil {
+		return nil, err
+	}
+
+	switch env.Status {
+	case model.StatusDeleting, model.StatusDeleted:
+		return nil, &model.ConflictError{
+			Msg: fmt.Sprintf("environment is %s", env.Status),
+		}
+	}
+
+	return env, nil
+}
+
+// checkOwner hides env from actors restricted to other owners.
+func checkOwner(ctx context.Context, env *model.Environment) error {
+	actor := actorFromContext(ctx)
+	if actor.Owner != "" && actor.Owner != env.Owner {
+		return &model.NotFoundError{
+			Msg: fmt.Sprintf("environment not found: %s", env.EnvID),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+)
+
+func TestAdminExtendEnvironmentIgnoreMax(t *testing.T) {
+	extendKey := model.Actor{
+		Name:   "ci",
+		Source: model.AuditSourceAPI,
+		Scopes: []string{model.ScopeExtend},
+	}
+	adminKey := model.Actor{
+		Name:   "ops",
+		Source: model.AuditSourceAPI,
+		Scopes: []string{model.ScopeAdmin},
+	}
+
+	tests := []struct {
+		name          string
+		actor         *model.Actor
+		period        string
+		ignoreMax     bool
+		wantForbidden bool
+		wantInvalid   bool
+	}{
+		{
+			name:   "extend key within max",
+			actor:  &extendKey,
+			period: "2d",
+		},
+		{
+			name:        "extend key over max",
+			actor:       &extendKey,
+			period:      "10d",
+			wantInvalid: true,
+		},
+		{
+			name:          "extend key with ignore_max",
+			actor:         &extendKey,
+			period:        "10d",
+			ignoreMax:     true,
+			wantForbidden: true,
+		},
+		{
+			name:      "admin key with ignore_max",
+			actor:     &adminKey,
+			period:    "10d",
+			ignoreMax: true,
+		},
+		{
+			name:          "no actor with ignore_max",
+			period:        "10d",
+			ignoreMax:     true,
+			wantForbidden: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newTestStorage(t)
+			ctx := context.Background()
+			if tt.actor != nil {
+				ctx = model.WithActor(ctx, *tt.actor)
+			}
+
+			if err := st.WriteEnvironments(ctx, []model.Environment{
+				testEnvironment("1", "review-app", "app", "helm"),
+			}); err != nil {
+				t.Fatalf("write environments: %v", err)
+			}
+
+			svc := NewEnvironmentService(st, nil, nil, "3d")
+			_, err := svc.AdminExtendEnvironment(
+				ctx, "1", tt.period, "", tt.ignoreMax,
+			)
+
+			var fe *model.ForbiddenError
+			var ve *model.ValidationError
+			switch {
+			case tt.wantForbidden:
+				if !errors.As(err, &fe) {
+					t.Fatalf("error = %v, want ForbiddenError", err)
+				}
+			case tt.wantInvalid:
+				if !errors.As(err, &ve) {
+					t.Fatalf("error = %v, want ValidationError", err)
+				}
+			case err != nil:
+				t.Fatalf("AdminExtendEnvironment: %v", err)
+			}
+		})
+	}
+}
+
+func TestAdminExtendEnvironmentOverdue(t *testing.T) {
+	st := newTestStorage(t)
+	ctx := model.WithActor(context.Background(), model.Actor{
+		Name:   "ops",
+		Source: model.AuditSourceAPI,
+		Scopes: []string{model.ScopeExtend},
+	})
+
+	past := time.Now().Add(-48 * time.Hour)
+	env := testEnvironment("1", "review-app", "app", "helm")
+	env.DeleteAt = past.Format("02-01-06 15:04:05")
+	env.DeleteAtSec = past.Unix()
+	if err := st.WriteEnvironments(ctx, []model.Environment{env}); err != nil {
+		t.Fatalf("write environments: %v", err)
+	}
+
+	svc := NewEnvironmentService(st, nil, nil, "3d")
+	got, err := svc.AdminExtendEnvironment(ctx, "1", "1d", "", false)
+	if err != nil {
+		t.Fatalf("AdminExtendEnvironment: %v", err)
+	}
+
+	// Extended from now rather than from the past deletion date.
+	want := time.Now().Add(24 * time.Hour).Unix()
+	if got.DeleteAtSec < want-60 || got.DeleteAtSec > want+60 {
+		t.Errorf("delete_at = %s, want about a day from now", got.DeleteAt)
+	}
+}
+
+func TestDeleteEnvironmentForgetWithoutActor(t *testing.T) {
+	st := newTestStorage(t)
+	ctx := context.Background()
+
+	if err := st.WriteEnvironments(ctx, []model.Environment{
+		testEnvironment("1", "review-app", "app", "helm"),
+	}); err != nil {
+		t.Fatalf("write environments: %v", err)
+	}
+
+	svc := NewEnvironmentService(st, nil, nil, "3d")
+	_, err := svc.DeleteEnvironment(ctx, "1", true)
+
+	var fe *model.ForbiddenError
+	if !errors.As(err, &fe) {
+		t.Fatalf("error = %v, want ForbiddenError", err)
+	}
+}
+
+func TestBatchEnvironmentsIgnoreMax(t *testing.T) {
+	tests := []struct {
+		name          string
+		noActor       bool
+		scopes        []string
+		ignoreMax     bool
+		wantForbidden bool
+	}{
+		{
+			name:   "extend key",
+			scopes: []string{model.ScopeExtend},
+		},
+		{
+			name:          "extend key with ignore_max",
+			scopes:        []string{model.ScopeExtend},
+			ignoreMax:     true,
+			wantForbidden: true,
+		},
+		{
+			name:      "admin key with ignore_max",
+			scopes:    []string{model.ScopeAdmin},
+			ignoreMax: true,
+		},
+		{
+			name:          "no actor",
+			noActor:       true,
+			wantForbidden: true,
+		},
+		{
+			name:          "no actor with ignore_max",
+			noActor:       true,
+			ignoreMax:     true,
+			wantForbidden: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newTestStorage(t)
+			ctx := context.Background()
+			if !tt.noActor {
+				ctx = model.WithActor(ctx, model.Actor{
+					Name:   "ci",
+					Source: model.AuditSourceAPI,
+					Scopes: tt.scopes,
+				})
+			}
+
+			if err := st.WriteEnvironments(ctx, []model.Environment{
+				testEnvironment("1", "review-app", "app", "helm"),
+			}); err != nil {
+				t.Fatalf("write environments: %v", err)
+			}
+
+			period := "2d"
+			if tt.ignoreMax {
+				period = "10d"
+			}
+
+			svc := NewEnvironmentService(st, nil, nil, "3d")
+			results, err := svc.BatchEnvironments(ctx, &model.BatchOperation{
+				Action:    model.BatchActionExtend,
+				IDs:       []string{"1"},
+				Period:    period,
+				IgnoreMax: tt.ignoreMax,
+			})
+
+			if tt.wantForbidden {
+				var fe *model.ForbiddenError
+				if !errors.As(err, &fe) {
+					t.Fatalf("error = %v, want ForbiddenError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BatchEnvironments: %v", err)
+			}
+			if len(results) != 1 || results[0].Result != model.BatchResultOK {
+				t.Fatalf("results = %+v, want one ok", results)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+)
+
+const (
+	healthCheckTimeout = 5 * time.Second
+	// defaultRunAgeIntervals is how many intervals may pass without a
+	// successful run before it is reported failing.
+	defaultRunAgeIntervals = 3
+)
+
+type HealthConfig struct {
+	CrawlInterval  string
+	DeleteInterval string
+	// MaxCrawlAge and MaxDeleteAge are how long ago the last successful
+	// run may be. They default to three intervals.
+	MaxCrawlAge  string
+	MaxDeleteAge string
+}
+
+// HealthService checks the components the server depends on. Liveness
+// only covers the process itself, since restarts fix neither outages of
+// the connectors nor failing runs. Readiness depends on the repository,
+// which every request needs, and reports the connectors and the crawler
+// and deleter runs without depending on them.
+type HealthService struct {
+	repo         model.Repository
+	connectors   map[string]model.Connector
+	crawlers     []*Crawler
+	deleter      *Deleter
+	maxCrawlAge  time.Duration
+	maxDeleteAge time.Duration
+	// started stands in for the last success of runs that have not
+	// succeeded yet.
+	started time.Time
+}
+
+func NewHealthService(
+	cfg HealthConfig,
+	repo model.Repository,
+	connectors map[string]model.Connector,
+	crawlers []*Crawler,
+	deleter *Deleter,
+) (*HealthService, error) {
+	maxCrawlAge, err := maxRunAge(cfg.MaxCrawlAge, cfg.CrawlInterval)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing max crawl age: %w", err)
+	}
+
+	maxDeleteAge, err := maxRunAge(cfg.MaxDeleteAge, cfg.DeleteInterval)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing max delete age: %w", err)
+	}
+
+	return &HealthService{
+		repo:         repo,
+		connectors:   connectors,
+		crawlers:     crawlers,
+		deleter:      deleter,
+		maxCrawlAge:  maxCrawlAge,
+		maxDeleteAge: maxDeleteAge,
+		started:      time.Now(),
+	}, nil
+}
+
+func maxRunAge(value, interval string) (time.Duration, error) {
+	if value != "" {
+		return str2duration.ParseDuration(value)
+	}
+
+	d, err := str2duration.ParseDuration(interval)
+	if err != nil {
+		return 0, err
+	}
+
+	return defaultRunAgeIntervals * d, nil
+}
+
+// Liveness reports that the process serves requests.
+func (s *HealthService) Liveness(ctx context.Context) *model.HealthReport {
+	return runHealthChecks(ctx, nil)
+}
+
+// Readiness pings the repository and the enabled connectors and reports
+// how long ago the crawlers and the deleter last succeeded. Only a
+// failing repository fails it.
+func (s *HealthService) Readiness(ctx context.Context) *model.HealthReport {
+	checks := map[string]healthCheck{
+		"repository": {
+			required: true,
+			check: func(ctx context.Context) model.ComponentHealth {
+				return componentHealth(s.repo.Ping(ctx))
+			},
+		},
+		"deleter": {check: func(context.Context) model.ComponentHealth {
+			return s.checkRun(s.deleter.LastSuccess(), s.maxDeleteAge)
+		}},
+	}
+	for name, conn := range s.connectors {
+		checks["connector:"+name] = healthCheck{check: func(
+			ctx context.Context,
+		) model.ComponentHealth {
+			return componentHealth(conn.Ping(ctx))
+		}}
+	}
+	for _, c := range s.crawlers {
+		checks["crawler:"+c.Connector.GetConnectorType()] = healthCheck{
+			check: func(context.Context) model.ComponentHealth {
+				return s.checkRun(c.LastSuccess(), s.maxCrawlAge)
+			},
+		}
+	}
+
+	return runHealthChecks(ctx, checks)
+}
+
+func (s *HealthService) checkRun(
+	lastSuccess time.Time,
+	maxAge time.Duration,
+) model.ComponentHealth {
+	since := lastSuccess
+	if since.IsZero() {
+		since = s.started
+	}
+
+	h := model.ComponentHealth{Status: model.HealthOK, LastSuccess: lastSuccess}
+	if age := time.Since(since); age > maxAge {
+		h.Status = model.HealthFailing
+		h.Error = fmt.Sprintf(
+			"no successful run for %s", age.Truncate(time.Second),
+		)
+	}
+
+	return h
+}
+
+func componentHealth(err error) model.ComponentHealth {
+	if err != nil {
+		return model.ComponentHealth{
+			Status: model.HealthFailing,
+			Error:  err.Error(),
+		}
+	}
+
+	return model.ComponentHealth{Status: model.HealthOK}
+}
+
+// healthCheck checks a single component. A failing component fails the
+// report if it is required and degrades it otherwise.
+type healthCheck struct {
+	check    func(context.Context) model.ComponentHealth
+	required bool
+}
+
+// runHealthChecks runs checks concurrently, each limited to
+// healthCheckTimeout.
+func runHealthChecks(
+	ctx context.Context,
+	checks map[string]healthCheck,
+) *model.HealthReport {
+	report := &model.HealthReport{
+		Status:     model.HealthOK,
+		Components: make(map[string]model.ComponentHealth, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+			h := check.check(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = h
+			switch {
+			case h.Status == model.HealthOK:
+			case check.required:
+				report.Status = model.HealthFailing
+			case report.Status == model.HealthOK:
+				report.Status = model.HealthDegraded
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(sec, 0)
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+)
+
+// maxSlugLength is the length branch slugs are cut to, the same as
+// CI_COMMIT_REF_SLUG in GitLab CI.
+const maxSlugLength = 63
+
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// WebhookConfig holds templates of the fields the environments of a merge
+// request are found by. Name or Labels is required, empty Namespace and Type
+// match any value. Labels executes to a label selector like the label
+// parameter of the environments list.
+type WebhookConfig struct {
+	NameTemplate      string
+	NamespaceTemplate string
+	TypeTemplate      string
+	LabelsTemplate    string
+}
+
+// WebhookService schedules the deletion of review environments when their
+// merge request is merged or closed.
+type WebhookService struct {
+	envs      *EnvironmentService
+	repo      model.EnvRepository
+	name      *template.Template
+	namespace *template.Template
+	envType   *template.Template
+	labels    *template.Template
+}
+
+// mergeRequestData is what the match templates are executed with.
+// BranchSlug is the source branch lowercased with other characters than
+// letters and digits replaced by -, like CI_COMMIT_REF_SLUG in GitLab CI.
+type mergeRequestData struct {
+	Provider    string
+	Project     string
+	ProjectName string
+	Number      int
+	Branch      string
+	BranchSlug  string
+}
+
+func NewWebhookService(
+	cfg WebhookConfig,
+	envs *EnvironmentService,
+	repo model.EnvRepository,
+) (*WebhookService, error) {
+	if cfg.NameTemplate == "" && cfg.LabelsTemplate == "" {
+		return nil, errors.New("name or labels template is required")
+	}
+
+	s := &WebhookService{envs: envs, repo: repo}
+	for _, t := range []struct {
+		name string
+		text string
+		dst  **template.Template
+	}{
+		{"name", cfg.NameTemplate, &s.name},
+		{"namespace", cfg.NamespaceTemplate, &s.namespace},
+		{"type", cfg.TypeTemplate, &s.envType},
+		{"labels", cfg.LabelsTemplate, &s.labels},
+	} {
+		tmpl, err := template.New(t.name).
+			Option("missingkey=error").
+			Parse(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s template: %w", t.name, err)
+		}
+		*t.dst = tmpl
+	}
+
+	return s, nil
+}
+
+// HandleMergeRequest schedules the deletion of the environments of a
+// merged or closed merge request and returns the per environment results.
+// Protected environments are left alone.
+func (s *WebhookService) HandleMergeRequest(
+	ctx context.Context,
+	ev *model.MergeRequestEvent,
+) ([]*model.BatchResult, error) {
+	filter, err := s.environmentFilter(ev)
+	if err != nil {
+		return nil, err
+	}
+
+	envs, err := s.repo.GetEnvironments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(envs) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, len(envs))
+	for i, env := range envs {
+		ids[i] = env.EnvID
+	}
+
+	ctx = model.WithActor(ctx, model.Actor{
+		Name:   fmt.Sprintf("%s:%s!%d", ev.Provider, ev.Project, ev.Number),
+		Source: model.AuditSourceWebhook,
+		Scopes: []string{model.ScopeRead, model.ScopeDelete},
+	})
+
+	return s.envs.BatchEnvironments(ctx, &model.BatchOperation{
+		IDs:    ids,
+		Action: model.BatchActionDeleteNow,
+	})
+}
+
+// environmentFilter executes the match templates for ev.
+func (s *WebhookService) environmentFilter(
+	ev *model.MergeRequestEvent,
+) (*model.EnvironmentFilter, error) {
+	data := mergeRequestData{
+		Provider:    ev.Provider,
+		Project:     ev.Project,
+		ProjectName: path.Base(ev.Project),
+		Number:      ev.Number,
+		Branch:      ev.SourceBranch,
+		BranchSlug:  slugify(ev.SourceBranch),
+	}
+
+	// The name is a glob pattern, so a branch like "*" must not widen it
+	// to other environments. Wildcards of the template itself still work.
+	filter := &model.EnvironmentFilter{}
+	var labels string
+	for _, t := range []struct {
+		tmpl *template.Template
+		data mergeRequestData
+		dst  *string
+	}{
+		{s.name, data.escapeGlob(), &filter.Name},
+		{s.namespace, data, &filter.Namespace},
+		{s.envType, data, &filter.Type},
+		{s.labels, data, &labels},
+	} {
+		var sb strings.Builder
+		if err := t.tmpl.Execute(&sb, t.data); err != nil {
+			return nil, &model.ValidationError{Msg: fmt.Sprintf(
+				"error executing %s template: %v", t.tmpl.Name(), err,
+			)}
+		}
+		*t.dst = sb.String()
+	}
+
+	selector, err := model.ParseLabelSelector(labels)
+	if err != nil {
+		return nil, &model.ValidationError{Msg: fmt.Sprintf(
+			"labels template executed to an invalid selector: %v", err,
+		)}
+	}
+	filter.Labels = selector
+
+	// An empty name without labels would match every environment.
+	if filter.Name == "" && len(filter.Labels) == 0 {
+		return nil, &model.ValidationError{
+			Msg: "name and labels templates executed to empty values",
+		}
+	}
+
+	return filter, nil
+}
+
+// escapeGlob returns d with the fields escaped to match literally in a
+// glob pattern.
+func (d mergeRequestData) escapeGlob() mergeRequestData {
+	d.Provider = model.EscapeGlob(d.Provider)
+	d.Project = model.EscapeGlob(d.Project)
+	d.ProjectName = model.EscapeGlob(d.ProjectName)
+	d.Branch = model.EscapeGlob(d.Branch)
+	d.BranchSlug = model.EscapeGlob(d.BranchSlug)
+	return d
+}
+
+func slugify(branch string) string {
+	slug := slugInvalidChars.ReplaceAllString(strings.ToLower(branch), "-")
+	if len(slug) > maxSlugLength {
+		slug = slug[:maxSlugLength]
+	}
+
+	return strings.Trim(slug, "-")
+}
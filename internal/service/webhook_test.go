@@ -0,0 +1,367 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/internal/storage/sqlite"
+)
+
+func newTestStorage(t *testing.T) *sqlite.Storage {
+	t.Helper()
+
+	st, err := sqlite.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("open storage: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+
+	return st
+}
+
+func newTestWebhookService(
+	t *testing.T,
+	st model.Repository,
+	cfg WebhookConfig,
+) *WebhookService {
+	t.Helper()
+
+	svc, err := NewWebhookService(
+		cfg, NewEnvironmentService(st, nil, nil, ""), st,
+	)
+	if err != nil {
+		t.Fatalf("NewWebhookService: %v", err)
+	}
+	return svc
+}
+
+func testEnvironment(id, name, namespace, envType string) model.Environment {
+	deleteAt := time.Now().Add(24 * time.Hour)
+	return model.Environment{
+		EnvID:       id,
+		Type:        envType,
+		Name:        name,
+		Namespace:   namespace,
+		Owner:       "ivanov",
+		DeleteAt:    deleteAt.Format("02-01-06 15:04:05"),
+		DeleteAtSec: deleteAt.Unix(),
+		TTL:         "1d",
+	}
+}
+
+func TestWebhookHandleMergeRequest(t *testing.T) {
+	st := newTestStorage(t)
+	ctx := context.Background()
+
+	protected := testEnvironment("3", "review-feature-review-apps", "app", "helm")
+	protected.Protected = true
+	protected.ProtectedBy = model.ProtectedByAPI
+	if err := st.WriteEnvironments(ctx, []model.Environment{
+		testEnvironment("1", "review-feature-review-apps", "app", "helm"),
+		testEnvironment("2", "review-feature-review-apps", "api", "helm"),
+		protected,
+		testEnvironment("4", "review-feature-review-apps-2", "app", "helm"),
+		testEnvironment("5", "review-feature-review-apps", "app", "vsphere_vm"),
+	}); err != nil {
+		t.Fatalf("write environments: %v", err)
+	}
+
+	svc := newTestWebhookService(t, st, WebhookConfig{
+		NameTemplate:      "review-{{.BranchSlug}}",
+		NamespaceTemplate: "{{.ProjectName}}",
+		TypeTemplate:      "helm",
+	})
+
+	results, err := svc.HandleMergeRequest(ctx, &model.MergeRequestEvent{
+		Provider:     model.ProviderGitLab,
+		Project:      "team/app",
+		Number:       42,
+		SourceBranch: "Feature/Review_Apps",
+		State:        model.MergeRequestMerged,
+	})
+	if err != nil {
+		t.Fatalf("HandleMergeRequest: %v", err)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].EnvID < results[j].EnvID
+	})
+	if len(results) != 2 ||
+		results[0].EnvID != "1" || results[0].Result != model.BatchResultOK ||
+		results[1].EnvID != "3" || results[1].Result != model.BatchResultFailed {
+		t.Fatalf("results = %+v, want 1 scheduled and protected 3 failed", results)
+	}
+
+	now := time.Now().Unix()
+	for id, due := range map[string]bool{
+		"1": true, "2": false, "3": false, "4": false, "5": false,
+	} {
+		env, err := st.GetEnvByID(ctx, id)
+		if err != nil {
+			t.Fatalf("get environment %s: %v", id, err)
+		}
+		if got := env.DeleteAtSec <= now; got != due {
+			t.Errorf("environment %s due for deletion = %t, want %t", id, got, due)
+		}
+	}
+
+	entries, err := st.GetAuditEntries(ctx, &model.AuditFilter{
+		EnvID:  "1",
+		Action: model.AuditActionDeleteNow,
+	})
+	if err != nil {
+		t.Fatalf("get audit entries: %v", err)
+	}
+	if len(entries) != 1 ||
+		entries[0].Actor != "gitlab:team/app!42" ||
+		entries[0].Source != model.AuditSourceWebhook {
+		t.Errorf("audit entries = %+v, want one by gitlab:team/app!42", entries)
+	}
+}
+
+func TestWebhookNameTemplates(t *testing.T) {
+	st := newTestStorage(t)
+	ctx := context.Background()
+
+	if err := st.WriteEnvironments(ctx, []model.Environment{
+		testEnvironment("1", "app-7", "review", "helm"),
+		testEnvironment("2", "app-feature-x", "review", "helm"),
+		testEnvironment("3", "gitea-org-app", "review", "helm"),
+		testEnvironment("4", "review-"+strings.Repeat("a", 62), "review", "helm"),
+	}); err != nil {
+		t.Fatalf("write environments: %v", err)
+	}
+
+	ev := &model.MergeRequestEvent{
+		Provider:     model.ProviderGitea,
+		Project:      "org/app",
+		Number:       7,
+		SourceBranch: "Feature/X",
+		State:        model.MergeRequestClosed,
+	}
+
+	for _, tc := range []struct {
+		name   string
+		tmpl   string
+		branch string
+		want   string
+	}{
+		{"number", "{{.ProjectName}}-{{.Number}}", "", "1"},
+		{"branch slug", "{{.ProjectName}}-{{.BranchSlug}}", "", "2"},
+		{"provider and project name", "{{.Provider}}-org-{{.ProjectName}}", "", "3"},
+		// The slug is cut to 63 characters, which would end with a dash.
+		{"long branch slug", "review-{{.BranchSlug}}",
+			strings.Repeat("A", 62) + "/B", "4"},
+		{"no match", "review-{{.Branch}}", "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := newTestWebhookService(t, st, WebhookConfig{
+				NameTemplate: tc.tmpl,
+			})
+
+			ev := *ev
+			if tc.branch != "" {
+				ev.SourceBranch = tc.branch
+			}
+			results, err := svc.HandleMergeRequest(ctx, &ev)
+			if err != nil {
+				t.Fatalf("HandleMergeRequest: %v", err)
+			}
+
+			var got string
+			if len(results) > 1 {
+				t.Fatalf("results = %+v, want at most one", results)
+			}
+			if len(results) == 1 {
+				got = results[0].EnvID
+			}
+			if got != tc.want {
+				t.Errorf("matched %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWebhookNameTemplateEscapesBranch(t *testing.T) {
+	st := newTestStorage(t)
+	ctx := context.Background()
+
+	if err := st.WriteEnvironments(ctx, []model.Environment{
+		testEnvironment("1", "review-feature", "review", "helm"),
+		testEnvironment("2", "review-foo", "review", "helm"),
+		testEnvironment("3", "review-a", "review", "helm"),
+		testEnvironment("4", "review-*", "review", "helm"),
+		testEnvironment("5", "review-[ab]", "review", "helm"),
+		testEnvironment("6", "review-100%", "review", "helm"),
+	}); err != nil {
+		t.Fatalf("write environments: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		tmpl   string
+		branch string
+		want   []string
+	}{
+		{"star", "review-{{.Branch}}", "*", []string{"4"}},
+		{"question mark", "review-{{.Branch}}", "f?o", nil},
+		{"character class", "review-{{.Branch}}", "[ab]", []string{"5"}},
+		{"percent", "review-{{.Branch}}", "1%", nil},
+		{"percent literal", "review-{{.Branch}}", "100%", []string{"6"}},
+		{"project name", "{{.ProjectName}}-feature", "", nil},
+		{"template wildcard", "review-{{.Branch}}*", "f", []string{"1", "2"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := newTestWebhookService(t, st, WebhookConfig{
+				NameTemplate: tc.tmpl,
+			})
+
+			// The merge request is not deleted, so that every case sees
+			// every environment.
+			filter, err := svc.environmentFilter(&model.MergeRequestEvent{
+				Provider:     model.ProviderGitHub,
+				Project:      "team/revie?",
+				Number:       1,
+				SourceBranch: tc.branch,
+				State:        model.MergeRequestMerged,
+			})
+			if err != nil {
+				t.Fatalf("environmentFilter: %v", err)
+			}
+			envs, err := st.GetEnvironments(ctx, filter)
+			if err != nil {
+				t.Fatalf("GetEnvironments: %v", err)
+			}
+
+			got := make([]string, 0, len(envs))
+			for _, env := range envs {
+				got = append(got, env.EnvID)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("matched %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWebhookTemplateErrors(t *testing.T) {
+	st := newTestStorage(t)
+	ev := &model.MergeRequestEvent{
+		Provider:     model.ProviderGitHub,
+		Project:      "team/app",
+		Number:       1,
+		SourceBranch: "---",
+		State:        model.MergeRequestMerged,
+	}
+
+	for _, tc := range []struct {
+		name   string
+		tmpl   string
+		labels string
+	}{
+		{"unknown field", "{{.Title}}", ""},
+		{"empty name", "{{.BranchSlug}}", ""},
+		{"empty name and labels", "{{.BranchSlug}}", "{{.BranchSlug}}"},
+		{"invalid labels", "", "review={{.Branch}}"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := newTestWebhookService(t, st, WebhookConfig{
+				NameTemplate:   tc.tmpl,
+				LabelsTemplate: tc.labels,
+			})
+
+			_, err := svc.HandleMergeRequest(context.Background(), ev)
+			var verr *model.ValidationError
+			if !errors.As(err, &verr) {
+				t.Errorf("error = %v, want a validation error", err)
+			}
+		})
+	}
+
+	if _, err := NewWebhookService(WebhookConfig{}, nil, st); err == nil {
+		t.Error("NewWebhookService without name and labels templates succeeded")
+	}
+}
+
+func TestWebhookLabelTemplates(t *testing.T) {
+	st := newTestStorage(t)
+	ctx := context.Background()
+
+	labeled := func(id, name string, labels map[string]string) model.Environment {
+		env := testEnvironment(id, name, "review", "helm")
+		env.Labels = labels
+		return env
+	}
+	if err := st.WriteEnvironments(ctx, []model.Environment{
+		labeled("1", "web", map[string]string{"review": "feature-x", "project": "app"}),
+		labeled("2", "api", map[string]string{"review": "feature-x", "project": "app"}),
+		labeled("3", "web", map[string]string{"review": "feature-x", "project": "other"}),
+		labeled("4", "web", map[string]string{"review": "feature-y", "project": "app"}),
+		testEnvironment("5", "web", "review", "helm"),
+	}); err != nil {
+		t.Fatalf("write environments: %v", err)
+	}
+
+	ev := &model.MergeRequestEvent{
+		Provider:     model.ProviderGitLab,
+		Project:      "team/app",
+		Number:       7,
+		SourceBranch: "Feature/X",
+		State:        model.MergeRequestMerged,
+	}
+
+	for _, tc := range []struct {
+		name   string
+		tmpl   string
+		labels string
+		want   []string
+	}{
+		{"label", "", "review={{.BranchSlug}}", []string{"1", "2", "3"}},
+		{"labels", "", "review={{.BranchSlug}},project={{.ProjectName}}",
+			[]string{"1", "2"}},
+		{"label and name", "web", "review={{.BranchSlug}},project={{.ProjectName}}",
+			[]string{"1"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := newTestWebhookService(t, st, WebhookConfig{
+				NameTemplate:   tc.tmpl,
+				LabelsTemplate: tc.labels,
+			})
+
+			results, err := svc.HandleMergeRequest(ctx, ev)
+			if err != nil {
+				t.Fatalf("HandleMergeRequest: %v", err)
+			}
+
+			got := make([]string, 0, len(results))
+			for _, res := range results {
+				got = append(got, res.EnvID)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("matched %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	for branch, want := range map[string]string{
+		"main":                   "main",
+		"Feature/Review_Apps":    "feature-review-apps",
+		"fix//double--dash":      "fix-double-dash",
+		"-leading-and-trailing-": "leading-and-trailing",
+		"release/1.2.3":          "release-1-2-3",
+		"ünïcode":                "n-code",
+	} {
+		if got := slugify(branch); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", branch, got, want)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported dialects. Each dialect has its own directory of migration
+// files named <version>_<name>.sql.
+const (
+	SQLite     = "sqlite"
+	PostgreSQL = "postgresql"
+)
+
+// lockKey identifies the PostgreSQL advisory lock held while migrations
+// are applied.
+const lockKey = 7294165030
+
+const createSchemaMigrationsQuery = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at INT NOT NULL
+);`
+
+// addColumnIfNotExists matches the ALTER TABLE ... ADD COLUMN IF NOT EXISTS
+// statements SQLite lacks, capturing the table, the column and the rest of
+// the column definition.
+var addColumnIfNotExists = regexp.MustCompile(
+	`(?is)ALTER\s+TABLE\s+(\w+)\s+ADD\s+COLUMN\s+IF\s+NOT\s+EXISTS\s+(\w+)([^;]*);`,
+)
+
+//go:embed sqlite/*.sql postgresql/*.sql
+var files embed.FS
+
+// querier is implemented by *sql.DB and *sql.Conn.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := load(dialect)
+	if err != nil {
+		return nil, fmt.Errorf("error loading migrations: %w", err)
+	}
+
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+	}, nil
+}
+
+func load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("unknown dialect %q: %w", dialect, err)
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	seen := make(map[int]string, len(entries))
+	for _, e := range entries {
+		base := strings.TrimSuffix(e.Name(), ".sql")
+		versionStr, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", e.Name())
+		}
+
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", e.Name())
+		}
+
+		if prev, ok := seen[version]; ok {
+			return nil, fmt.Errorf(
+				"duplicate migration version %d: %s, %s",
+				version, prev, e.Name(),
+			)
+		}
+		seen[version] = e.Name()
+
+		body, err := fs.ReadFile(files, path.Join(dialect, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    name,
+			SQL:     string(body),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Status returns every known migration along with whether it has been
+// applied to the database. It does not change the database.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		st := Status{Migration: mg}
+		if at, ok := applied[mg.Version]; ok {
+			st.Applied = true
+			st.AppliedAt = time.Unix(at, 0)
+		}
+		result = append(result, st)
+	}
+
+	return result, nil
+}
+
+// Pending returns the migrations that have not been applied yet, in the
+// order they would be applied. It does not change the database.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	return m.pending(ctx, m.db)
+}
+
+func (m *Migrator) pending(ctx context.Context, q querier) ([]Migration, error) {
+	applied, err := m.applied(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, mg := range m.migrations {
+		if _, ok := applied[mg.Version]; !ok {
+			pending = append(pending, mg)
+		}
+	}
+
+	return pending, nil
+}
+
+// Up applies all pending migrations, each in its own transaction, and
+// returns the applied ones. On PostgreSQL it holds an advisory lock while
+// doing so, so that replicas starting at the same time apply each
+// migration once: the others wait and then find nothing pending.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	unlock, err := m.lock(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("error locking schema migrations: %w", err)
+	}
+	defer unlock()
+
+	if _, err := conn.ExecContext(ctx, createSchemaMigrationsQuery); err != nil {
+		return nil, fmt.Errorf("error creating schema_migrations: %w", err)
+	}
+
+	pending, err := m.pending(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make([]Migration, 0, len(pending))
+	for _, mg := range pending {
+		if err := m.apply(ctx, conn, mg); err != nil {
+			return done, fmt.Errorf("error applying migration %s: %w", mg, err)
+		}
+		done = append(done, mg)
+	}
+
+	return done, nil
+}
+
+// lock takes the migration advisory lock on PostgreSQL and returns the
+// function releasing it. SQLite serializes writers itself.
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	if m.dialect != PostgreSQL {
+		return func() {}, nil
+	}
+
+	if _, err := conn.ExecContext(
+		ctx, `SELECT pg_advisory_lock($1);`, lockKey,
+	); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_, _ = conn.ExecContext(
+			context.Background(), `SELECT pg_advisory_unlock($1);`, lockKey,
+		)
+	}, nil
+}
+
+func (m *Migrator) apply(
+	ctx context.Context,
+	conn *sql.Conn,
+	mg Migration,
+) (err error) {
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("%w, tx rollback error: %w", err, rbErr)
+			}
+		}
+	}()
+
+	query := mg.SQL
+	if m.dialect == SQLite {
+		query, err = addMissingColumns(ctx, tx, query)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	q := `INSERT INTO schema_migrations (version, name, applied_at)
+		VALUES ($1, $2, $3);`
+	if _, err := tx.ExecContext(
+		ctx, q, mg.Version, mg.Name, time.Now().Unix(),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// addMissingColumns emulates ALTER TABLE ... ADD COLUMN IF NOT EXISTS on
+// SQLite: statements adding a column the table already has are dropped,
+// the others lose the IF NOT EXISTS clause.
+func addMissingColumns(
+	ctx context.Context,
+	tx *sql.Tx,
+	query string,
+) (string, error) {
+	var err error
+	result := addColumnIfNotExists.ReplaceAllStringFunc(query, func(stmt string) string {
+		if err != nil {
+			return stmt
+		}
+
+		sub := addColumnIfNotExists.FindStringSubmatch(stmt)
+		table, column, definition := sub[1], sub[2], sub[3]
+
+		var n int
+		err = tx.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM pragma_table_info($1) WHERE name = $2;`,
+			table, column,
+		).Scan(&n)
+		if err != nil || n > 0 {
+			return ""
+		}
+
+		return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s%s;", table, column, definition)
+	})
+	if err != nil {
+		return "", fmt.Errorf("error reading table columns: %w", err)
+	}
+
+	return result, nil
+}
+
+// applied returns applied migration versions mapped to the unix time they
+// were applied at. A database without schema_migrations has none applied.
+// It fails if the database has migrations this binary does not know about,
+// i.e. it was upgraded by a newer env-cleaner.
+func (m *Migrator) applied(
+	ctx context.Context,
+	q querier,
+) (map[int]int64, error) {
+	exists, err := m.tableExists(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %w", err)
+	}
+	if !exists {
+		return map[int]int64{}, nil
+	}
+
+	rows, err := q.QueryContext(
+		ctx, `SELECT version, applied_at FROM schema_migrations;`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	known := make(map[int]struct{}, len(m.migrations))
+	for _, mg := range m.migrations {
+		known[mg.Version] = struct{}{}
+	}
+
+	applied := make(map[int]int64)
+	for rows.Next() {
+		var version int
+		var at int64
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("error reading schema_migrations: %w", err)
+		}
+
+		if _, ok := known[version]; !ok {
+			return nil, fmt.Errorf(
+				"database schema version %d is newer than this env-cleaner",
+				version,
+			)
+		}
+		applied[version] = at
+	}
+
+	return applied, rows.Err()
+}
+
+// tableExists reports whether the schema_migrations table exists.
+func (m *Migrator) tableExists(ctx context.Context, q querier) (bool, error) {
+	query := `SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name = 'schema_migrations';`
+	if m.dialect == PostgreSQL {
+		query = `SELECT COUNT(*) FROM information_schema.tables
+			WHERE table_schema = current_schema()
+				AND table_name = 'schema_migrations';`
+	}
+
+	var n int
+	if err := q.QueryRowContext(ctx, query).Scan(&n); err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
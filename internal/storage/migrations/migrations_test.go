@@ -0,0 +1,119 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "env.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+func TestUpSQLite(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{
+			name: "empty database",
+		},
+		{
+			name: "schema without versioned migrations",
+			schema: `
+			CREATE TABLE environments (
+					env_id TEXT PRIMARY KEY,
+					type TEXT NOT NULL,
+					name TEXT NOT NULL,
+					namespace TEXT NOT NULL,
+					owner TEXT NOT NULL,
+					delete_at TEXT NOT NULL,
+					delete_at_sec INT NOT NULL
+			);
+			CREATE TABLE tokens (
+					env_id TEXT PRIMARY KEY,
+					token TEXT NOT NULL
+			);`,
+		},
+		{
+			name: "columns added before versioned migrations",
+			schema: `
+			CREATE TABLE environments (
+					env_id TEXT PRIMARY KEY,
+					type TEXT NOT NULL,
+					name TEXT NOT NULL,
+					namespace TEXT NOT NULL,
+					owner TEXT NOT NULL,
+					delete_at TEXT NOT NULL,
+					delete_at_sec INT NOT NULL
+			);
+			CREATE TABLE tokens (
+					env_id TEXT PRIMARY KEY,
+					token TEXT NOT NULL
+			);
+			ALTER TABLE environments ADD COLUMN ttl TEXT NOT NULL DEFAULT '';
+			ALTER TABLE environments ADD COLUMN status TEXT NOT NULL DEFAULT 'active';`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newTestDB(t)
+
+			if tt.schema != "" {
+				if _, err := db.Exec(tt.schema); err != nil {
+					t.Fatalf("create schema: %v", err)
+				}
+			}
+
+			m, err := New(db, SQLite)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			applied, err := m.Up(ctx)
+			if err != nil {
+				t.Fatalf("Up: %v", err)
+			}
+			if len(applied) != len(m.migrations) {
+				t.Errorf("applied %d migrations, want %d",
+					len(applied), len(m.migrations))
+			}
+
+			pending, err := m.Pending(ctx)
+			if err != nil {
+				t.Fatalf("Pending: %v", err)
+			}
+			if len(pending) != 0 {
+				t.Errorf("pending after Up: %v", pending)
+			}
+
+			for _, column := range []string{
+				"ttl", "delete_at_source", "status", "status_changed_at",
+			} {
+				var n int
+				if err := db.QueryRow(
+					`SELECT COUNT(*) FROM pragma_table_info('environments')
+						WHERE name = $1;`, column,
+				).Scan(&n); err != nil {
+					t.Fatalf("read columns: %v", err)
+				}
+				if n != 1 {
+					t.Errorf("column %s: found %d, want 1", column, n)
+				}
+			}
+		})
+	}
+}
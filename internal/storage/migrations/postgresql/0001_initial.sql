@@ -0,0 +1,16 @@
+-- Schema created by env-cleaner releases without versioned migrations.
+-- Every statement is idempotent, so existing databases are adopted as is.
+CREATE TABLE IF NOT EXISTS environments (
+    env_id TEXT PRIMARY KEY,
+    type TEXT NOT NULL,
+    name TEXT NOT NULL,
+    namespace TEXT NOT NULL,
+    owner TEXT NOT NULL,
+    delete_at TEXT NOT NULL,
+    delete_at_sec INT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS tokens (
+    env_id TEXT PRIMARY KEY,
+    token TEXT NOT NULL
+);
@@ -0,0 +1,7 @@
+-- Columns added to environments after the initial schema. Releases
+-- before versioned migrations added them on start, so they may exist.
+ALTER TABLE environments
+    ADD COLUMN IF NOT EXISTS ttl TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS delete_at_source TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS status_changed_at INT NOT NULL DEFAULT 0;
@@ -0,0 +1,17 @@
+-- Persistent trail of environment lifecycle actions.
+CREATE TABLE audit_log (
+    id SERIAL PRIMARY KEY,
+    created_at INT NOT NULL,
+    env_id TEXT NOT NULL,
+    env_type TEXT NOT NULL,
+    env_name TEXT NOT NULL,
+    action TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    source TEXT NOT NULL,
+    old_delete_at TEXT NOT NULL DEFAULT '',
+    new_delete_at TEXT NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX audit_log_env_id_idx ON audit_log (env_id);
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
@@ -0,0 +1,4 @@
+-- Track failed deletion attempts for retry backoff.
+ALTER TABLE environments ADD COLUMN failure_count INT NOT NULL DEFAULT 0;
+ALTER TABLE environments ADD COLUMN last_error TEXT NOT NULL DEFAULT '';
+ALTER TABLE environments ADD COLUMN next_attempt_at INT NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+-- Runtime protection from deletion.
+ALTER TABLE environments ADD COLUMN protected BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE environments ADD COLUMN protected_until INT NOT NULL DEFAULT 0;
+ALTER TABLE environments ADD COLUMN protected_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE environments ADD COLUMN protected_by TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,10 @@
+-- Named API keys. Keys are stored as SHA-256 hashes, scopes as a comma
+-- separated list.
+CREATE TABLE api_keys (
+    name TEXT PRIMARY KEY,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    created_at INT NOT NULL,
+    expires_at INT NOT NULL DEFAULT 0,
+    revoked_at INT NOT NULL DEFAULT 0
+);
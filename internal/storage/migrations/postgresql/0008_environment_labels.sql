@@ -0,0 +1,3 @@
+-- Environment labels, stored as comma separated key=value pairs sorted by
+-- key.
+ALTER TABLE environments ADD COLUMN labels TEXT NOT NULL DEFAULT '';
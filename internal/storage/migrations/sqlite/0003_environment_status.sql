@@ -0,0 +1,2 @@
+-- Index environments by lifecycle status.
+CREATE INDEX environments_status_idx ON environments (status);
@@ -0,0 +1,142 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+)
+
+const apiKeySelectQuery = `SELECT name, key_hash, scopes, created_at,
+		expires_at, revoked_at
+	FROM api_keys`
+
+func (s *Storage) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	return s.executeTransaction(ctx, func(tx *sql.Tx) error {
+		var n int
+		if err := tx.QueryRowContext(
+			ctx, `SELECT COUNT(*) FROM api_keys WHERE name = $1;`, key.Name,
+		).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			return &model.ConflictError{
+				Msg: fmt.Sprintf("api key %s already exists", key.Name),
+			}
+		}
+
+		q := `INSERT INTO api_keys (
+				name,
+				key_hash,
+				scopes,
+				created_at,
+				expires_at
+			) VALUES ($1, $2, $3, $4, $5);`
+
+		if _, err := tx.ExecContext(
+			ctx, q,
+			key.Name,
+			key.KeyHash,
+			strings.Join(key.Scopes, ","),
+			key.CreatedAt,
+			key.ExpiresAt,
+		); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *Storage) GetAPIKeyByHash(
+	ctx context.Context,
+	hash string,
+) (*model.APIKey, error) {
+	row := s.DB.QueryRowContext(
+		ctx, apiKeySelectQuery+` WHERE key_hash = $1;`, hash,
+	)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &model.NotFoundError{Msg: "api key not found"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get api key error: %w", err)
+	}
+
+	return key, nil
+}
+
+func (s *Storage) GetAPIKeys(ctx context.Context) ([]*model.APIKey, error) {
+	rows, err := s.DB.QueryContext(ctx, apiKeySelectQuery+` ORDER BY name;`)
+	if err != nil {
+		return nil, fmt.Errorf("get api keys error: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var keys []*model.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get api keys error: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (s *Storage) RevokeAPIKey(
+	ctx context.Context,
+	name string,
+	revokedAt int64,
+) error {
+	return s.executeTransaction(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(
+			ctx,
+			`UPDATE api_keys SET revoked_at = $1
+			WHERE name = $2 AND revoked_at = 0;`,
+			revokedAt, name,
+		)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return &model.NotFoundError{
+				Msg: fmt.Sprintf("active api key %s not found", name),
+			}
+		}
+
+		return nil
+	})
+}
+
+func scanAPIKey(row rowScanner) (*model.APIKey, error) {
+	var key model.APIKey
+	var scopes string
+	if err := row.Scan(
+		&key.Name,
+		&key.KeyHash,
+		&scopes,
+		&key.CreatedAt,
+		&key.ExpiresAt,
+		&key.RevokedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+
+	return &key, nil
+}
@@ -0,0 +1,25 @@
+package postgresql
+
+import (
+	"testing"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+)
+
+func TestGlobToLike(t *testing.T) {
+	for glob, want := range map[string]string{
+		"review-*":                      `review-%`,
+		"review-?":                      `review-_`,
+		"100%_done":                     `100\%\_done`,
+		`back\slash`:                    `back\\slash`,
+		model.EscapeGlob("review-*"):    `review-*`,
+		model.EscapeGlob("f?o"):         `f?o`,
+		model.EscapeGlob("[ab]"):        `[ab]`,
+		model.EscapeGlob("[*]") + "*":   `[*]%`,
+		model.EscapeGlob("100%") + "-?": `100\%-_`,
+	} {
+		if got := globToLike(glob); got != want {
+			t.Errorf("globToLike(%q) = %q, want %q", glob, got, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+)
+
+func (s *Storage) WriteAuditEntry(
+	ctx context.Context,
+	entry *model.AuditEntry,
+) error {
+	if entry.CreatedAt == 0 {
+		entry.CreatedAt = time.Now().Unix()
+	}
+
+	return s.executeTransaction(ctx, func(tx *sql.Tx) error {
+		q := `INSERT INTO audit_log (
+				created_at,
+				env_id,
+				env_type,
+				env_name,
+				action,
+				actor,
+				source,
+				old_delete_at,
+				new_delete_at,
+				details
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);`
+
+		if _, err := tx.ExecContext(
+			ctx, q,
+			entry.CreatedAt,
+			entry.EnvID,
+			entry.EnvType,
+			entry.EnvName,
+			entry.Action,
+			entry.Actor,
+			entry.Source,
+			entry.OldDeleteAt,
+			entry.NewDeleteAt,
+			entry.Details,
+		); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *Storage) GetAuditEntries(
+	ctx context.Context,
+	filter *model.AuditFilter,
+) ([]*model.AuditEntry, error) {
+	if filter == nil {
+		filter = &model.AuditFilter{}
+	}
+
+	var args []any
+	var where []string
+	addCond := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.EnvID != "" {
+		addCond("env_id = $%d", filter.EnvID)
+	}
+	if filter.Action != "" {
+		addCond("action = $%d", filter.Action)
+	}
+	if filter.Actor != "" {
+		addCond("actor = $%d", filter.Actor)
+	}
+	if filter.Source != "" {
+		addCond("source = $%d", filter.Source)
+	}
+	if filter.Owner != "" {
+		addCond(
+			"env_id IN (SELECT env_id FROM environments WHERE owner = $%d)",
+			filter.Owner,
+		)
+	}
+	if filter.Since != 0 {
+		addCond("created_at >= $%d", filter.Since)
+	}
+	if filter.Until != 0 {
+		addCond("created_at <= $%d", filter.Until)
+	}
+
+	q := `SELECT id, created_at, env_id, env_type, env_name, action, actor,
+			source, old_delete_at, new_delete_at, details
+		FROM audit_log`
+	if len(where) > 0 {
+		q += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	q += ` ORDER BY created_at DESC, id DESC`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		q += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+
+	rows, err := s.DB.QueryContext(ctx, q+`;`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("get audit entries error: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []*model.AuditEntry
+	for rows.Next() {
+		var e model.AuditEntry
+		if err := rows.Scan(
+			&e.ID,
+			&e.CreatedAt,
+			&e.EnvID,
+			&e.EnvType,
+			&e.EnvName,
+			&e.Action,
+			&e.Actor,
+			&e.Source,
+			&e.OldDeleteAt,
+			&e.NewDeleteAt,
+			&e.Details,
+		); err != nil {
+			return nil, fmt.Errorf("get audit entries error: %w", err)
+		}
+		entries = append(entries, &e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get audit entries error: %w", err)
+	}
+
+	return entries, nil
+}
@@ -3,14 +3,20 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"log/slog"
 
 	_ "github.com/mattn/go-sqlite3" // Import go-sqlite3 library
 
 	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/internal/storage/migrations"
 	"github.com/fragpit/env-cleaner/pkg/utils"
 )
 
@@ -18,9 +24,59 @@ type Storage struct {
 	DB *sql.DB
 }
 
+const envSelectQuery = `SELECT e.env_id, e.type, e.name, e.namespace, e.owner,
+		e.delete_at, e.delete_at_sec, e.ttl, e.delete_at_source,
+		e.status, e.status_changed_at,
+		e.failure_count, e.last_error, e.next_attempt_at,
+		e.protected, e.protected_until, e.protected_reason, e.protected_by,
+		e.labels
+	FROM environments e`
+
+// rearmWarningSet returns SET clauses that return a warned environment to
+// active when its delete_at changes, so that a new stale warning is sent.
+// The arguments are the placeholder numbers of the new delete_at_sec and
+// the current unix time.
+func rearmWarningSet(deleteAtSecArg, nowArg int) string {
+	return fmt.Sprintf(`
+				status = CASE WHEN status = 'warned' AND delete_at_sec <> $%[1]d
+					THEN 'active' ELSE status END,
+				status_changed_at = CASE WHEN status = 'warned' AND delete_at_sec <> $%[1]d
+					THEN $%[2]d ELSE status_changed_at END`,
+		deleteAtSecArg, nowArg,
+	)
+}
+
+// envSortColumns maps environment sort fields to columns.
+var envSortColumns = map[string]string{
+	model.SortByEnvID:     "e.env_id",
+	model.SortByName:      "e.name",
+	model.SortByOwner:     "e.owner",
+	model.SortByType:      "e.type",
+	model.SortByNamespace: "e.namespace",
+	model.SortByDeleteAt:  "e.delete_at_sec",
+}
+
 var _ model.Repository = (*Storage)(nil)
 
-func New(dbFolder string) (_ *Storage, err error) {
+// New opens the database, creating it if it does not exist, and applies
+// pending schema migrations.
+func New(dbFolder string) (*Storage, error) {
+	s, err := Create(dbFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.migrate(); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Create opens the database, creating it and its folder if they do not
+// exist, without touching the schema.
+func Create(dbFolder string) (_ *Storage, err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("storage init error: %w", err)
@@ -44,55 +100,45 @@ func New(dbFolder string) (_ *Storage, err error) {
 		_ = file.Close()
 	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := connect(dbPath)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, err
-	}
-
 	_, err = db.Exec("PRAGMA journal_mode=WAL")
 	if err != nil {
+		_ = db.Close()
 		return nil, err
 	}
 
-	tx, err := db.Begin()
-	if err != nil {
-		return nil, err
-	}
+	return &Storage{
+		DB: db,
+	}, nil
+}
 
+// Open opens an existing database without touching the schema. Unlike
+// Create it fails if the database does not exist.
+func Open(dbFolder string) (_ *Storage, err error) {
 	defer func() {
 		if err != nil {
-			if rbErr := tx.Rollback(); rbErr != nil {
-				err = fmt.Errorf("%w, tx rollback error: %w", err, rbErr)
-			}
+			err = fmt.Errorf("storage init error: %w", err)
 		}
 	}()
 
-	dbCreateQuery := `
-	CREATE TABLE IF NOT EXISTS environments (
-			env_id TEXT PRIMARY KEY,
-			type TEXT NOT NULL,
-			name TEXT NOT NULL,
-			namespace TEXT NOT NULL,
-			owner TEXT NOT NULL,
-			delete_at TEXT NOT NULL,
-			delete_at_sec INT NOT NULL
-	);
-
-	CREATE TABLE IF NOT EXISTS tokens (
-			env_id TEXT PRIMARY KEY,
-			token TEXT NOT NULL
-	);
-	`
-
-	if _, err = tx.Exec(dbCreateQuery); err != nil {
+	if dbFolder == "" {
+		return nil, fmt.Errorf("db folder variable is empty")
+	}
+
+	dbPath := dbFolder + "/" + "env.db"
+	if _, err := os.Stat(dbPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("database %s does not exist", dbPath)
+		}
 		return nil, err
 	}
 
-	if err := tx.Commit(); err != nil {
+	db, err := connect(dbPath)
+	if err != nil {
 		return nil, err
 	}
 
@@ -101,6 +147,42 @@ func New(dbFolder string) (_ *Storage, err error) {
 	}, nil
 }
 
+func connect(dbPath string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Migrator returns the schema migrator for the database.
+func (s *Storage) Migrator() (*migrations.Migrator, error) {
+	return migrations.New(s.DB, migrations.SQLite)
+}
+
+func (s *Storage) migrate() error {
+	m, err := s.Migrator()
+	if err != nil {
+		return fmt.Errorf("storage init error: %w", err)
+	}
+
+	applied, err := m.Up(context.Background())
+	for _, mg := range applied {
+		slog.Info("applied schema migration", slog.String("migration", mg.String()))
+	}
+	if err != nil {
+		return fmt.Errorf("storage init error: %w", err)
+	}
+
+	return nil
+}
+
 func (s *Storage) WriteEnvironments(
 	ctx context.Context,
 	envs []model.Environment,
@@ -113,8 +195,18 @@ func (s *Storage) WriteEnvironments(
 					namespace,
 					owner,
 					delete_at,
-					delete_at_sec
-			) VALUES ($1, $2, $3, $4, $5, $6, $7);`
+					delete_at_sec,
+					ttl,
+					delete_at_source,
+					status,
+					status_changed_at,
+					protected,
+					protected_until,
+					protected_reason,
+					protected_by,
+					labels
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11,
+				$12, $13, $14, $15, $16);`
 
 		stmt, err := tx.Prepare(q)
 		if err != nil {
@@ -122,9 +214,31 @@ func (s *Storage) WriteEnvironments(
 		}
 		defer func() { _ = stmt.Close() }()
 
+		now := time.Now().Unix()
 		for _, e := range envs {
-			if env, _ := s.GetEnvByID(ctx, e.EnvID); env != nil {
+			// Deleted environments are kept for a while for history. One
+			// that shows up again is tracked from scratch.
+			var stored string
+			err := tx.QueryRowContext(
+				ctx, `SELECT status FROM environments WHERE env_id = $1;`, e.EnvID,
+			).Scan(&stored)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+			case err != nil:
+				return fmt.Errorf("error reading environment %s: %w", e.EnvID, err)
+			case stored != model.StatusDeleted:
 				continue
+			default:
+				if _, err := tx.ExecContext(
+					ctx, `DELETE FROM environments WHERE env_id = $1;`, e.EnvID,
+				); err != nil {
+					return err
+				}
+			}
+
+			status := e.Status
+			if status == "" {
+				status = model.StatusActive
 			}
 
 			slog.Info("new environment added",
@@ -141,6 +255,15 @@ func (s *Storage) WriteEnvironments(
 				e.Owner,
 				e.DeleteAt,
 				e.DeleteAtSec,
+				e.TTL,
+				e.DeleteAtSource,
+				status,
+				now,
+				e.Protected,
+				e.ProtectedUntil,
+				e.ProtectedReason,
+				e.ProtectedBy,
+				model.FormatLabels(e.Labels),
 			); err != nil {
 				return err
 			}
@@ -152,46 +275,144 @@ func (s *Storage) WriteEnvironments(
 
 func (s *Storage) GetEnvironments(
 	ctx context.Context,
+	filter *model.EnvironmentFilter,
+) ([]*model.Environment, error) {
+	if filter == nil {
+		filter = &model.EnvironmentFilter{}
+	}
+
+	var args []any
+	var where []string
+	addCond := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if len(filter.Statuses) > 0 {
+		where = append(where, fmt.Sprintf(
+			"e.status IN (%s)", placeholders(len(args)+1, len(filter.Statuses)),
+		))
+		for _, st := range filter.Statuses {
+			args = append(args, st)
+		}
+	} else {
+		addCond("e.status <> $%d", model.StatusDeleted)
+	}
+	if filter.Owner != "" {
+		addCond("e.owner = $%d", filter.Owner)
+	}
+	if filter.Type != "" {
+		addCond("e.type = $%d", filter.Type)
+	}
+	if filter.Namespace != "" {
+		addCond("e.namespace = $%d", filter.Namespace)
+	}
+	if filter.Name != "" {
+		addCond("e.name GLOB $%d", filter.Name)
+	}
+	// Stored labels are sorted key=value pairs separated by commas. Keys
+	// and values cannot contain commas or equal signs, so a label is
+	// matched as a substring of the commas-wrapped column.
+	for _, key := range slices.Sorted(maps.Keys(filter.Labels)) {
+		term := "," + key + "="
+		if value := filter.Labels[key]; value != "" {
+			term += value + ","
+		}
+		addCond("instr(',' || e.labels || ',', $%d) > 0", term)
+	}
+	if filter.DeleteBefore != 0 {
+		addCond("e.delete_at_sec < $%d", filter.DeleteBefore)
+	}
+	if filter.DeleteAfter != 0 {
+		addCond("e.delete_at_sec > $%d", filter.DeleteAfter)
+	}
+
+	field, desc := model.ParseSort(filter.Sort)
+	column, ok := envSortColumns[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort field: %s", field)
+	}
+	cmp, dir := ">", "ASC"
+	if desc {
+		cmp, dir = "<", "DESC"
+	}
+
+	if filter.After != nil {
+		if field == model.SortByEnvID {
+			addCond("e.env_id "+cmp+" $%d", filter.After.EnvID)
+		} else {
+			args = append(args, filter.After.Key, filter.After.EnvID)
+			where = append(where, fmt.Sprintf(
+				"(%[1]s %[2]s $%[3]d OR (%[1]s = $%[3]d AND e.env_id %[2]s $%[4]d))",
+				column, cmp, len(args)-1, len(args),
+			))
+		}
+	}
+
+	q := envSelectQuery + ` WHERE ` + strings.Join(where, " AND ")
+	q += fmt.Sprintf(` ORDER BY %s %s`, column, dir)
+	if field != model.SortByEnvID {
+		q += `, e.env_id ` + dir
+	}
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		q += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+
+	return s.getEnvironments(ctx, q+`;`, args...)
+}
+
+func (s *Storage) GetEnvironmentsByType(
+	ctx context.Context,
+	envType string,
 ) ([]*model.Environment, error) {
-	q := `SELECT * FROM environments;`
-	return s.getEnvironments(ctx, q)
+	q := envSelectQuery + ` WHERE e.type = $1;`
+	return s.getEnvironments(ctx, q, envType)
 }
 
 func (s *Storage) GetEnvByID(
 	ctx context.Context,
 	id string,
 ) (*model.Environment, error) {
-	q := `SELECT * FROM environments WHERE env_id = $1;`
+	q := envSelectQuery + ` WHERE e.env_id = $1;`
 
 	row := s.DB.QueryRowContext(ctx, q, id)
 
-	var e model.Environment
-	err := row.Scan(
-		&e.EnvID, &e.Type, &e.Name, &e.Namespace,
-		&e.Owner, &e.DeleteAt, &e.DeleteAtSec,
-	)
+	e, err := scanEnvironment(row)
 	if err != nil {
 		return nil, fmt.Errorf("get environment by id error: %w", err)
 	}
 
-	return &e, nil
+	return e, nil
 }
 
 func (s *Storage) GetStaleEnvironments(
 	ctx context.Context,
 	tr int64,
 ) ([]*model.Environment, error) {
-	q := `SELECT * FROM environments WHERE delete_at_sec < strftime('%s', 'now') + ?;`
+	q := envSelectQuery + `
+		WHERE e.status = ?
+			AND e.delete_at_sec > strftime('%s', 'now')
+			AND e.delete_at_sec < strftime('%s', 'now') + ?;`
 
-	return s.getEnvironments(ctx, q, tr)
+	return s.getEnvironments(ctx, q, model.StatusActive, tr)
 }
 
 func (s *Storage) GetOutdatedEnvironments(
 	ctx context.Context,
 ) ([]*model.Environment, error) {
-	q := `SELECT * FROM environments WHERE strftime('%s', 'now') > delete_at_sec;`
-
-	return s.getEnvironments(ctx, q)
+	q := envSelectQuery + `
+		WHERE e.status IN ($1, $2, $3, $4)
+			AND strftime('%s', 'now') > e.delete_at_sec
+			AND strftime('%s', 'now') >= e.next_attempt_at;`
+
+	return s.getEnvironments(
+		ctx, q,
+		model.StatusActive,
+		model.StatusWarned,
+		model.StatusFailed,
+		model.StatusDeleting,
+	)
 }
 
 func (s *Storage) ExtendEnvironment(
@@ -212,7 +433,11 @@ func (s *Storage) ExtendEnvironment(
 			return err
 		}
 
-		q := `UPDATE environments SET delete_at = $1, delete_at_sec = $2 WHERE env_id = $3;`
+		q := `UPDATE environments
+			SET delete_at = $1, delete_at_sec = $2, delete_at_source = $3,
+				failure_count = 0, last_error = '', next_attempt_at = 0,` +
+			rearmWarningSet(2, 4) + `
+			WHERE env_id = $5;`
 
 		stmt, err := tx.Prepare(q)
 		if err != nil {
@@ -220,7 +445,39 @@ func (s *Storage) ExtendEnvironment(
 		}
 		defer func() { _ = stmt.Close() }()
 
-		if _, err = stmt.Exec(env.DeleteAt, env.DeleteAtSec, id); err != nil {
+		if _, err = stmt.Exec(
+			env.DeleteAt, env.DeleteAtSec, model.DeleteAtSourceExtend,
+			time.Now().Unix(), id,
+		); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *Storage) UpdateEnvironment(
+	ctx context.Context,
+	env *model.Environment,
+) error {
+	return s.executeTransaction(ctx, func(tx *sql.Tx) error {
+		q := `UPDATE environments
+			SET owner = $1, delete_at = $2, delete_at_sec = $3,
+				ttl = $4, delete_at_source = $5, labels = $6,` +
+			rearmWarningSet(3, 7) + `
+			WHERE env_id = $8;`
+
+		if _, err := tx.ExecContext(
+			ctx, q,
+			env.Owner,
+			env.DeleteAt,
+			env.DeleteAtSec,
+			env.TTL,
+			env.DeleteAtSource,
+			model.FormatLabels(env.Labels),
+			time.Now().Unix(),
+			env.EnvID,
+		); err != nil {
 			return err
 		}
 
@@ -230,15 +487,102 @@ func (s *Storage) ExtendEnvironment(
 
 func (s *Storage) DeleteEnvironment(ctx context.Context, id string) error {
 	return s.executeTransaction(ctx, func(tx *sql.Tx) error {
-		q := `DELETE FROM environments WHERE env_id = $1;`
+		queries := []string{
+			`DELETE FROM environments WHERE env_id = $1;`,
+			`DELETE FROM tokens WHERE env_id = $1;`,
+		}
 
-		stmt, err := tx.Prepare(q)
+		for _, q := range queries {
+			if _, err := tx.ExecContext(ctx, q, id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *Storage) SetEnvironmentStatus(
+	ctx context.Context,
+	id, status string,
+) error {
+	from, ok := model.StatusTransitions[status]
+	if !ok {
+		return fmt.Errorf("unknown status: %s", status)
+	}
+
+	return s.executeTransaction(ctx, func(tx *sql.Tx) error {
+		q := fmt.Sprintf(
+			`UPDATE environments SET status = $1, status_changed_at = $2
+			WHERE env_id = $3 AND status IN (%s);`,
+			placeholders(4, len(from)),
+		)
+
+		args := []any{status, time.Now().Unix(), id}
+		for _, st := range from {
+			args = append(args, st)
+		}
+
+		res, err := tx.ExecContext(ctx, q, args...)
 		if err != nil {
 			return err
 		}
-		defer func() { _ = stmt.Close() }()
 
-		if _, err = stmt.Exec(id); err != nil {
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return &model.ConflictError{Msg: fmt.Sprintf(
+				"environment %s can't change status to %s", id, status,
+			)}
+		}
+
+		return nil
+	})
+}
+
+func (s *Storage) SetProtection(
+	ctx context.Context,
+	env *model.Environment,
+) error {
+	return s.executeTransaction(ctx, func(tx *sql.Tx) error {
+		q := `UPDATE environments
+			SET protected = $1, protected_until = $2,
+				protected_reason = $3, protected_by = $4
+			WHERE env_id = $5;`
+
+		if _, err := tx.ExecContext(
+			ctx, q,
+			env.Protected,
+			env.ProtectedUntil,
+			env.ProtectedReason,
+			env.ProtectedBy,
+			env.EnvID,
+		); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *Storage) SetDeleteFailure(
+	ctx context.Context,
+	id string,
+	failureCount int,
+	lastError string,
+	nextAttemptAt int64,
+) error {
+	return s.executeTransaction(ctx, func(tx *sql.Tx) error {
+		q := `UPDATE environments
+			SET failure_count = $1, last_error = $2, next_attempt_at = $3
+			WHERE env_id = $4;`
+
+		if _, err := tx.ExecContext(
+			ctx, q, failureCount, lastError, nextAttemptAt, id,
+		); err != nil {
 			return err
 		}
 
@@ -246,6 +590,35 @@ func (s *Storage) DeleteEnvironment(ctx context.Context, id string) error {
 	})
 }
 
+func (s *Storage) PurgeEnvironments(
+	ctx context.Context,
+	status string,
+	before int64,
+) (int64, error) {
+	var n int64
+	err := s.executeTransaction(ctx, func(tx *sql.Tx) error {
+		q := `DELETE FROM tokens WHERE env_id IN (
+			SELECT env_id FROM environments
+			WHERE status = $1 AND status_changed_at < $2
+		);`
+		if _, err := tx.ExecContext(ctx, q, status, before); err != nil {
+			return err
+		}
+
+		q = `DELETE FROM environments
+			WHERE status = $1 AND status_changed_at < $2;`
+		res, err := tx.ExecContext(ctx, q, status, before)
+		if err != nil {
+			return err
+		}
+
+		n, err = res.RowsAffected()
+		return err
+	})
+
+	return n, err
+}
+
 func (s *Storage) SetToken(
 	ctx context.Context,
 	id string,
@@ -345,6 +718,10 @@ func (s *Storage) executeTransaction(
 	return tx.Commit()
 }
 
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
+}
+
 func (s *Storage) Close() error {
 	return s.DB.Close()
 }
@@ -362,21 +739,61 @@ func (s *Storage) getEnvironments(
 
 	var envs []*model.Environment
 	for rows.Next() {
-		var e model.Environment
-		err := rows.Scan(
-			&e.EnvID,
-			&e.Type,
-			&e.Name,
-			&e.Namespace,
-			&e.Owner,
-			&e.DeleteAt,
-			&e.DeleteAtSec,
-		)
+		e, err := scanEnvironment(rows)
 		if err != nil {
 			return nil, fmt.Errorf("get outdated environments error: %w", err)
 		}
-		envs = append(envs, &e)
+		envs = append(envs, e)
 	}
 
 	return envs, nil
 }
+
+// placeholders returns n comma separated placeholders numbered from start.
+func placeholders(start, n int) string {
+	ph := make([]string, n)
+	for i := range ph {
+		ph[i] = fmt.Sprintf("$%d", start+i)
+	}
+	return strings.Join(ph, ", ")
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanEnvironment scans a row selected with envSelectQuery.
+func scanEnvironment(row rowScanner) (*model.Environment, error) {
+	var e model.Environment
+	var labels string
+	if err := row.Scan(
+		&e.EnvID,
+		&e.Type,
+		&e.Name,
+		&e.Namespace,
+		&e.Owner,
+		&e.DeleteAt,
+		&e.DeleteAtSec,
+		&e.TTL,
+		&e.DeleteAtSource,
+		&e.Status,
+		&e.StatusChangedAt,
+		&e.FailureCount,
+		&e.LastError,
+		&e.NextAttemptAt,
+		&e.Protected,
+		&e.ProtectedUntil,
+		&e.ProtectedReason,
+		&e.ProtectedBy,
+		&labels,
+	); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if e.Labels, err = model.ParseLabels(labels); err != nil {
+		return nil, fmt.Errorf("environment %s: %w", e.EnvID, err)
+	}
+
+	return &e, nil
+}
@@ -0,0 +1,63 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fragpit/env-cleaner/internal/model"
+)
+
+func TestWriteEnvironmentsRetracksDeleted(t *testing.T) {
+	ctx := context.Background()
+	st, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+
+	deleteAt := time.Now().Add(24 * time.Hour)
+	env := func(id, owner string) model.Environment {
+		return model.Environment{
+			EnvID:       id,
+			Type:        "helm",
+			Name:        "app-" + id,
+			Namespace:   "review",
+			Owner:       owner,
+			DeleteAt:    deleteAt.Format("02-01-06 15:04:05"),
+			DeleteAtSec: deleteAt.Unix(),
+		}
+	}
+
+	if err := st.WriteEnvironments(ctx, []model.Environment{
+		env("1", "ivanov"), env("2", "ivanov"),
+	}); err != nil {
+		t.Fatalf("WriteEnvironments: %v", err)
+	}
+	for _, status := range []string{model.StatusDeleting, model.StatusDeleted} {
+		if err := st.SetEnvironmentStatus(ctx, "1", status); err != nil {
+			t.Fatalf("SetEnvironmentStatus(%s): %v", status, err)
+		}
+	}
+
+	// The deleted environment is tracked from scratch, the active one is
+	// left as stored and a new one is added.
+	if err := st.WriteEnvironments(ctx, []model.Environment{
+		env("1", "petrov"), env("2", "petrov"), env("3", "petrov"),
+	}); err != nil {
+		t.Fatalf("WriteEnvironments again: %v", err)
+	}
+
+	for id, want := range map[string]string{
+		"1": "petrov", "2": "ivanov", "3": "petrov",
+	} {
+		got, err := st.GetEnvByID(ctx, id)
+		if err != nil {
+			t.Fatalf("GetEnvByID(%s): %v", id, err)
+		}
+		if got.Owner != want || got.Status != model.StatusActive {
+			t.Errorf("environment %s: owner %s, status %s, want %s and active",
+				id, got.Owner, got.Status, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/fragpit/env-cleaner/internal/config"
+	"github.com/fragpit/env-cleaner/internal/model"
+	"github.com/fragpit/env-cleaner/internal/storage/migrations"
+	"github.com/fragpit/env-cleaner/internal/storage/postgresql"
+	"github.com/fragpit/env-cleaner/internal/storage/sqlite"
+)
+
+type Storage interface {
+	model.Repository
+	Migrator() (*migrations.Migrator, error)
+}
+
+var (
+	_ Storage = (*sqlite.Storage)(nil)
+	_ Storage = (*postgresql.Storage)(nil)
+)
+
+var ErrNotConfigured = errors.New(
+	"no storage configured: set sqlite.database_folder or postgresql.host",
+)
+
+// openMode is how open prepares the configured storage.
+type openMode int
+
+const (
+	// modeOpen opens an existing database without writing to it.
+	modeOpen openMode = iota
+	// modeCreate creates a missing SQLite database without touching the
+	// schema.
+	modeCreate
+	// modeMigrate creates a missing SQLite database and upgrades the schema.
+	modeMigrate
+)
+
+// New connects to the configured storage and upgrades its schema.
+// SQLite is preferred if both backends are configured.
+func New(cfg *config.ServerConfig) (Storage, error) {
+	return open(cfg, modeMigrate)
+}
+
+// Create connects to the configured storage, creating a missing SQLite
+// database, without upgrading its schema.
+func Create(cfg *config.ServerConfig) (Storage, error) {
+	return open(cfg, modeCreate)
+}
+
+// Open connects to the configured storage without creating the database or
+// upgrading its schema.
+func Open(cfg *config.ServerConfig) (Storage, error) {
+	return open(cfg, modeOpen)
+}
+
+// OpenCurrent connects to the configured storage like Open and fails if
+// its schema has pending migrations.
+func OpenCurrent(ctx context.Context, cfg *config.ServerConfig) (Storage, error) {
+	st, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := st.Migrator()
+	if err != nil {
+		_ = st.Close()
+		return nil, err
+	}
+
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		_ = st.Close()
+		return nil, err
+	}
+	if len(pending) > 0 {
+		_ = st.Close()
+		return nil, fmt.Errorf(
+			"database schema has %d pending migrations, run env-cleaner db migrate",
+			len(pending),
+		)
+	}
+
+	return st, nil
+}
+
+func open(cfg *config.ServerConfig, mode openMode) (Storage, error) {
+	switch {
+	case cfg.SQLite.DatabaseFolder != "":
+		var st *sqlite.Storage
+		var err error
+		switch mode {
+		case modeMigrate:
+			st, err = sqlite.New(cfg.SQLite.DatabaseFolder)
+		case modeCreate:
+			st, err = sqlite.Create(cfg.SQLite.DatabaseFolder)
+		default:
+			st, err = sqlite.Open(cfg.SQLite.DatabaseFolder)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		slog.Info("successfully connected to SQLite database",
+			slog.String("folder", cfg.SQLite.DatabaseFolder),
+		)
+		return st, nil
+	case cfg.Postgresql.Host != "":
+		var st *postgresql.Storage
+		var err error
+		if mode == modeMigrate {
+			st, err = postgresql.New(
+				cfg.Postgresql.Host,
+				cfg.Postgresql.Port,
+				cfg.Postgresql.Username,
+				cfg.Postgresql.Password,
+				cfg.Postgresql.Database,
+			)
+		} else {
+			st, err = postgresql.Open(
+				cfg.Postgresql.Host,
+				cfg.Postgresql.Port,
+				cfg.Postgresql.Username,
+				cfg.Postgresql.Password,
+				cfg.Postgresql.Database,
+			)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		slog.Info("successfully connected to PostgreSQL database",
+			slog.String("host", cfg.Postgresql.Host),
+			slog.Int("port", cfg.Postgresql.Port),
+			slog.String("database", cfg.Postgresql.Database),
+		)
+		return st, nil
+	}
+
+	return nil, ErrNotConfigured
+}
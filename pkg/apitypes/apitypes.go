@@ -0,0 +1,175 @@
+// Package apitypes defines the request and response types of the
+// env-cleaner API. The server and the Go client both use them, so that they
+// always agree on the wire format.
+package apitypes
+
+// Response is the envelope of every API response. Data is set on success,
+// Error otherwise.
+type Response struct {
+	Success bool    `json:"success"`
+	Data    any     `json:"data,omitempty"`
+	Paging  *Paging `json:"paging,omitempty"`
+	Error   *Error  `json:"error,omitempty"`
+}
+
+// Paging describes a page of a list response. NextCursor is empty on the
+// last page.
+type Paging struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Error describes a failed request. Code is the HTTP status code.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// EnvironmentRequest is a DTO for creating an environment.
+type EnvironmentRequest struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Owner     string `json:"owner"`
+	Type      string `json:"type"`
+	TTL       string `json:"ttl"`
+	// Labels are key=value pairs.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// UpdateEnvironmentRequest is a DTO for changing an environment. Empty
+// fields are left unchanged, TTL and DeleteAt are mutually exclusive.
+type UpdateEnvironmentRequest struct {
+	Owner    string `json:"owner,omitempty"`
+	TTL      string `json:"ttl,omitempty"`
+	DeleteAt string `json:"delete_at,omitempty"`
+}
+
+// EnvironmentResponse is a DTO for returning environment data.
+type EnvironmentResponse struct {
+	EnvID     string `json:"env_id"`
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Owner     string `json:"owner"`
+	DeleteAt  string `json:"delete_at,omitempty"`
+	// DeleteAtSec is DeleteAt in unix time.
+	DeleteAtSec int64  `json:"delete_at_sec,omitempty"`
+	TTL         string `json:"ttl,omitempty"`
+	// DeleteAtSource tells what set the current delete_at.
+	DeleteAtSource  string `json:"delete_at_source,omitempty"`
+	Status          string `json:"status,omitempty"`
+	StatusChangedAt string `json:"status_changed_at,omitempty"`
+	// FailureCount, LastError and NextAttemptAt describe failed deletion
+	// attempts.
+	FailureCount  int    `json:"failure_count,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	NextAttemptAt string `json:"next_attempt_at,omitempty"`
+	// ProtectedUntil is empty for protection without expiry.
+	Protected       bool              `json:"protected,omitempty"`
+	ProtectedUntil  string            `json:"protected_until,omitempty"`
+	ProtectedReason string            `json:"protected_reason,omitempty"`
+	ProtectedBy     string            `json:"protected_by,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+}
+
+// ExtendEnvironmentRequest is a DTO for extending an environment's TTL.
+// Owners extend by Period with the Token from a stale notification.
+// Authenticated admins send no token and extend by Period or set DeleteAt,
+// optionally ignoring max_extend_duration.
+type ExtendEnvironmentRequest struct {
+	Period    string `json:"period,omitempty"`
+	Token     string `json:"token,omitempty"`
+	DeleteAt  string `json:"delete_at,omitempty"`
+	IgnoreMax bool   `json:"ignore_max,omitempty"`
+}
+
+// ProtectEnvironmentRequest is a DTO for protecting an environment from
+// deletion.
+type ProtectEnvironmentRequest struct {
+	// Period is empty for protection without expiry.
+	Period string `json:"period,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// BatchRequest is a DTO for applying an action to several environments,
+// listed in IDs or matched by Selector. Period, DeleteAt, IgnoreMax, Reason
+// and Owner are the arguments of the action.
+type BatchRequest struct {
+	Selector  *BatchSelectorRequest `json:"selector,omitempty"`
+	IDs       []string              `json:"ids,omitempty"`
+	Action    string                `json:"action"`
+	Period    string                `json:"period,omitempty"`
+	DeleteAt  string                `json:"delete_at,omitempty"`
+	IgnoreMax bool                  `json:"ignore_max,omitempty"`
+	Reason    string                `json:"reason,omitempty"`
+	Owner     string                `json:"owner,omitempty"`
+}
+
+// BatchSelectorRequest selects environments by their fields. NameRegex is
+// a regular expression matched against the name, Label a comma separated
+// list of key=value labels and bare keys.
+type BatchSelectorRequest struct {
+	Owner     string `json:"owner,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Type      string `json:"type,omitempty"`
+	NameRegex string `json:"name_regex,omitempty"`
+	Label     string `json:"label,omitempty"`
+}
+
+// BatchResponse is a DTO for returning the results of a batch operation.
+type BatchResponse struct {
+	Action    string               `json:"action"`
+	DryRun    bool                 `json:"dry_run"`
+	Matched   int                  `json:"matched"`
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+	Items     []*BatchItemResponse `json:"items"`
+}
+
+// BatchItemResponse is a DTO for the result of a batch operation on one
+// environment. Result is matched on dry runs, ok or failed otherwise.
+type BatchItemResponse struct {
+	EnvID       string               `json:"env_id"`
+	Result      string               `json:"result"`
+	Error       string               `json:"error,omitempty"`
+	Environment *EnvironmentResponse `json:"environment,omitempty"`
+}
+
+// WebhookResponse is a DTO for returning the result of a webhook. Ignored
+// is set for events that do not end a merge request, Items lists the
+// environments scheduled for deletion otherwise.
+type WebhookResponse struct {
+	Ignored bool                 `json:"ignored,omitempty"`
+	Project string               `json:"project,omitempty"`
+	Number  int                  `json:"number,omitempty"`
+	State   string               `json:"state,omitempty"`
+	Items   []*BatchItemResponse `json:"items,omitempty"`
+}
+
+// AuditEntryResponse is a DTO for returning audit log entries.
+type AuditEntryResponse struct {
+	ID          int64  `json:"id"`
+	CreatedAt   string `json:"created_at"`
+	EnvID       string `json:"env_id"`
+	EnvType     string `json:"env_type"`
+	EnvName     string `json:"env_name"`
+	Action      string `json:"action"`
+	Actor       string `json:"actor"`
+	Source      string `json:"source"`
+	OldDeleteAt string `json:"old_delete_at,omitempty"`
+	NewDeleteAt string `json:"new_delete_at,omitempty"`
+	Details     string `json:"details,omitempty"`
+}
+
+// HealthResponse is a DTO for returning health check results.
+type HealthResponse struct {
+	Status     string                              `json:"status"`
+	Components map[string]*ComponentHealthResponse `json:"components"`
+}
+
+// ComponentHealthResponse is a DTO for the health of a single component.
+type ComponentHealthResponse struct {
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	LastSuccess string `json:"last_success,omitempty"`
+}
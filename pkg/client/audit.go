@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const auditEndpoint = "/api/audit"
+
+// AuditOptions filters audit log entries. Empty fields are not sent.
+type AuditOptions struct {
+	EnvID  string
+	Action string
+	Actor  string
+	Source string
+	// Owner of the environments the entries are about.
+	Owner string
+	// Since and Until are a period back from now (e.g. 7d) or an RFC 3339
+	// time.
+	Since string
+	Until string
+	// Limit is the maximum number of entries, 100 if zero.
+	Limit int
+}
+
+// GetAuditEntries returns audit log entries, newest first.
+func (c *Client) GetAuditEntries(
+	ctx context.Context,
+	opts *AuditOptions,
+) ([]AuditEntry, error) {
+	query := url.Values{}
+	if opts != nil {
+		for key, value := range map[string]string{
+			"env_id": opts.EnvID,
+			"action": opts.Action,
+			"actor":  opts.Actor,
+			"source": opts.Source,
+			"owner":  opts.Owner,
+			"since":  opts.Since,
+			"until":  opts.Until,
+		} {
+			if value != "" {
+				query.Set(key, value)
+			}
+		}
+		if opts.Limit > 0 {
+			query.Set("limit", strconv.Itoa(opts.Limit))
+		}
+	}
+
+	var entries []AuditEntry
+	if _, err := c.do(
+		ctx, http.MethodGet, auditEndpoint, query, nil, &entries,
+	); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,296 @@
+// Package client is a Go client of the env-cleaner API.
+//
+//	c, err := client.New(client.Config{
+//		URL:    "https://env-cleaner.example.com",
+//		APIKey: os.Getenv("EC_API_KEY"),
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	env, err := c.GetEnvironment(ctx, "a1b2c3d4")
+//	if errors.Is(err, client.ErrNotFound) {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"time"
+
+	"github.com/fragpit/env-cleaner/pkg/apitypes"
+)
+
+const (
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 2
+	defaultRetryWait  = 500 * time.Millisecond
+)
+
+// Config configures a Client.
+type Config struct {
+	// URL is the base URL of the API, e.g. http://localhost:8080.
+	URL string
+	// APIKey is a named API key sent as a bearer token. An OIDC token is
+	// accepted as well.
+	APIKey string
+	// AdminAPIKey is the legacy admin key sent with Basic auth if APIKey
+	// is empty.
+	AdminAPIKey string
+	// Timeout limits each attempt of a request, 30s if zero.
+	Timeout time.Duration
+	// TLS configures HTTPS connections, see LoadTLSConfig.
+	TLS *tls.Config
+	// HTTPClient replaces the client built from Timeout and TLS.
+	HTTPClient *http.Client
+	// MaxRetries is how many times idempotent requests are retried on
+	// connection errors and 429, 502, 503 and 504 responses. It is 2 if
+	// zero and retries are disabled if negative.
+	MaxRetries int
+	// RetryWait is the wait before the first retry, doubled for each next
+	// one, 500ms if zero.
+	RetryWait time.Duration
+}
+
+// Client sends requests to the env-cleaner API. It is safe for concurrent
+// use.
+type Client struct {
+	baseURL     *url.URL
+	apiKey      string
+	adminAPIKey string
+	httpClient  *http.Client
+	maxRetries  int
+	retryWait   time.Duration
+}
+
+func New(cfg Config) (*Client, error) {
+	baseURL, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing url: %w", err)
+	}
+
+	c := &Client{
+		baseURL:     baseURL,
+		apiKey:      cfg.APIKey,
+		adminAPIKey: cfg.AdminAPIKey,
+		httpClient:  cfg.HTTPClient,
+		maxRetries:  cfg.MaxRetries,
+		retryWait:   cfg.RetryWait,
+	}
+
+	if c.httpClient == nil {
+		timeout := cfg.Timeout
+		if timeout == 0 {
+			timeout = defaultTimeout
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = cfg.TLS
+		c.httpClient = &http.Client{Transport: transport, Timeout: timeout}
+	}
+
+	switch {
+	case c.maxRetries == 0:
+		c.maxRetries = defaultMaxRetries
+	case c.maxRetries < 0:
+		c.maxRetries = 0
+	}
+	if c.retryWait == 0 {
+		c.retryWait = defaultRetryWait
+	}
+
+	return c, nil
+}
+
+// LoadTLSConfig returns a TLS configuration trusting the CA in caFile and
+// presenting the client certificate in certFile and keyFile. Empty files
+// are skipped, and nil is returned if all of them are empty.
+func LoadTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading ca file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates in ca file %s", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// response is apitypes.Response with the data left encoded.
+type response struct {
+	Success bool             `json:"success"`
+	Data    json.RawMessage  `json:"data,omitempty"`
+	Paging  *apitypes.Paging `json:"paging,omitempty"`
+	Error   *apitypes.Error  `json:"error,omitempty"`
+}
+
+// do sends a request to the API endpoint at p and decodes the data of the
+// response into out, unless it is nil. in is sent as the JSON body unless
+// it is nil.
+func (c *Client) do(
+	ctx context.Context,
+	method, p string,
+	query url.Values,
+	in, out any,
+) (*response, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return nil, fmt.Errorf("error marshaling json: %w", err)
+		}
+	}
+
+	res, err := c.send(ctx, method, p, query, body)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	var resp response
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		// Proxies in front of the server answer errors with other bodies.
+		if res.StatusCode >= http.StatusBadRequest {
+			return nil, &APIError{
+				Code:    res.StatusCode,
+				Message: http.StatusText(res.StatusCode),
+			}
+		}
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	if !resp.Success {
+		if resp.Error == nil {
+			return nil, &APIError{
+				Code:    res.StatusCode,
+				Message: http.StatusText(res.StatusCode),
+			}
+		}
+		return nil, &APIError{Code: resp.Error.Code, Message: resp.Error.Message}
+	}
+
+	if out != nil && len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, out); err != nil {
+			return nil, fmt.Errorf("error decoding response: %w", err)
+		}
+	}
+
+	return &resp, nil
+}
+
+// send sends a request, retrying idempotent ones on transient failures.
+// The caller closes the body of the response.
+func (c *Client) send(
+	ctx context.Context,
+	method, p string,
+	query url.Values,
+	body []byte,
+) (*http.Response, error) {
+	retries := 0
+	if idempotent(method) {
+		retries = c.maxRetries
+	}
+
+	wait := c.retryWait
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(
+			ctx, method, c.url(p, query), bytes.NewReader(body),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		c.setAuthHeader(req)
+
+		res, err := c.httpClient.Do(req)
+		if err == nil && !retryableStatus(res.StatusCode) {
+			return res, nil
+		}
+		if attempt >= retries || ctx.Err() != nil {
+			if err != nil {
+				return nil, fmt.Errorf("error sending request: %w", err)
+			}
+			return res, nil
+		}
+		if res != nil {
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("error sending request: %w", ctx.Err())
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// url returns the URL of the API endpoint at p.
+func (c *Client) url(p string, query url.Values) string {
+	u := *c.baseURL
+	u.Path = path.Join(u.Path, p)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// setAuthHeader authenticates req with the API key, falling back to the
+// legacy admin key.
+func (c *Client) setAuthHeader(req *http.Request) {
+	switch {
+	case c.apiKey != "":
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	case c.adminAPIKey != "":
+		req.Header.Set("Authorization", "Basic "+
+			base64.StdEncoding.EncodeToString([]byte(c.adminAPIKey)))
+	}
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,333 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fragpit/env-cleaner/pkg/apitypes"
+)
+
+// newTestClient returns a client of a test server answering with handler.
+func newTestClient(t *testing.T, cfg Config, handler http.HandlerFunc) *Client {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	cfg.URL = srv.URL
+	if cfg.RetryWait == 0 {
+		cfg.RetryWait = time.Millisecond
+	}
+
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return c
+}
+
+func writeResponse(t *testing.T, w http.ResponseWriter, code int, resp apitypes.Response) {
+	t.Helper()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		t.Errorf("encode response: %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		call         func(c *Client) error
+		status       int
+		maxRetries   int
+		wantAttempts int32
+	}{
+		{
+			name: "get on 503",
+			call: func(c *Client) error {
+				_, err := c.GetEnvironment(context.Background(), "1")
+				return err
+			},
+			status:       http.StatusServiceUnavailable,
+			wantAttempts: 3,
+		},
+		{
+			name: "delete on 429",
+			call: func(c *Client) error {
+				_, err := c.DeleteEnvironment(context.Background(), "1", false)
+				return err
+			},
+			status:       http.StatusTooManyRequests,
+			wantAttempts: 3,
+		},
+		{
+			name: "get on 502 with one retry",
+			call: func(c *Client) error {
+				_, err := c.GetEnvironment(context.Background(), "1")
+				return err
+			},
+			status:       http.StatusBadGateway,
+			maxRetries:   1,
+			wantAttempts: 2,
+		},
+		{
+			name: "get on 504 without retries",
+			call: func(c *Client) error {
+				_, err := c.GetEnvironment(context.Background(), "1")
+				return err
+			},
+			status:       http.StatusGatewayTimeout,
+			maxRetries:   -1,
+			wantAttempts: 1,
+		},
+		{
+			name: "get on 500",
+			call: func(c *Client) error {
+				_, err := c.GetEnvironment(context.Background(), "1")
+				return err
+			},
+			status:       http.StatusInternalServerError,
+			wantAttempts: 1,
+		},
+		{
+			name: "post on 503",
+			call: func(c *Client) error {
+				_, err := c.ExtendEnvironment(
+					context.Background(), "1", &ExtendRequest{Period: "1d"},
+				)
+				return err
+			},
+			status:       http.StatusServiceUnavailable,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			c := newTestClient(t, Config{MaxRetries: tt.maxRetries},
+				func(w http.ResponseWriter, _ *http.Request) {
+					attempts.Add(1)
+					writeResponse(t, w, tt.status, apitypes.Response{
+						Error: &apitypes.Error{Code: tt.status, Message: "unavailable"},
+					})
+				})
+
+			err := tt.call(c)
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != tt.status {
+				t.Fatalf("error = %v, want an APIError with code %d", err, tt.status)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetrySucceeds(t *testing.T) {
+	var attempts atomic.Int32
+	c := newTestClient(t, Config{}, func(w http.ResponseWriter, _ *http.Request) {
+		if attempts.Add(1) == 1 {
+			writeResponse(t, w, http.StatusServiceUnavailable, apitypes.Response{})
+			return
+		}
+		writeResponse(t, w, http.StatusOK, apitypes.Response{
+			Success: true,
+			Data:    Environment{EnvID: "1"},
+		})
+	})
+
+	env, err := c.GetEnvironment(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("GetEnvironment: %v", err)
+	}
+	if env.EnvID != "1" || attempts.Load() != 2 {
+		t.Errorf("env = %+v after %d attempts, want 1 after 2",
+			env, attempts.Load())
+	}
+}
+
+func TestAPIErrorUnwrap(t *testing.T) {
+	tests := []struct {
+		code int
+		want error
+	}{
+		{http.StatusBadRequest, ErrBadRequest},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusConflict, ErrConflict},
+		{http.StatusInternalServerError, ErrServer},
+		{http.StatusTeapot, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.code), func(t *testing.T) {
+			c := newTestClient(t, Config{},
+				func(w http.ResponseWriter, _ *http.Request) {
+					writeResponse(t, w, tt.code, apitypes.Response{
+						Error: &apitypes.Error{Code: tt.code, Message: "failed"},
+					})
+				})
+
+			_, err := c.GetEnvironment(context.Background(), "1")
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error = %v, want an APIError", err)
+			}
+			if apiErr.Message != "failed" {
+				t.Errorf("message = %q, want failed", apiErr.Message)
+			}
+			if tt.want == nil {
+				if apiErr.Unwrap() != nil {
+					t.Errorf("Unwrap = %v, want nil", apiErr.Unwrap())
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNonJSONErrorBody(t *testing.T) {
+	c := newTestClient(t, Config{MaxRetries: -1},
+		func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte("<html><body>502 Bad Gateway</body></html>"))
+		})
+
+	_, err := c.GetEnvironment(context.Background(), "1")
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error = %v, want an APIError", err)
+	}
+	if apiErr.Code != http.StatusBadGateway ||
+		apiErr.Message != http.StatusText(http.StatusBadGateway) {
+		t.Errorf("error = %+v, want 502 Bad Gateway", apiErr)
+	}
+	if !errors.Is(err, ErrServer) {
+		t.Errorf("error = %v, want ErrServer", err)
+	}
+}
+
+func TestAuthHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{
+			name: "api key",
+			cfg:  Config{APIKey: "named-key", AdminAPIKey: "admin-key"},
+			want: "Bearer named-key",
+		},
+		{
+			name: "legacy admin key",
+			cfg:  Config{AdminAPIKey: "admin-key"},
+			want: "Basic " + base64.StdEncoding.EncodeToString([]byte("admin-key")),
+		},
+		{
+			name: "no key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			c := newTestClient(t, tt.cfg,
+				func(w http.ResponseWriter, r *http.Request) {
+					got = r.Header.Get("Authorization")
+					writeResponse(t, w, http.StatusOK, apitypes.Response{
+						Success: true,
+						Data:    Environment{EnvID: "1"},
+					})
+				})
+
+			if _, err := c.GetEnvironment(context.Background(), "1"); err != nil {
+				t.Fatalf("GetEnvironment: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListAllEnvironments(t *testing.T) {
+	pages := map[string]struct {
+		ids  []string
+		next string
+	}{
+		"":   {ids: []string{"1", "2"}, next: "c1"},
+		"c1": {ids: []string{"3", "4"}, next: "c2"},
+		"c2": {ids: []string{"5"}},
+	}
+
+	var cursors []string
+	c := newTestClient(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != environmentsEndpoint {
+			t.Errorf("path = %s, want %s", r.URL.Path, environmentsEndpoint)
+		}
+		if got := r.URL.Query().Get("owner"); got != "alice" {
+			t.Errorf("owner = %q, want alice", got)
+		}
+		if got := r.URL.Query().Get("limit"); got != "2" {
+			t.Errorf("limit = %q, want 2", got)
+		}
+
+		cursor := r.URL.Query().Get("cursor")
+		cursors = append(cursors, cursor)
+		page, ok := pages[cursor]
+		if !ok {
+			t.Errorf("unknown cursor %q", cursor)
+			writeResponse(t, w, http.StatusBadRequest, apitypes.Response{})
+			return
+		}
+
+		envs := make([]Environment, len(page.ids))
+		for i, id := range page.ids {
+			envs[i] = Environment{EnvID: id}
+		}
+		writeResponse(t, w, http.StatusOK, apitypes.Response{
+			Success: true,
+			Data:    envs,
+			Paging:  &apitypes.Paging{Limit: 2, NextCursor: page.next},
+		})
+	})
+
+	envs, err := c.ListAllEnvironments(context.Background(), &ListOptions{
+		Owner: "alice",
+		Limit: 2,
+	})
+	if err != nil {
+		t.Fatalf("ListAllEnvironments: %v", err)
+	}
+
+	var ids []string
+	for _, env := range envs {
+		ids = append(ids, env.EnvID)
+	}
+	if want := []string{"1", "2", "3", "4", "5"}; !slices.Equal(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+	if want := []string{"", "c1", "c2"}; !slices.Equal(cursors, want) {
+		t.Errorf("cursors = %v, want %v", cursors, want)
+	}
+}
@@ -0,0 +1,253 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const environmentsEndpoint = "/api/environments"
+
+// ListOptions filters and pages environment lists. Empty fields are not
+// sent.
+type ListOptions struct {
+	// Statuses to list, active ones if empty.
+	Statuses  []string
+	Owner     string
+	Type      string
+	Namespace string
+	// Name is a glob pattern, e.g. feature-*.
+	Name string
+	// Label is a label selector, e.g. team=qa,critical. A bare key
+	// matches any value.
+	Label string
+	// DeleteBefore and DeleteAfter bound the deletion date with a period
+	// from now (e.g. 2d) or an RFC 3339 time.
+	DeleteBefore string
+	DeleteAfter  string
+	// Sort is a field to sort by, descending with a leading -.
+	Sort string
+	// Limit is the page size.
+	Limit  int
+	Cursor string
+}
+
+func (o *ListOptions) query() url.Values {
+	query := url.Values{}
+	if o == nil {
+		return query
+	}
+
+	for key, value := range map[string]string{
+		"status":        strings.Join(o.Statuses, ","),
+		"owner":         o.Owner,
+		"type":          o.Type,
+		"namespace":     o.Namespace,
+		"name":          o.Name,
+		"label":         o.Label,
+		"delete_before": o.DeleteBefore,
+		"delete_after":  o.DeleteAfter,
+		"sort":          o.Sort,
+		"cursor":        o.Cursor,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+
+	return query
+}
+
+// EnvironmentPage is a page of environments. NextCursor is empty on the
+// last page.
+type EnvironmentPage struct {
+	Environments []Environment
+	NextCursor   string
+}
+
+// ListEnvironments returns a page of environments.
+func (c *Client) ListEnvironments(
+	ctx context.Context,
+	opts *ListOptions,
+) (*EnvironmentPage, error) {
+	page := &EnvironmentPage{}
+	resp, err := c.do(
+		ctx, http.MethodGet, environmentsEndpoint, opts.query(), nil,
+		&page.Environments,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Paging != nil {
+		page.NextCursor = resp.Paging.NextCursor
+	}
+
+	return page, nil
+}
+
+// ListAllEnvironments returns the environments of every page, requested
+// with opts.Limit as the page size.
+func (c *Client) ListAllEnvironments(
+	ctx context.Context,
+	opts *ListOptions,
+) ([]Environment, error) {
+	pageOpts := ListOptions{}
+	if opts != nil {
+		pageOpts = *opts
+	}
+
+	var environments []Environment
+	for {
+		page, err := c.ListEnvironments(ctx, &pageOpts)
+		if err != nil {
+			return nil, err
+		}
+		environments = append(environments, page.Environments...)
+
+		if page.NextCursor == "" {
+			return environments, nil
+		}
+		pageOpts.Cursor = page.NextCursor
+	}
+}
+
+// GetEnvironment returns an environment in any status.
+func (c *Client) GetEnvironment(
+	ctx context.Context,
+	envID string,
+) (*Environment, error) {
+	return c.environmentRequest(ctx, http.MethodGet, envID, "", nil, nil)
+}
+
+// AddEnvironment registers an environment.
+func (c *Client) AddEnvironment(
+	ctx context.Context,
+	req *EnvironmentRequest,
+) (*Environment, error) {
+	var env Environment
+	if _, err := c.do(
+		ctx, http.MethodPost, environmentsEndpoint, nil, req, &env,
+	); err != nil {
+		return nil, err
+	}
+
+	return &env, nil
+}
+
+// UpdateEnvironment changes the owner and the deletion date of an
+// environment.
+func (c *Client) UpdateEnvironment(
+	ctx context.Context,
+	envID string,
+	req *UpdateEnvironmentRequest,
+) (*Environment, error) {
+	return c.environmentRequest(ctx, http.MethodPatch, envID, "", nil, req)
+}
+
+// DeleteEnvironment schedules an immediate deletion of an environment, or
+// removes it from the database without deleting it if forget is set.
+func (c *Client) DeleteEnvironment(
+	ctx context.Context,
+	envID string,
+	forget bool,
+) (*Environment, error) {
+	var query url.Values
+	if forget {
+		query = url.Values{"forget": {"true"}}
+	}
+
+	return c.environmentRequest(
+		ctx, http.MethodDelete, envID, "", query, nil,
+	)
+}
+
+// ExtendEnvironment extends an environment by a period or sets its
+// deletion date. Without credentials req must carry the token of a stale
+// notification.
+func (c *Client) ExtendEnvironment(
+	ctx context.Context,
+	envID string,
+	req *ExtendRequest,
+) (*Environment, error) {
+	return c.environmentRequest(
+		ctx, http.MethodPost, envID, "extend", nil, req,
+	)
+}
+
+// ProtectEnvironment protects an environment from deletion.
+func (c *Client) ProtectEnvironment(
+	ctx context.Context,
+	envID string,
+	req *ProtectRequest,
+) (*Environment, error) {
+	return c.environmentRequest(
+		ctx, http.MethodPost, envID, "protect", nil, req,
+	)
+}
+
+// UnprotectEnvironment removes the protection of an environment.
+func (c *Client) UnprotectEnvironment(
+	ctx context.Context,
+	envID string,
+) (*Environment, error) {
+	return c.environmentRequest(
+		ctx, http.MethodDelete, envID, "protect", nil, nil,
+	)
+}
+
+// BatchEnvironments applies an action to several environments. With
+// dryRun set the matched environments are returned without changing them.
+func (c *Client) BatchEnvironments(
+	ctx context.Context,
+	req *BatchRequest,
+	dryRun bool,
+) (*BatchResponse, error) {
+	var query url.Values
+	if dryRun {
+		query = url.Values{"dry_run": {"true"}}
+	}
+
+	var batch BatchResponse
+	if _, err := c.do(
+		ctx, http.MethodPost, path.Join(environmentsEndpoint, "batch"),
+		query, req, &batch,
+	); err != nil {
+		return nil, err
+	}
+
+	return &batch, nil
+}
+
+// environmentRequest sends a request to the action endpoint of an
+// environment, or to the environment itself if action is empty.
+func (c *Client) environmentRequest(
+	ctx context.Context,
+	method, envID, action string,
+	query url.Values,
+	in any,
+) (*Environment, error) {
+	if envID == "" {
+		return nil, &APIError{
+			Code:    http.StatusBadRequest,
+			Message: "environment id is empty",
+		}
+	}
+
+	var env Environment
+	if _, err := c.do(
+		ctx, method,
+		path.Join(environmentsEndpoint, url.PathEscape(envID), action),
+		query, in, &env,
+	); err != nil {
+		return nil, err
+	}
+
+	return &env, nil
+}
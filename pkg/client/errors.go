@@ -0,0 +1,48 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors an *APIError wraps depending on its code, for use with errors.Is.
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrServer       = errors.New("server error")
+)
+
+// APIError is an error response of the API. Code is the HTTP status and
+// Message the error message of the server.
+type APIError struct {
+	Code    int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s (code: %d)", e.Message, e.Code)
+}
+
+// Unwrap returns the error matching the code, or nil for unexpected codes.
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.Code == http.StatusBadRequest:
+		return ErrBadRequest
+	case e.Code == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.Code == http.StatusForbidden:
+		return ErrForbidden
+	case e.Code == http.StatusNotFound:
+		return ErrNotFound
+	case e.Code == http.StatusConflict:
+		return ErrConflict
+	case e.Code >= http.StatusInternalServerError:
+		return ErrServer
+	}
+
+	return nil
+}
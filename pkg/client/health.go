@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Liveness returns the result of the liveness checks. A failing report is
+// returned without an error. Health endpoints are served by the internal
+// listener if the server has one, which needs a client of its own.
+func (c *Client) Liveness(ctx context.Context) (*Health, error) {
+	return c.health(ctx, "/healthz")
+}
+
+// Readiness returns the result of the readiness checks, see Liveness.
+func (c *Client) Readiness(ctx context.Context) (*Health, error) {
+	return c.health(ctx, "/readyz")
+}
+
+// health requests a health endpoint, which answers with the report as is
+// and 503 if it is failing. Failing reports are not retried.
+func (c *Client) health(ctx context.Context, p string) (*Health, error) {
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, c.url(p, nil), http.NoBody,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK &&
+		res.StatusCode != http.StatusServiceUnavailable {
+		return nil, &APIError{
+			Code:    res.StatusCode,
+			Message: http.StatusText(res.StatusCode),
+		}
+	}
+
+	var health Health
+	if err := json.NewDecoder(res.Body).Decode(&health); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &health, nil
+}
@@ -0,0 +1,21 @@
+package client
+
+import "github.com/fragpit/env-cleaner/pkg/apitypes"
+
+// Request and response types of the API. They are aliases of the types in
+// package apitypes, which the server uses as well, so that both always
+// agree on the wire format.
+type (
+	Environment              = apitypes.EnvironmentResponse
+	EnvironmentRequest       = apitypes.EnvironmentRequest
+	UpdateEnvironmentRequest = apitypes.UpdateEnvironmentRequest
+	ExtendRequest            = apitypes.ExtendEnvironmentRequest
+	ProtectRequest           = apitypes.ProtectEnvironmentRequest
+	BatchRequest             = apitypes.BatchRequest
+	BatchSelector            = apitypes.BatchSelectorRequest
+	BatchResponse            = apitypes.BatchResponse
+	BatchItem                = apitypes.BatchItemResponse
+	AuditEntry               = apitypes.AuditEntryResponse
+	Health                   = apitypes.HealthResponse
+	ComponentHealth          = apitypes.ComponentHealthResponse
+)
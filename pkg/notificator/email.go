@@ -1,14 +1,47 @@
 package notificator
 
 import (
+	"bytes"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+const (
+	smtpDialTimeout = 30 * time.Second
+	// smtpTimeout bounds the whole exchange with the server after dialing,
+	// so that a stalled server cannot block the sender.
+	smtpTimeout = 2 * time.Minute
+
+	// EmailTLSAuto upgrades the connection with STARTTLS if the server
+	// advertises it.
+	EmailTLSAuto = "auto"
+	// EmailTLSStartTLS requires STARTTLS.
+	EmailTLSStartTLS = "starttls"
+	// EmailTLSImplicit connects over TLS (SMTPS, usually port 465).
+	EmailTLSImplicit = "tls"
+	// EmailTLSNone sends mail over a plain connection.
+	EmailTLSNone = "none"
 )
 
 type EmailNotificator struct {
-	smtpServerAddress string
-	smtpServerPort    int
-	username          string
-	password          string
+	smtpServerAddress  string
+	smtpServerPort     int
+	username           string
+	password           string
+	tlsMode            string
+	insecureSkipVerify bool
+	timeout            time.Duration
 }
 
 func NewEmailNotificator(
@@ -16,32 +49,243 @@ func NewEmailNotificator(
 	smtpServerPort int,
 	username string,
 	password string,
+	tlsMode string,
+	insecureSkipVerify bool,
 ) *EmailNotificator {
+	if tlsMode == "" {
+		tlsMode = EmailTLSAuto
+	}
+
 	return &EmailNotificator{
-		smtpServerAddress: smtpServerAddress,
-		smtpServerPort:    smtpServerPort,
-		username:          username,
-		password:          password,
+		smtpServerAddress:  smtpServerAddress,
+		smtpServerPort:     smtpServerPort,
+		username:           username,
+		password:           password,
+		tlsMode:            tlsMode,
+		insecureSkipVerify: insecureSkipVerify,
+		timeout:            smtpTimeout,
 	}
 }
 
+// EmailMessage is an email with a plain text and an HTML body. From and To
+// may include display names, only their addresses are used in the SMTP
+// envelope.
 type EmailMessage struct {
 	From    string
 	To      string
 	Subject string
-	Body    string
+	Text    string
+	HTML    string
+
+	from *mail.Address
+	to   *mail.Address
+}
+
+func NewEmailMessage(from, to, subject, text, html string) (*EmailMessage, error) {
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("from and to cannot be empty")
+	}
+
+	fromAddr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %w", from, err)
+	}
+
+	toAddr, err := mail.ParseAddress(to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient address %q: %w", to, err)
+	}
+
+	return &EmailMessage{
+		From:    from,
+		To:      to,
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+		from:    fromAddr,
+		to:      toAddr,
+	}, nil
 }
 
-//nolint:revive
-func NewEmailMessage(senderName, channel, text string) (*EmailMessage, error) {
-	// TODO: Implement email message creation logic here
+// addresses returns the parsed sender and recipient, parsing them if the
+// message was not created with NewEmailMessage.
+func (m *EmailMessage) addresses() (from, to *mail.Address, err error) {
+	from, to = m.from, m.to
+	if from == nil {
+		if from, err = mail.ParseAddress(m.From); err != nil {
+			return nil, nil, fmt.Errorf("invalid sender address %q: %w", m.From, err)
+		}
+	}
+	if to == nil {
+		if to, err = mail.ParseAddress(m.To); err != nil {
+			return nil, nil, fmt.Errorf("invalid recipient address %q: %w", m.To, err)
+		}
+	}
+
+	return from, to, nil
+}
+
+// Bytes renders the message as a multipart/alternative MIME document with
+// plain text and HTML parts.
+func (m *EmailMessage) Bytes() ([]byte, error) {
+	from, to, err := m.addresses()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	headers := []struct{ key, value string }{
+		{"From", from.String()},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{
+			"Content-Type",
+			fmt.Sprintf("multipart/alternative; boundary=%q", mw.Boundary()),
+		},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", h.key, h.value)
+	}
+	buf.WriteString("\r\n")
+
+	parts := []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	}
+	for _, p := range parts {
+		if p.body == "" {
+			continue
+		}
+
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write([]byte(p.body)); err != nil {
+			return nil, err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
 
-	return nil, fmt.Errorf("not implemented")
+	return buf.Bytes(), nil
 }
 
-//nolint:revive
 func (nt *EmailNotificator) Send(msg *EmailMessage) error {
-	// TODO: Implement email sending logic here
+	slog.Debug("sending email notification", slog.String("to", msg.To))
+
+	from, to, err := msg.addresses()
+	if err != nil {
+		return fmt.Errorf("error building email message: %w", err)
+	}
+
+	body, err := msg.Bytes()
+	if err != nil {
+		return fmt.Errorf("error building email message: %w", err)
+	}
+
+	c, err := nt.dial()
+	if err != nil {
+		return fmt.Errorf("error connecting to smtp server: %w", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	if nt.username != "" {
+		auth := smtp.PlainAuth("", nt.username, nt.password, nt.smtpServerAddress)
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("error authenticating to smtp server: %w", err)
+		}
+	}
+
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+
+	if err := c.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+
+	return c.Quit()
+}
+
+func (nt *EmailNotificator) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(
+		nt.smtpServerAddress,
+		strconv.Itoa(nt.smtpServerPort),
+	)
+	tlsConfig := &tls.Config{
+		ServerName:         nt.smtpServerAddress,
+		InsecureSkipVerify: nt.insecureSkipVerify, //nolint:gosec
+	}
+	dialer := &net.Dialer{Timeout: smtpDialTimeout}
+
+	var conn net.Conn
+	var err error
+	switch nt.tlsMode {
+	case EmailTLSImplicit:
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	case EmailTLSAuto, EmailTLSStartTLS, EmailTLSNone:
+		conn, err = dialer.Dial("tcp", addr)
+	default:
+		return nil, fmt.Errorf("unknown tls mode: %s", nt.tlsMode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The deadline also covers the TLS connection upgraded with STARTTLS,
+	// which wraps conn.
+	if err := conn.SetDeadline(time.Now().Add(nt.timeout)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	c, err := smtp.NewClient(conn, nt.smtpServerAddress)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	if nt.tlsMode == EmailTLSAuto || nt.tlsMode == EmailTLSStartTLS {
+		ok, _ := c.Extension("STARTTLS")
+		switch {
+		case ok:
+			if err := c.StartTLS(tlsConfig); err != nil {
+				_ = c.Close()
+				return nil, err
+			}
+		case nt.tlsMode == EmailTLSStartTLS:
+			_ = c.Close()
+			return nil, errors.New("smtp server does not support STARTTLS")
+		}
+	}
 
-	return fmt.Errorf("not implemented")
+	return c, nil
 }
@@ -0,0 +1,482 @@
+package notificator
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSession is what the test SMTP server received in one connection.
+type smtpSession struct {
+	// tls is set if the connection was encrypted when the message was sent.
+	tls  bool
+	auth string
+	from string
+	to   string
+	data string
+}
+
+// testSMTPServer is a minimal in-process SMTP server that accepts a single
+// connection.
+type testSMTPServer struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+	startTLS  bool
+	sessions  chan smtpSession
+}
+
+func newTestSMTPServer(t *testing.T, implicitTLS, startTLS bool) *testSMTPServer {
+	t.Helper()
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{testCertificate(t)},
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	if implicitTLS {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	s := &testSMTPServer{
+		ln:        ln,
+		tlsConfig: tlsConfig,
+		startTLS:  startTLS,
+		sessions:  make(chan smtpSession, 1),
+	}
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		s.serve(conn)
+	}()
+
+	return s
+}
+
+func (s *testSMTPServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+// session waits for the server to receive a message.
+func (s *testSMTPServer) session(t *testing.T) smtpSession {
+	t.Helper()
+
+	select {
+	case sess := <-s.sessions:
+		return sess
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the smtp session")
+		return smtpSession{}
+	}
+}
+
+func (s *testSMTPServer) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	var sess smtpSession
+	_, sess.tls = conn.(*tls.Conn)
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "EHLO", "HELO":
+			exts := []string{"localhost"}
+			if s.startTLS && !sess.tls {
+				exts = append(exts, "STARTTLS")
+			}
+			exts = append(exts, "AUTH PLAIN")
+			for i, ext := range exts {
+				sep := "-"
+				if i == len(exts)-1 {
+					sep = " "
+				}
+				_ = tp.PrintfLine("250%s%s", sep, ext)
+			}
+		case "STARTTLS":
+			_ = tp.PrintfLine("220 ready to start TLS")
+			tc := tls.Server(conn, s.tlsConfig)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			conn = tc
+			tp = textproto.NewConn(tc)
+			sess.tls = true
+		case "AUTH":
+			mech, resp, _ := strings.Cut(arg, " ")
+			creds, err := base64.StdEncoding.DecodeString(resp)
+			if err != nil || mech != "PLAIN" {
+				_ = tp.PrintfLine("501 invalid auth")
+				continue
+			}
+			sess.auth = string(creds)
+			_ = tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			sess.from = arg
+			_ = tp.PrintfLine("250 ok")
+		case "RCPT":
+			sess.to = arg
+			_ = tp.PrintfLine("250 ok")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			sess.data = string(data)
+			_ = tp.PrintfLine("250 queued")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			s.sessions <- sess
+			return
+		default:
+			_ = tp.PrintfLine("502 unknown command")
+		}
+	}
+}
+
+// testCertificate returns a self-signed certificate for 127.0.0.1.
+func testCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestEmailNotificatorSend(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		implicitTLS bool
+		startTLS    bool
+		wantTLS     bool
+	}{
+		{name: "none", mode: EmailTLSNone, startTLS: true},
+		{name: "starttls", mode: EmailTLSStartTLS, startTLS: true, wantTLS: true},
+		{name: "auto with starttls", mode: EmailTLSAuto, startTLS: true, wantTLS: true},
+		{name: "auto without starttls", mode: EmailTLSAuto},
+		{name: "implicit tls", mode: EmailTLSImplicit, implicitTLS: true, wantTLS: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestSMTPServer(t, tt.implicitTLS, tt.startTLS)
+			nt := NewEmailNotificator(
+				"127.0.0.1", srv.port(), "user", "secret", tt.mode, true,
+			)
+
+			msg, err := NewEmailMessage(
+				"env-cleaner@example.com", "owner@example.com",
+				"Environment is stale", "text body", "<p>html body</p>",
+			)
+			if err != nil {
+				t.Fatalf("NewEmailMessage: %v", err)
+			}
+
+			if err := nt.Send(msg); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+
+			sess := srv.session(t)
+			if sess.tls != tt.wantTLS {
+				t.Errorf("tls = %v, want %v", sess.tls, tt.wantTLS)
+			}
+			if want := "\x00user\x00secret"; sess.auth != want {
+				t.Errorf("auth = %q, want %q", sess.auth, want)
+			}
+			if want := "FROM:<env-cleaner@example.com>"; sess.from != want {
+				t.Errorf("from = %q, want %q", sess.from, want)
+			}
+			if want := "TO:<owner@example.com>"; sess.to != want {
+				t.Errorf("to = %q, want %q", sess.to, want)
+			}
+			if !strings.Contains(sess.data, "Subject: Environment is stale") {
+				t.Errorf("message has no subject:\n%s", sess.data)
+			}
+		})
+	}
+}
+
+func TestEmailNotificatorSendWithoutAuth(t *testing.T) {
+	srv := newTestSMTPServer(t, false, false)
+	nt := NewEmailNotificator("127.0.0.1", srv.port(), "", "", EmailTLSNone, false)
+
+	msg, err := NewEmailMessage("a@example.com", "b@example.com", "s", "t", "")
+	if err != nil {
+		t.Fatalf("NewEmailMessage: %v", err)
+	}
+
+	if err := nt.Send(msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if sess := srv.session(t); sess.auth != "" {
+		t.Errorf("auth = %q, want none", sess.auth)
+	}
+}
+
+func TestEmailNotificatorStartTLSRequired(t *testing.T) {
+	srv := newTestSMTPServer(t, false, false)
+	nt := NewEmailNotificator(
+		"127.0.0.1", srv.port(), "user", "secret", EmailTLSStartTLS, true,
+	)
+
+	msg, err := NewEmailMessage("a@example.com", "b@example.com", "s", "t", "")
+	if err != nil {
+		t.Fatalf("NewEmailMessage: %v", err)
+	}
+
+	err = nt.Send(msg)
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("Send error = %v, want missing STARTTLS", err)
+	}
+}
+
+func TestEmailNotificatorUnknownTLSMode(t *testing.T) {
+	nt := NewEmailNotificator("127.0.0.1", 25, "", "", "ssl", false)
+
+	msg, err := NewEmailMessage("a@example.com", "b@example.com", "s", "t", "")
+	if err != nil {
+		t.Fatalf("NewEmailMessage: %v", err)
+	}
+
+	if err := nt.Send(msg); err == nil {
+		t.Fatal("Send succeeded with an unknown tls mode")
+	}
+}
+
+func TestEmailNotificatorSendDisplayNames(t *testing.T) {
+	srv := newTestSMTPServer(t, false, false)
+	nt := NewEmailNotificator("127.0.0.1", srv.port(), "", "", EmailTLSNone, false)
+
+	msg, err := NewEmailMessage(
+		"Env Cleaner <env-cleaner@example.com>", "Owner <owner@example.com>",
+		"s", "t", "",
+	)
+	if err != nil {
+		t.Fatalf("NewEmailMessage: %v", err)
+	}
+
+	if err := nt.Send(msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	sess := srv.session(t)
+	if want := "FROM:<env-cleaner@example.com>"; sess.from != want {
+		t.Errorf("from = %q, want %q", sess.from, want)
+	}
+	if want := "TO:<owner@example.com>"; sess.to != want {
+		t.Errorf("to = %q, want %q", sess.to, want)
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(sess.data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	for header, want := range map[string]string{
+		"From": `"Env Cleaner" <env-cleaner@example.com>`,
+		"To":   `"Owner" <owner@example.com>`,
+	} {
+		if got := m.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestEmailNotificatorStalledServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	// The server sends the banner and then never answers.
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = io.WriteString(conn, "220 localhost ESMTP\r\n")
+		<-stop
+	}()
+
+	nt := NewEmailNotificator(
+		"127.0.0.1", ln.Addr().(*net.TCPAddr).Port, "", "", EmailTLSAuto, false,
+	)
+	nt.timeout = 100 * time.Millisecond
+
+	msg, err := NewEmailMessage("a@example.com", "b@example.com", "s", "t", "")
+	if err != nil {
+		t.Fatalf("NewEmailMessage: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- nt.Send(msg) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Send succeeded against a stalled server")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send blocked on a stalled server")
+	}
+}
+
+func TestNewEmailMessageInvalidAddress(t *testing.T) {
+	for _, tt := range []struct{ from, to string }{
+		{"", "b@example.com"},
+		{"a@example.com", ""},
+		{"not an address", "b@example.com"},
+		{"a@example.com", "b@"},
+	} {
+		if _, err := NewEmailMessage(tt.from, tt.to, "s", "t", ""); err == nil {
+			t.Errorf("NewEmailMessage(%q, %q) succeeded", tt.from, tt.to)
+		}
+	}
+}
+
+func TestEmailMessageBytes(t *testing.T) {
+	subject := "Окружение release-name устарело ✓"
+	text := "Environment: release-name, type: helm = stale\nÜber lang " +
+		strings.Repeat("x", 100)
+	html := `<p>Environment: <b>release-name</b> &amp; more</p>`
+
+	msg, err := NewEmailMessage(
+		"env-cleaner@example.com", "owner@example.com", subject, text, html,
+	)
+	if err != nil {
+		t.Fatalf("NewEmailMessage: %v", err)
+	}
+
+	raw, err := msg.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+
+	rawSubject := m.Header.Get("Subject")
+	if !strings.HasPrefix(rawSubject, "=?utf-8?q?") {
+		t.Errorf("subject is not Q-encoded: %q", rawSubject)
+	}
+	got, err := new(mime.WordDecoder).DecodeHeader(rawSubject)
+	if err != nil {
+		t.Fatalf("DecodeHeader: %v", err)
+	}
+	if got != subject {
+		t.Errorf("subject = %q, want %q", got, subject)
+	}
+
+	if _, err := m.Header.Date(); err != nil {
+		t.Errorf("invalid Date header: %v", err)
+	}
+	if v := m.Header.Get("MIME-Version"); v != "1.0" {
+		t.Errorf("MIME-Version = %q", v)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("ParseMediaType: %v", err)
+	}
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %q, want multipart/alternative", mediaType)
+	}
+
+	want := []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	}
+
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for i, w := range want {
+		p, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		if ct := p.Header.Get("Content-Type"); ct != w.contentType {
+			t.Errorf("part %d content type = %q, want %q", i, ct, w.contentType)
+		}
+
+		body, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		// Text parts use CRLF line endings on the wire.
+		wantBody := strings.ReplaceAll(w.body, "\n", "\r\n")
+		if string(body) != wantBody {
+			t.Errorf("part %d body = %q, want %q", i, body, wantBody)
+		}
+	}
+
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("unexpected extra part, err = %v", err)
+	}
+}
+
+func TestEmailMessageBytesSkipsEmptyParts(t *testing.T) {
+	msg, err := NewEmailMessage("a@example.com", "b@example.com", "s", "text", "")
+	if err != nil {
+		t.Fatalf("NewEmailMessage: %v", err)
+	}
+
+	raw, err := msg.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+
+	if strings.Contains(string(raw), "text/html") {
+		t.Errorf("message has an html part:\n%s", raw)
+	}
+}
@@ -44,6 +44,17 @@ func IncreaseDeleteAt(date, period string) (string, int64, error) {
 	return deleteAt, deleteAtSec, nil
 }
 
+// ParseDeleteAt parses an absolute delete_at in the 02-01-06 15:04:05
+// format and returns it normalized along with its unix time.
+func ParseDeleteAt(date string) (string, int64, error) {
+	t, err := time.ParseInLocation("02-01-06 15:04:05", date, time.Local)
+	if err != nil {
+		return "", 0, fmt.Errorf("error parsing date: %w", err)
+	}
+
+	return t.Format("02-01-06 15:04:05"), t.Unix(), nil
+}
+
 // PeriodValidate validates that the provided period is less than the max period.
 func PeriodValidate(period string, maxDuration string) error {
 	maxD, err := str2duration.ParseDuration(maxDuration)
@@ -71,3 +82,33 @@ func GenerateToken(length int) (string, error) {
 	}
 	return hex.EncodeToString(b), nil
 }
+
+// ParseTimeBound converts a period back from now (e.g. 7d) or an RFC 3339
+// timestamp to unix time.
+func ParseTimeBound(value string) (int64, error) {
+	if dur, err := str2duration.ParseDuration(value); err == nil {
+		return time.Now().Add(-dur).Unix(), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("expected a period or an RFC 3339 time: %s", value)
+	}
+
+	return t.Unix(), nil
+}
+
+// ParseDeleteBound converts a period ahead of now (e.g. 2d) or an RFC 3339
+// timestamp to unix time.
+func ParseDeleteBound(value string) (int64, error) {
+	if dur, err := str2duration.ParseDuration(value); err == nil {
+		return time.Now().Add(dur).Unix(), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("expected a period or an RFC 3339 time: %s", value)
+	}
+
+	return t.Unix(), nil
+}